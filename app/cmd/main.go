@@ -2,6 +2,9 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strconv"
+	"time"
 
 	"github.com/Taks/internal/application"
 )
@@ -48,7 +51,82 @@ func main() {
 	// application
 
 	// - config
-	app := application.NewDefault(":8080")
+	// las variables de entorno vacías usan los valores por defecto
+	var saveInterval time.Duration
+	if value := os.Getenv("TASK_SAVE_INTERVAL"); value != "" {
+		var err error
+		saveInterval, err = time.ParseDuration(value)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	var walCompactEvery int
+	if value := os.Getenv("TASK_WAL_COMPACT_EVERY"); value != "" {
+		var err error
+		walCompactEvery, err = strconv.Atoi(value)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	var trashRetention time.Duration
+	if value := os.Getenv("TASK_TRASH_RETENTION"); value != "" {
+		var err error
+		trashRetention, err = time.ParseDuration(value)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	var tittleMaxLength int
+	if value := os.Getenv("TASK_TITTLE_MAX_LENGTH"); value != "" {
+		var err error
+		tittleMaxLength, err = strconv.Atoi(value)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	var descriptionMaxLength int
+	if value := os.Getenv("TASK_DESCRIPTION_MAX_LENGTH"); value != "" {
+		var err error
+		descriptionMaxLength, err = strconv.Atoi(value)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	var caseInsensitiveTittles bool
+	if value := os.Getenv("TASK_CASE_INSENSITIVE_TITTLES"); value != "" {
+		var err error
+		caseInsensitiveTittles, err = strconv.ParseBool(value)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	app := application.NewDefault(&application.ConfigDefault{
+		ServerAddr:      os.Getenv("SERVER_ADDR"),
+		Repository:      os.Getenv("TASK_REPOSITORY"),
+		DatabaseFile:    os.Getenv("TASK_DATABASE_FILE"),
+		TaskFile:        os.Getenv("TASK_FILE"),
+		EventFile:       os.Getenv("TASK_EVENT_FILE"),
+		AuditFile:       os.Getenv("TASK_AUDIT_FILE"),
+		SaveInterval:    saveInterval,
+		WALCompactEvery: walCompactEvery,
+		TrashRetention:  trashRetention,
+
+		TittleMaxLength:        tittleMaxLength,
+		DescriptionMaxLength:   descriptionMaxLength,
+		CaseInsensitiveTittles: caseInsensitiveTittles,
+	})
 
 	// - run
 	if err := app.Run(); err != nil {
@@ -1,29 +1,159 @@
 package application
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/Taks/internal"
 	"github.com/Taks/internal/handler"
 	"github.com/Taks/internal/repository"
 	"github.com/Taks/internal/service"
 	"github.com/go-chi/chi"
 )
 
+// Implementaciones de TaskRepository que se pueden elegir al iniciar
+const (
+	// RepositoryMap guarda las tareas en memoria, se pierden al reiniciar.
+	RepositoryMap = "map"
+	// RepositorySQLite guarda las tareas en una base de datos SQLite.
+	RepositorySQLite = "sqlite"
+	// RepositoryFile guarda las tareas en memoria y las persiste en un archivo JSON.
+	RepositoryFile = "file"
+	// RepositoryWAL guarda las tareas en memoria y registra cada cambio en un WAL.
+	RepositoryWAL = "wal"
+	// RepositoryEvents guarda las tareas en memoria como la proyección de un flujo de eventos persistido en un archivo.
+	RepositoryEvents = "events"
+)
+
+// ConfigDefault es la configuración de la application Default.
+type ConfigDefault struct {
+	// ServerAddr es la dirección donde se va a ejecutar el servidor.
+	ServerAddr string
+	// Repository es la implementación de TaskRepository a usar: RepositoryMap, RepositorySQLite, RepositoryFile, RepositoryWAL o RepositoryEvents.
+	Repository string
+	// DatabaseFile es el archivo de la base de datos SQLite.
+	DatabaseFile string
+	// TaskFile es el archivo JSON donde se persisten las tareas con RepositoryFile o RepositoryWAL.
+	TaskFile string
+	// EventFile es el archivo donde se guarda el flujo de eventos con RepositoryEvents.
+	EventFile string
+	// AuditFile es el archivo donde se guarda la auditoria con RepositoryFile, RepositoryWAL o RepositoryEvents, con RepositorySQLite se guarda en la base de datos.
+	AuditFile string
+	// SaveInterval es cada cuánto se escribe el archivo JSON, si es 0 se escribe después de cada cambio.
+	SaveInterval time.Duration
+	// WALCompactEvery es la cantidad de registros del WAL antes de compactarlo en el archivo JSON.
+	WALCompactEvery int
+	// TrashRetention es cuánto tiempo quedan las tareas en la papelera antes de eliminarlas definitivamente, si es 0 no se eliminan.
+	TrashRetention time.Duration
+	// TittleMaxLength es la cantidad máxima de caracteres del título, si es 0 se usa internal.TaskTittleMaxLength y si es negativo no se limita.
+	TittleMaxLength int
+	// DescriptionMaxLength es la cantidad máxima de caracteres de la descripción, si es 0 se usa internal.TaskDescriptionMaxLength y si es negativo no se limita.
+	DescriptionMaxLength int
+	// CaseInsensitiveTittles indica si dos títulos que solo difieren en mayúsculas y minúsculas se consideran duplicados.
+	CaseInsensitiveTittles bool
+}
+
 // Default  es una implenetación de application.
 type Default struct {
 	// addr es la dirección donde se va a ejecutar el servidor.
 	addr string
+	// repository es la implementación de TaskRepository a usar.
+	repository string
+	// databaseFile es el archivo de la base de datos SQLite.
+	databaseFile string
+	// taskFile es el archivo JSON donde se persisten las tareas.
+	taskFile string
+	// eventFile es el archivo donde se guarda el flujo de eventos.
+	eventFile string
+	// auditFile es el archivo donde se guarda la auditoria.
+	auditFile string
+	// saveInterval es cada cuánto se escribe el archivo JSON.
+	saveInterval time.Duration
+	// walCompactEvery es la cantidad de registros del WAL antes de compactarlo.
+	walCompactEvery int
+	// trashRetention es cuánto tiempo quedan las tareas en la papelera.
+	trashRetention time.Duration
+	// taskRules son las reglas del título y la descripción de las tareas.
+	taskRules internal.TaskRules
 }
 
+// trashPurgeInterval es cada cuánto se eliminan las tareas de la papelera que superaron la retención.
+const trashPurgeInterval = time.Minute
+
+// shutdownTimeout es cuánto se espera a que terminen los requests en curso al apagar el servidor.
+const shutdownTimeout = 10 * time.Second
+
+// trashPurgeActor es el actor con el que se registran en la auditoría las tareas eliminadas por la retención.
+const trashPurgeActor = "trash-retention"
+
 // NewDefault retorns a new Default application.
-func NewDefault(addr string) *Default {
-	if addr == "" {
-		addr = ":8080"
+func NewDefault(cfg *ConfigDefault) *Default {
+	// valores por defecto
+	defaultCfg := &ConfigDefault{
+		ServerAddr:   ":8080",
+		Repository:   RepositoryMap,
+		DatabaseFile: "tasks.db",
+		TaskFile:     "tasks.json",
+		EventFile:    "events.jsonl",
+		AuditFile:    "audit.jsonl",
+
+		TittleMaxLength:      internal.TaskTittleMaxLength,
+		DescriptionMaxLength: internal.TaskDescriptionMaxLength,
+	}
+	if cfg != nil {
+		if cfg.ServerAddr != "" {
+			defaultCfg.ServerAddr = cfg.ServerAddr
+		}
+		if cfg.Repository != "" {
+			defaultCfg.Repository = cfg.Repository
+		}
+		if cfg.DatabaseFile != "" {
+			defaultCfg.DatabaseFile = cfg.DatabaseFile
+		}
+		if cfg.TaskFile != "" {
+			defaultCfg.TaskFile = cfg.TaskFile
+		}
+		if cfg.EventFile != "" {
+			defaultCfg.EventFile = cfg.EventFile
+		}
+		if cfg.AuditFile != "" {
+			defaultCfg.AuditFile = cfg.AuditFile
+		}
+		defaultCfg.SaveInterval = cfg.SaveInterval
+		defaultCfg.WALCompactEvery = cfg.WALCompactEvery
+		defaultCfg.TrashRetention = cfg.TrashRetention
+		defaultCfg.CaseInsensitiveTittles = cfg.CaseInsensitiveTittles
+		if cfg.TittleMaxLength != 0 {
+			defaultCfg.TittleMaxLength = cfg.TittleMaxLength
+		}
+		if cfg.DescriptionMaxLength != 0 {
+			defaultCfg.DescriptionMaxLength = cfg.DescriptionMaxLength
+		}
 	}
 
 	return &Default{
-		addr: addr,
+		addr:            defaultCfg.ServerAddr,
+		repository:      defaultCfg.Repository,
+		databaseFile:    defaultCfg.DatabaseFile,
+		taskFile:        defaultCfg.TaskFile,
+		eventFile:       defaultCfg.EventFile,
+		auditFile:       defaultCfg.AuditFile,
+		saveInterval:    defaultCfg.SaveInterval,
+		walCompactEvery: defaultCfg.WALCompactEvery,
+		trashRetention:  defaultCfg.TrashRetention,
+		taskRules: internal.TaskRules{
+			TittleMaxLength:        defaultCfg.TittleMaxLength,
+			DescriptionMaxLength:   defaultCfg.DescriptionMaxLength,
+			CaseInsensitiveTittles: defaultCfg.CaseInsensitiveTittles,
+		},
 	}
 }
 
@@ -32,11 +162,34 @@ func (a *Default) Run() (err error) {
 	// dependencias
 	//Inicializar las dependencias
 
-	//Dependencia del repository
-	rp := repository.NewTaskMap(nil, 0)
+	//Dependencia del repository y de la auditoria
+	rp, audit, closeRepository, err := a.newRepository()
+	if err != nil {
+		return fmt.Errorf("error al iniciar el repositorio: %v", err)
+	}
+	defer closeRepository()
+
+	//Los errores que no se pueden devolver en una respuesta se informan en la salida de errores
+	logger := slog.New(slog.NewTextHandler(os.Stderr, nil))
 
 	//Dependencia del service
-	sv := service.NewTaskService(rp)
+	sv := service.NewTaskService(rp, audit).WithRules(a.taskRules).WithLogger(logger)
+
+	//Eliminar en segundo plano las tareas que superaron la retención de la papelera
+	//Al terminar se espera a que la limpieza en curso termine antes de cerrar el repositorio
+	if a.trashRetention > 0 {
+		stop := make(chan struct{})
+		var purging sync.WaitGroup
+		purging.Add(1)
+		defer func() {
+			close(stop)
+			purging.Wait()
+		}()
+		go func() {
+			defer purging.Done()
+			a.purgeTrash(sv.WithActor(trashPurgeActor), logger, stop)
+		}()
+	}
 
 	//Dependencia del handler
 	h := handler.NewTaskHandler(sv)
@@ -44,6 +197,9 @@ func (a *Default) Run() (err error) {
 	//Dependencia para el router
 	router := chi.NewRouter()
 
+	//Limitar el tamaño del body de todos los requests
+	router.Use(handler.LimitBody(handler.RequestMaxBytes))
+
 	//Registrar los endpoints
 	router.Route("/task", func(r chi.Router) {
 		//Método POST
@@ -58,14 +214,166 @@ func (a *Default) Run() (err error) {
 		//Método DELETE
 		r.Delete("/delete/{id}", h.DeleteTask())
 
+		//Método GET con filtros, orden y paginacion
+		r.Get("/", h.ListTasks())
+
 		//Método GETBYID
 		r.Get("/get/{id}", h.GetTaskByID())
+
+		//Métodos de etiquetas
+		r.Get("/tags", h.ListTags())
+		r.Post("/{id}/tags/{tag}", h.AddTag())
+		r.Delete("/{id}/tags/{tag}", h.RemoveTag())
+
+		//Métodos de subtareas
+		r.Get("/{id}/children", h.GetChildren())
+		r.Get("/{id}/subtree", h.GetSubtree())
+
+		//Historial de cambios de una tarea
+		r.Get("/{id}/history", h.GetHistory())
+
+		//Registro de cambios de todas las tareas, ?since= es el cursor y ?wait= espera cambios nuevos
+		r.Get("/changes", h.GetChanges())
+
+		//Stream de eventos (SSE) con los cambios a medida que ocurren, filtra por ?id= y ?done=
+		r.Get("/events", h.StreamEvents())
+
+		//WebSocket para recibir los cambios y enviar comandos (create, update, patch, delete) por la misma conexion
+		r.Get("/ws", h.TaskSocket())
+
+		//Revisiones de una tarea: por numero, vigente en un instante (?at=) y volver a una revision
+		r.Get("/{id}/revisions", h.GetRevisionAt())
+		r.Get("/{id}/revisions/{revision}", h.GetRevision())
+		r.Post("/{id}/revisions/{revision}/revert", h.RevertTask())
+
+		//Métodos de dependencias, /next devuelve las tareas sin terminar en orden de dependencias
+		r.Get("/next", h.NextTasks())
+		r.Post("/{id}/dependencies/{blockedBy}", h.AddDependency())
+		r.Delete("/{id}/dependencies/{blockedBy}", h.RemoveDependency())
+
+		//Métodos de la papelera
+		r.Get("/trash", h.ListTrash())
+		r.Post("/trash/{id}/restore", h.RestoreTask())
+		r.Delete("/trash/{id}", h.PurgeTask())
 	})
 
-	// Iniciar el servidor
-	if err := http.ListenAndServe(a.addr, router); err != nil {
+	// Iniciar el servidor hasta recibir SIGINT o SIGTERM
+	ctx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	// Los requests que esperan cambios (long polling, SSE y WebSocket) terminan cuando se cancela baseCtx
+	baseCtx, cancelRequests := context.WithCancel(context.Background())
+	defer cancelRequests()
+
+	server := &http.Server{
+		Addr:        a.addr,
+		Handler:     router,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-serveErr:
 		return fmt.Errorf("error al iniciar el servidor: %v", err)
+	case <-ctx.Done():
+	}
+
+	// Apagar el servidor esperando los requests en curso, despues los defer guardan y cierran el repositorio
+	cancelRequests()
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		server.Close()
+		return fmt.Errorf("error al apagar el servidor: %v", err)
 	}
 
 	return nil
 }
+
+// Método para eliminar periódicamente las tareas de la papelera que superaron la retención
+// Se ejecuta hasta que se cierra stop, un error se informa y se vuelve a intentar en el siguiente intervalo
+func (a *Default) purgeTrash(sv internal.TaskService, logger *slog.Logger, stop <-chan struct{}) {
+	ticker := time.NewTicker(trashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := sv.PurgeExpired(a.trashRetention); err != nil {
+			logger.Error("trash purge failed", "error", err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Método para crear el repositorio configurado junto con la auditoria que le corresponde
+// Devuelve también una función para liberar sus recursos al terminar
+func (a *Default) newRepository() (rp internal.TaskRepository, audit internal.AuditStore, closeFn func(), err error) {
+	switch a.repository {
+	case RepositoryMap:
+		rp = repository.NewTaskMap(nil, 0)
+		audit = repository.NewAuditMap()
+		closeFn = func() {}
+	case RepositorySQLite:
+		db, errOpen := repository.OpenSQLite(a.databaseFile)
+		if errOpen != nil {
+			err = errOpen
+			return
+		}
+
+		rp, err = repository.NewTaskSQL(db)
+		if err != nil {
+			db.Close()
+			return
+		}
+		audit, err = repository.NewAuditSQL(db)
+		if err != nil {
+			db.Close()
+			return
+		}
+		closeFn = func() { db.Close() }
+	case RepositoryFile, RepositoryWAL, RepositoryEvents:
+		var taskMap *repository.TaskMap
+		var errFile error
+		switch a.repository {
+		case RepositoryFile:
+			taskMap, errFile = repository.NewTaskMapFile(a.taskFile, a.saveInterval)
+		case RepositoryWAL:
+			taskMap, errFile = repository.NewTaskMapWAL(a.taskFile, a.walCompactEvery)
+		default:
+			taskMap, errFile = repository.NewTaskMapEvents(a.eventFile)
+		}
+		if errFile != nil {
+			err = errFile
+			return
+		}
+
+		auditFile, errAudit := repository.NewAuditFile(a.auditFile)
+		if errAudit != nil {
+			taskMap.Close()
+			err = errAudit
+			return
+		}
+
+		rp = taskMap
+		audit = auditFile
+		closeFn = func() {
+			if err := taskMap.Close(); err != nil {
+				fmt.Println(err)
+			}
+			if err := auditFile.Close(); err != nil {
+				fmt.Println(err)
+			}
+		}
+	default:
+		err = fmt.Errorf("repositorio desconocido: %s", a.repository)
+	}
+	return
+}
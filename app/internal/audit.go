@@ -0,0 +1,163 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+/*
+	Este archivo contiene el registro de auditoria de las tareas.
+
+	Cada cambio que pasa por el servicio se guarda como una entrada inmutable en un AuditStore:
+	quien lo hizo, cuando, la operacion y el valor anterior y posterior de cada campo que cambio.
+	El AuditStore es independiente del TaskRepository, por eso el historial de una tarea se
+	conserva aunque la tarea se elimine definitivamente.
+
+	Cada entrada guarda ademas el estado completo de la tarea despues del cambio, asi cada
+	entrada es una revision de la tarea y se puede ver como era la tarea en cualquier momento.
+*/
+
+// Operaciones que se registran en la auditoria
+const (
+	AuditOpSave             = "save"
+	AuditOpUpdate           = "update"
+	AuditOpUpdatePartial    = "update_partial"
+	AuditOpDelete           = "delete"
+	AuditOpRestore          = "restore"
+	AuditOpPurge            = "purge"
+	AuditOpAddTag           = "add_tag"
+	AuditOpRemoveTag        = "remove_tag"
+	AuditOpAddDependency    = "add_dependency"
+	AuditOpRemoveDependency = "remove_dependency"
+	AuditOpRevert           = "revert"
+
+	//Cambio en una tarea que es consecuencia del cambio de otra, como las subtareas de una tarea que se
+	//elimina o la siguiente ocurrencia que se crea al completar una tarea recurrente
+	AuditOpRelated = "related"
+)
+
+// Actor que se registra cuando no se sabe quien hizo el cambio
+const AuditActorAnonymous = "anonymous"
+
+// Cambio de un campo de una tarea, los valores estan en JSON y null indica que no habia valor
+type FieldChange struct {
+	Field  string
+	Before json.RawMessage
+	After  json.RawMessage
+}
+
+// Entrada de la auditoria, una vez guardada no se modifica
+type AuditEntry struct {
+	//ID de la entrada, lo asigna el AuditStore y crece con cada entrada
+	ID int
+
+	TaskID int
+	Actor  string
+	At     time.Time
+	Op     string
+
+	//Numero de revision de la tarea, la primera entrada de cada tarea es la revision 1
+	//Lo asigna el AuditStore segun el orden de las entradas de la tarea
+	Revision int
+
+	//Campos que cambiaron, en el orden de auditFields
+	Changes []FieldChange
+
+	//Estado completo de la tarea despues del cambio, es nil si la tarea se elimino definitivamente
+	//o si la entrada se guardo antes de que se registraran las revisiones
+	Task *Task
+}
+
+// Consulta del historial de una tarea, las entradas se devuelven de la mas antigua a la mas reciente
+type AuditQuery struct {
+	TaskID int
+
+	//Cantidad maxima de entradas a devolver, 0 devuelve todas
+	Limit int
+
+	//Cantidad de entradas a saltear
+	Offset int
+}
+
+// Resultado de una consulta del historial
+type AuditPage struct {
+	Entries []AuditEntry
+
+	//Total de entradas de la tarea, sin tener en cuenta la paginacion
+	Total int
+}
+
+// Interfaz del almacenamiento de la auditoria
+type AuditStore interface {
+	//Guardar una entrada, le asigna el ID
+	Append(entry *AuditEntry) (err error)
+
+	//Obtener una pagina del historial de una tarea
+	List(query AuditQuery) (page AuditPage, err error)
+
+	//Obtener las entradas de todas las tareas con ID mayor a after, en orden de ID
+	//Devuelve como maximo limit entradas, 0 devuelve todas
+	ListSince(after int, limit int) (entries []AuditEntry, err error)
+}
+
+// Campos de una tarea que se comparan en la auditoria, con el mismo nombre que en la API
+// Los campos que calcula el repositorio (Blocked, Version, CreatedAt y UpdatedAt) no se comparan
+var auditFields = []struct {
+	name  string
+	value func(task Task) any
+}{
+	{"tittle", func(task Task) any { return task.Tittle }},
+	{"description", func(task Task) any { return task.Description }},
+	{"done", func(task Task) any { return task.Done }},
+	{"start_at", func(task Task) any { return task.StartAt }},
+	{"due_at", func(task Task) any { return task.DueAt }},
+	{"priority", func(task Task) any { return task.Priority }},
+	{"tags", func(task Task) any { return task.Tags }},
+	{"parent_id", func(task Task) any { return task.ParentID }},
+	{"blocked_by", func(task Task) any { return task.BlockedBy }},
+	{"recurrence", func(task Task) any { return task.Recurrence }},
+	{"deleted_at", func(task Task) any { return task.DeletedAt }},
+}
+
+// Metodo para validar una consulta del historial
+func (q AuditQuery) Validate() (err error) {
+	if q.Limit < 0 || q.Offset < 0 {
+		err = fmt.Errorf("%w: invalid pagination", ErrTaskInvalidField)
+	}
+	return
+}
+
+// Funcion para comparar dos estados de una tarea campo por campo
+// before es nil si la tarea no existia y after es nil si la tarea ya no existe, en esos casos los valores son null
+func DiffTasks(before, after *Task) (changes []FieldChange, err error) {
+	for _, field := range auditFields {
+		var beforeValue, afterValue json.RawMessage
+		if beforeValue, err = auditValue(before, field.value); err != nil {
+			return
+		}
+		if afterValue, err = auditValue(after, field.value); err != nil {
+			return
+		}
+
+		if !bytes.Equal(beforeValue, afterValue) {
+			changes = append(changes, FieldChange{Field: field.name, Before: beforeValue, After: afterValue})
+		}
+	}
+	return
+}
+
+// Funcion para obtener el valor en JSON de un campo de una tarea que puede no existir
+func auditValue(task *Task, value func(task Task) any) (raw json.RawMessage, err error) {
+	if task == nil {
+		raw = json.RawMessage("null")
+		return
+	}
+
+	raw, err = json.Marshal(value(*task))
+	if err != nil {
+		err = fmt.Errorf("%w: %v", ErrTaskInternal, err)
+	}
+	return
+}
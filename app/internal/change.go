@@ -0,0 +1,165 @@
+package internal
+
+import (
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+)
+
+/*
+	Este archivo contiene el registro de cambios de las tareas.
+
+	Es una vista de la auditoria pensada para sistemas que replican las tareas: todas las
+	entradas de todas las tareas en el orden en que se hicieron, cada una como un cambio
+	created, updated o deleted con el estado de la tarea. Cada cambio tiene un cursor opaco
+	para seguir leyendo desde ahi, aunque el servicio se reinicie. Si la entrada de un cambio no se
+	puede guardar el servicio no hace otros cambios hasta guardarla, asi el registro no saltea cambios.
+
+	Los cambios tambien se pueden recibir a medida que ocurren con una suscripcion. Para las
+	suscripciones el servicio conserva en memoria solo los ultimos cambios, una suscripcion
+	que retoma desde un cursor mas antiguo se entera de que se perdio cambios con Gap.
+*/
+
+// Tipos de cambios del registro
+const (
+	ChangeCreated = "created"
+	ChangeUpdated = "updated"
+	ChangeDeleted = "deleted"
+)
+
+// Limites de una consulta del registro de cambios
+const (
+	//Cantidad de cambios que se devuelven si la consulta no indica un limite
+	ChangeDefaultLimit = 100
+
+	//Cantidad maxima de cambios por consulta
+	ChangeMaxLimit = 1000
+
+	//Tiempo maximo de espera de una consulta, si se pide mas se espera este tiempo
+	ChangeMaxWait = time.Minute
+)
+
+// Cambio de una tarea en el registro de cambios
+type Change struct {
+	//Cursor para seguir leyendo despues de este cambio
+	Cursor string
+
+	Type   string
+	TaskID int
+	At     time.Time
+
+	//Estado de la tarea despues del cambio, en un deleted es la tarea en la papelera
+	//Es nil si la tarea se elimino definitivamente o si la entrada no tiene el estado de la tarea
+	Task *Task
+}
+
+// Consulta del registro de cambios
+type ChangeQuery struct {
+	//Cursor del ultimo cambio leido, vacio lee desde el principio
+	Cursor string
+
+	//Cantidad maxima de cambios a devolver, 0 usa ChangeDefaultLimit
+	Limit int
+
+	//Si no hay cambios nuevos, tiempo que se espera a que llegue alguno antes de devolver una pagina vacia
+	Wait time.Duration
+}
+
+// Resultado de una consulta del registro de cambios
+type ChangePage struct {
+	Changes []Change
+
+	//Cursor para la siguiente consulta, si no hay cambios es el mismo de la consulta
+	Cursor string
+}
+
+// Filtro de los cambios de una suscripcion, los campos vacios no filtran
+type ChangeFilter struct {
+	//Solo los cambios de estas tareas
+	TaskIDs []int
+
+	//Solo los cambios en que la tarea queda en este estado, excluye las tareas eliminadas definitivamente
+	Done *bool
+}
+
+// Suscripcion a los cambios de las tareas a medida que ocurren
+type ChangeSubscription struct {
+	//Primero recibe los cambios posteriores al cursor que se conservan y despues los nuevos
+	//Se cierra cuando se cancela la suscripcion o si el suscriptor no lee los cambios a tiempo
+	Changes <-chan Change
+
+	//Es true si algunos cambios posteriores al cursor ya no se conservan y no se van a recibir
+	Gap bool
+}
+
+// Metodo para validar una consulta del registro de cambios
+func (q ChangeQuery) Validate() (err error) {
+	if q.Limit < 0 || q.Limit > ChangeMaxLimit {
+		err = fmt.Errorf("%w: invalid limit %d", ErrTaskInvalidField, q.Limit)
+		return
+	}
+	if q.Wait < 0 {
+		err = fmt.Errorf("%w: invalid wait %s", ErrTaskInvalidField, q.Wait)
+		return
+	}
+
+	_, err = ParseChangeCursor(q.Cursor)
+	return
+}
+
+// Funcion para crear el cursor que apunta despues de una entrada de la auditoria
+func EncodeChangeCursor(entryID int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(entryID)))
+}
+
+// Funcion para leer un cursor, devuelve el ID de la ultima entrada leida (0 si el cursor esta vacio)
+func ParseChangeCursor(cursor string) (entryID int, err error) {
+	if cursor == "" {
+		return
+	}
+
+	bytes, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err == nil {
+		entryID, err = strconv.Atoi(string(bytes))
+	}
+	if err != nil || entryID < 0 {
+		entryID = 0
+		err = fmt.Errorf("%w: invalid cursor", ErrTaskInvalidField)
+		return
+	}
+	return
+}
+
+// Funcion para crear el cambio que corresponde a una entrada de la auditoria
+// La primera revision de una tarea y una restauracion son created, moverla a la papelera o eliminarla es deleted
+func NewChange(entry AuditEntry) Change {
+	change := Change{
+		Cursor: EncodeChangeCursor(entry.ID),
+		Type:   ChangeUpdated,
+		TaskID: entry.TaskID,
+		At:     entry.At,
+		Task:   entry.Task,
+	}
+
+	switch {
+	case entry.Op == AuditOpDelete || entry.Op == AuditOpPurge:
+		change.Type = ChangeDeleted
+	case entry.Revision == 1 || entry.Op == AuditOpRestore:
+		change.Type = ChangeCreated
+	}
+	return change
+}
+
+// Metodo para saber si un cambio cumple el filtro
+func (f ChangeFilter) Match(change Change) bool {
+	if len(f.TaskIDs) > 0 && !slices.Contains(f.TaskIDs, change.TaskID) {
+		return false
+	}
+
+	if f.Done != nil && (change.Task == nil || change.Task.Done != *f.Done) {
+		return false
+	}
+	return true
+}
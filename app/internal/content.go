@@ -0,0 +1,118 @@
+package internal
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/Taks/internal/tools"
+	"golang.org/x/text/unicode/norm"
+)
+
+/*
+	Este archivo contiene las reglas del contenido de las tareas: el titulo y la descripcion.
+
+	El servicio aplica las reglas antes de guardar una tarea: normaliza el texto (NFC y el titulo
+	sin espacios al principio o al final) y valida que no este vacio, que no supere el largo maximo
+	y que no tenga caracteres de control. Asi el repositorio compara los titulos duplicados sobre
+	el texto ya normalizado.
+*/
+
+// Largos maximos por defecto del titulo y la descripcion, en caracteres
+const (
+	TaskTittleMaxLength      = 200
+	TaskDescriptionMaxLength = 10000
+)
+
+// Reglas del contenido de las tareas
+type TaskRules struct {
+	//Cantidad maxima de caracteres del titulo y de la descripcion, 0 no limita
+	TittleMaxLength      int
+	DescriptionMaxLength int
+
+	//Si es true dos titulos que solo difieren en mayusculas y minusculas se consideran duplicados
+	//Es una regla del servicio: los repositorios y el indice de SQLite solo rechazan los titulos exactamente iguales
+	CaseInsensitiveTittles bool
+}
+
+// Funcion para obtener las reglas por defecto
+func DefaultTaskRules() TaskRules {
+	return TaskRules{
+		TittleMaxLength:      TaskTittleMaxLength,
+		DescriptionMaxLength: TaskDescriptionMaxLength,
+	}
+}
+
+// Metodo para normalizar y validar el titulo y la descripcion de una tarea
+// Si hay campos invalidos el error envuelve ErrTaskInvalidField y un tools.ValidationError con el detalle de cada campo
+func (r TaskRules) Apply(task *Task) (err error) {
+	var v tools.Validator
+	(*task).Tittle = r.tittle(&v, (*task).Tittle)
+	(*task).Description = r.description(&v, (*task).Description)
+
+	err = invalidFields(&v)
+	return
+}
+
+// Metodo para normalizar y validar el titulo y la descripcion de una actualizacion parcial, solo los campos que se envian
+func (r TaskRules) ApplyPatch(patch *TaskPatch) (err error) {
+	var v tools.Validator
+	if (*patch).Tittle.Set {
+		(*patch).Tittle.Value = r.tittle(&v, (*patch).Tittle.Value)
+	}
+	if (*patch).Description.Set {
+		(*patch).Description.Value = r.description(&v, (*patch).Description.Value)
+	}
+
+	err = invalidFields(&v)
+	return
+}
+
+// Metodo para normalizar un titulo: NFC y sin espacios al principio o al final
+// No puede quedar vacio ni tener caracteres de control, tampoco saltos de linea
+func (r TaskRules) tittle(v *tools.Validator, tittle string) string {
+	tittle = strings.TrimSpace(norm.NFC.String(tittle))
+
+	rules := tools.FieldRules{Field: "tittle", Rules: []tools.Rule{
+		tools.Format("must not be empty", func(text string) bool { return text != "" }),
+		maxLength(r.TittleMaxLength),
+		noControl(unicode.IsControl),
+	}}
+	if msg := rules.Check(tittle); msg != "" {
+		v.Add(rules.Field, msg)
+	}
+	return tittle
+}
+
+// Metodo para normalizar una descripcion en NFC, puede estar vacia y tener saltos de linea y tabulaciones
+func (r TaskRules) description(v *tools.Validator, description string) string {
+	description = norm.NFC.String(description)
+
+	rules := tools.FieldRules{Field: "description", Rules: []tools.Rule{
+		maxLength(r.DescriptionMaxLength),
+		noControl(isForbiddenControl),
+	}}
+	if msg := rules.Check(description); msg != "" {
+		v.Add(rules.Field, msg)
+	}
+	return description
+}
+
+// Funcion para obtener la regla del largo maximo de un texto, 0 no limita
+func maxLength(max int) tools.Rule {
+	if max <= 0 {
+		return func(value any) string { return "" }
+	}
+	return tools.MaxLength(max)
+}
+
+// Funcion para obtener la regla que rechaza los caracteres de control que indica forbidden
+func noControl(forbidden func(r rune) bool) tools.Rule {
+	return tools.Format("must not contain control characters", func(text string) bool {
+		return strings.IndexFunc(text, forbidden) < 0
+	})
+}
+
+// Funcion para saber si un caracter es de control y no es un salto de linea o una tabulacion
+func isForbiddenControl(r rune) bool {
+	return unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t'
+}
@@ -0,0 +1,94 @@
+package internal_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/tools"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de las reglas del titulo y la descripcion
+func TestTaskRules(t *testing.T) {
+	rules := internal.TaskRules{TittleMaxLength: 5, DescriptionMaxLength: 8}
+
+	//Test el titulo se guarda sin espacios alrededor y el texto en NFC
+	t.Run("Success - Trim and NFC", func(t *testing.T) {
+
+		//arrange
+		task := internal.Task{Tittle: "  Cafe\u0301 \n", Description: "line 1\n\tcafe\u0301"}
+
+		//act
+		err := internal.DefaultTaskRules().Apply(&task)
+
+		//assert
+		require.NoError(t, err)
+		require.Equal(t, "Caf\u00e9", task.Tittle)
+		require.Equal(t, "line 1\n\tcaf\u00e9", task.Description)
+	})
+
+	//Test el largo se cuenta en caracteres despues de normalizar
+	t.Run("Success - Length in characters", func(t *testing.T) {
+
+		//arrange
+		task := internal.Task{Tittle: "ñandú", Description: "ñandú ok"}
+
+		//act
+		err := rules.Apply(&task)
+
+		//assert
+		require.NoError(t, err)
+		require.Equal(t, "ñandú", task.Tittle)
+	})
+
+	//Test se informan los errores del titulo y la descripcion juntos
+	t.Run("Error - Invalid tittle and description", func(t *testing.T) {
+		cases := []struct {
+			name   string
+			task   internal.Task
+			errors []*tools.FieldError
+		}{
+			{"empty", internal.Task{Tittle: " \t "}, []*tools.FieldError{
+				{Field: "tittle", Msg: "must not be empty"},
+			}},
+			{"too long", internal.Task{Tittle: "task 10", Description: strings.Repeat("a", 9)}, []*tools.FieldError{
+				{Field: "tittle", Msg: "must be at most 5 characters"},
+				{Field: "description", Msg: "must be at most 8 characters"},
+			}},
+			{"control characters", internal.Task{Tittle: "a\nb", Description: "bell\a"}, []*tools.FieldError{
+				{Field: "tittle", Msg: "must not contain control characters"},
+				{Field: "description", Msg: "must not contain control characters"},
+			}},
+		}
+
+		for _, c := range cases {
+			//act
+			err := rules.Apply(&c.task)
+
+			//assert
+			require.ErrorIs(t, err, internal.ErrTaskInvalidField, c.name)
+			var validationErr *tools.ValidationError
+			require.ErrorAs(t, err, &validationErr, c.name)
+			require.Equal(t, c.errors, validationErr.Errors, c.name)
+		}
+	})
+
+	//Test en una actualizacion parcial solo se validan los campos que se envian
+	t.Run("Success - Patch only set fields", func(t *testing.T) {
+
+		//arrange
+		patch := internal.TaskPatch{Tittle: internal.Set(" task "), Done: internal.Set(true)}
+		invalid := internal.TaskPatch{Description: internal.Set("\x00")}
+
+		//act
+		err := rules.ApplyPatch(&patch)
+		errInvalid := rules.ApplyPatch(&invalid)
+
+		//assert
+		require.NoError(t, err)
+		require.Equal(t, internal.Set("task"), patch.Tittle)
+		require.False(t, patch.Description.Set)
+		require.ErrorIs(t, errInvalid, internal.ErrTaskInvalidField)
+	})
+}
@@ -0,0 +1,89 @@
+package internal
+
+import "sort"
+
+/*
+	Este archivo contiene el grafo de dependencias entre tareas.
+
+	Una tarea A bloqueada por una tarea B no puede empezar hasta que B este terminada.
+	Las dependencias forman un grafo dirigido sin ciclos: los repositorios rechazan con
+	ErrTaskDependencyCycle cualquier dependencia que cierre un ciclo.
+*/
+
+// Funcion para normalizar las dependencias de una tarea: sin repetidas y ordenadas
+// Si no hay dependencias devuelve nil
+func NormalizeDependencies(ids []int) (normalized []int) {
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			normalized = append(normalized, id)
+		}
+	}
+
+	sort.Ints(normalized)
+	return
+}
+
+// Funcion para ordenar tareas de forma que cada una aparezca despues de las tareas que la bloquean
+//
+// Solo se tienen en cuenta las dependencias entre las tareas recibidas, una dependencia con una tarea
+// que no esta en la lista se considera cumplida. Entre las tareas que pueden ir a continuacion va primero
+// la de mayor prioridad y despues la de menor ID.
+// Si las dependencias forman un ciclo devuelve ErrTaskDependencyCycle.
+func SortByDependencies(tasks []Task) (sorted []Task, err error) {
+	//Paso 1: Contar cuantas tareas de la lista bloquean a cada tarea
+	byID := make(map[int]Task, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+	}
+
+	pending := make(map[int]int, len(tasks))
+	blocks := make(map[int][]int, len(tasks))
+	for _, task := range tasks {
+		for _, id := range task.BlockedBy {
+			if _, ok := byID[id]; ok {
+				pending[task.ID]++
+				blocks[id] = append(blocks[id], task.ID)
+			}
+		}
+	}
+
+	//Paso 2: Empezar por las tareas que no estan bloqueadas
+	ready := make([]Task, 0, len(tasks))
+	for _, task := range tasks {
+		if pending[task.ID] == 0 {
+			ready = append(ready, task)
+		}
+	}
+
+	//Paso 3: Sacar la siguiente tarea y liberar las que dependian de ella
+	sorted = make([]Task, 0, len(tasks))
+	for len(ready) > 0 {
+		sort.Slice(ready, func(i, j int) bool {
+			if ready[i].Priority != ready[j].Priority {
+				return ready[i].Priority > ready[j].Priority
+			}
+			return ready[i].ID < ready[j].ID
+		})
+
+		next := ready[0]
+		ready = ready[1:]
+		sorted = append(sorted, next)
+
+		for _, id := range blocks[next.ID] {
+			pending[id]--
+			if pending[id] == 0 {
+				ready = append(ready, byID[id])
+			}
+		}
+	}
+
+	//Si quedaron tareas sin ordenar es porque forman un ciclo
+	if len(sorted) < len(tasks) {
+		sorted = nil
+		err = ErrTaskDependencyCycle
+		return
+	}
+	return
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/tools"
+	"github.com/Taks/pkg/response"
+)
+
+/*
+	Este archivo contiene el mapeo de los errores a problemas RFC 7807 (application/problem+json).
+
+	Todos los handlers envian sus errores con writeError, asi un mismo error tiene siempre el mismo
+	type, title y status. El detail explica el caso particular y los errores de validacion de cada
+	campo (tools.FieldError) se envian juntos en errors.
+*/
+
+// Prefijo de los tipos de problemas, son URIs relativas a la API
+const problemTypeBase = "/problems/"
+
+// Error de un request que el handler rechaza antes de llamar al servicio: id, parametros de la consulta o headers invalidos
+var errInvalidRequest = errors.New("invalid request")
+
+// Error de un body mas grande que el limite de LimitBody
+var errRequestTooLarge = errors.New("request body too large")
+
+// Tipo de problema: su status HTTP, el final de su URI y su titulo
+type problemType struct {
+	status int
+	slug   string
+	title  string
+}
+
+// Tipos de problemas de cada error, en orden: los errores mas especificos van antes que los errores que envuelven
+var problemTypes = []struct {
+	err error
+	problemType
+}{
+	{internal.ErrTaskRevisionNotFound, problemType{http.StatusNotFound, "revision-not-found", "Revision not found"}},
+	{internal.ErrTaskNotFound, problemType{http.StatusNotFound, "task-not-found", "Task not found"}},
+	{internal.ErrTaskVersionConflict, problemType{http.StatusPreconditionFailed, "version-conflict", "Task version conflict"}},
+	{internal.ErrTaskDuplicated, problemType{http.StatusConflict, "task-duplicated", "Task already exists"}},
+	{internal.ErrTaskOpenChildren, problemType{http.StatusConflict, "open-children", "Task has open children"}},
+	{internal.ErrTaskParentCycle, problemType{http.StatusConflict, "parent-cycle", "Parent would create a cycle"}},
+	{internal.ErrTaskDependencyCycle, problemType{http.StatusConflict, "dependency-cycle", "Dependency would create a cycle"}},
+	{internal.ErrTaskAuditUnavailable, problemType{http.StatusServiceUnavailable, "audit-unavailable", "Audit unavailable"}},
+	{internal.ErrTaskInvalidField, problemType{http.StatusBadRequest, "invalid-field", "Invalid field"}},
+	{errRequestTooLarge, problemType{http.StatusRequestEntityTooLarge, "request-too-large", "Request too large"}},
+	{errInvalidRequest, problemType{http.StatusBadRequest, "invalid-request", "Invalid request"}},
+}
+
+// Problema de los errores de validacion de los campos del body
+var validationProblem = problemType{http.StatusBadRequest, "validation-failed", "Validation failed"}
+
+// Problema de los errores que no se esperan, el detalle no se envia al cliente
+var internalProblem = problemType{http.StatusInternalServerError, "internal", "Internal server error"}
+
+// Metodo para crear un problema de este tipo
+func (p problemType) problem(detail string) response.Problem {
+	return response.Problem{
+		Type:   problemTypeBase + p.slug,
+		Title:  p.title,
+		Status: p.status,
+		Detail: detail,
+	}
+}
+
+// Funcion para crear el error de un request invalido
+func invalidRequest(detail string) error {
+	return fmt.Errorf("%w: %s", errInvalidRequest, detail)
+}
+
+// Funcion para crear el error de un body que no se pudo leer o decodificar
+// Si el body supera el limite de LimitBody el error es errRequestTooLarge
+func bodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return fmt.Errorf("%w: the limit is %d bytes", errRequestTooLarge, tooLarge.Limit)
+	}
+	return invalidRequest("invalid request body")
+}
+
+// Funcion para crear el error de un campo invalido del body
+func invalidField(field string, detail string) error {
+	return &tools.FieldError{Field: field, Msg: detail}
+}
+
+// Funcion para crear el problema que corresponde a un error
+func newProblem(err error) (problem response.Problem) {
+	//Los errores de validacion de los campos se envian todos juntos
+	if fields := fieldErrors(err); len(fields) > 0 {
+		problem = validationProblem.problem("one or more fields are invalid")
+		for _, field := range fields {
+			problem.Errors = append(problem.Errors, response.FieldProblem{Field: field.Field, Detail: field.Msg})
+		}
+		return
+	}
+
+	for _, mapping := range problemTypes {
+		if errors.Is(err, mapping.err) {
+			problem = mapping.problem(err.Error())
+			return
+		}
+	}
+
+	problem = internalProblem.problem("internal server error")
+	return
+}
+
+// Funcion para obtener todos los errores de campos que contiene un error, tambien los de errors.Join
+func fieldErrors(err error) (fields []*tools.FieldError) {
+	switch wrapped := err.(type) {
+	case *tools.FieldError:
+		fields = append(fields, wrapped)
+	case interface{ Unwrap() []error }:
+		for _, inner := range wrapped.Unwrap() {
+			fields = append(fields, fieldErrors(inner)...)
+		}
+	case interface{ Unwrap() error }:
+		fields = fieldErrors(wrapped.Unwrap())
+	}
+	return
+}
+
+// Funcion para enviar un error como problem+json, la instancia es la ruta del request
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	problem := newProblem(err)
+	problem.Instance = r.URL.Path
+	response.ProblemJSON(w, problem)
+}
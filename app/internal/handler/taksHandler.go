@@ -1,12 +1,15 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Taks/internal"
 	"github.com/Taks/internal/tools"
@@ -22,17 +25,245 @@ type TaskHandler struct {
 
 // Se crea una estructura para almacenar las tareas en forma de requests
 type TaskRequest struct {
-	Tittle      string `json:"tittle"`
-	Description string `json:"description"`
-	Done        bool   `json:"done"`
+	Tittle      string     `json:"tittle"`
+	Description string     `json:"description"`
+	Done        bool       `json:"done"`
+	StartAt     *time.Time `json:"start_at"`
+	DueAt       *time.Time `json:"due_at"`
+	Priority    string     `json:"priority"`
+	Tags        []string   `json:"tags"`
+	ParentID    *int       `json:"parent_id"`
+	BlockedBy   []int      `json:"blocked_by"`
+	Recurrence  string     `json:"recurrence"`
 }
 
 // Se crea una estructura para almacenar las tareas en forma de JSON
 type TaskResponse struct {
-	ID          int    `json:"id"`
-	Tittle      string `json:"tittle"`
-	Description string `json:"description"`
-	Done        bool   `json:"done"`
+	ID          int                  `json:"id"`
+	Tittle      string               `json:"tittle"`
+	Description string               `json:"description"`
+	Done        bool                 `json:"done"`
+	StartAt     *time.Time           `json:"start_at,omitempty"`
+	DueAt       *time.Time           `json:"due_at,omitempty"`
+	Priority    internal.Priority    `json:"priority"`
+	Tags        []string             `json:"tags,omitempty"`
+	ParentID    *int                 `json:"parent_id,omitempty"`
+	BlockedBy   []int                `json:"blocked_by,omitempty"`
+	Blocked     bool                 `json:"blocked"`
+	Recurrence  *internal.Recurrence `json:"recurrence,omitempty"`
+	Version     int                  `json:"version"`
+	CreatedAt   *time.Time           `json:"created_at,omitempty"`
+	UpdatedAt   *time.Time           `json:"updated_at,omitempty"`
+	DeletedAt   *time.Time           `json:"deleted_at,omitempty"`
+}
+
+// Se crea una estructura para enviar una tarea con todas sus subtareas en forma de JSON
+type TaskTreeResponse struct {
+	TaskResponse
+	Children []TaskTreeResponse `json:"children"`
+}
+
+// Se crea una estructura para enviar las etiquetas con su cantidad de tareas en forma de JSON
+type TagResponse struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// Se crea una estructura para enviar el cambio de un campo en forma de JSON
+type FieldChangeResponse struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// Se crea una estructura para enviar una entrada del historial en forma de JSON
+type AuditEntryResponse struct {
+	ID       int                   `json:"id"`
+	TaskID   int                   `json:"task_id"`
+	Revision int                   `json:"revision"`
+	Actor    string                `json:"actor"`
+	At       time.Time             `json:"at"`
+	Op       string                `json:"op"`
+	Changes  []FieldChangeResponse `json:"changes"`
+}
+
+// Se crea una estructura para enviar una revision de una tarea en forma de JSON
+type RevisionResponse struct {
+	Revision int          `json:"revision"`
+	Actor    string       `json:"actor"`
+	At       time.Time    `json:"at"`
+	Op       string       `json:"op"`
+	Task     TaskResponse `json:"task"`
+}
+
+// Se crea una estructura para enviar un cambio del registro de cambios en forma de JSON
+// Task es el estado de la tarea despues del cambio, no se envia si la tarea se elimino definitivamente
+type ChangeResponse struct {
+	Cursor string        `json:"cursor"`
+	Type   string        `json:"type"`
+	TaskID int           `json:"task_id"`
+	At     time.Time     `json:"at"`
+	Task   *TaskResponse `json:"task,omitempty"`
+}
+
+// Funcion para crear la respuesta JSON de una tarea
+func newTaskResponse(task internal.Task) TaskResponse {
+	return TaskResponse{
+		ID:          task.ID,
+		Tittle:      task.Tittle,
+		Description: task.Description,
+		Done:        task.Done,
+		StartAt:     task.StartAt,
+		DueAt:       task.DueAt,
+		Priority:    task.Priority,
+		Tags:        task.Tags,
+		ParentID:    task.ParentID,
+		BlockedBy:   task.BlockedBy,
+		Blocked:     task.Blocked,
+		Recurrence:  task.Recurrence,
+		Version:     task.Version,
+		CreatedAt:   optionalTime(task.CreatedAt),
+		UpdatedAt:   optionalTime(task.UpdatedAt),
+		DeletedAt:   task.DeletedAt,
+	}
+}
+
+// Funcion para enviar una fecha que puede no conocerse, el valor cero no se envia
+func optionalTime(date time.Time) *time.Time {
+	if date.IsZero() {
+		return nil
+	}
+	return &date
+}
+
+// Funcion para crear la respuesta JSON de una entrada del historial
+func newAuditEntryResponse(entry internal.AuditEntry) AuditEntryResponse {
+	changes := make([]FieldChangeResponse, 0, len(entry.Changes))
+	for _, change := range entry.Changes {
+		changes = append(changes, FieldChangeResponse{
+			Field:  change.Field,
+			Before: change.Before,
+			After:  change.After,
+		})
+	}
+
+	return AuditEntryResponse{
+		ID:       entry.ID,
+		TaskID:   entry.TaskID,
+		Revision: entry.Revision,
+		Actor:    entry.Actor,
+		At:       entry.At,
+		Op:       entry.Op,
+		Changes:  changes,
+	}
+}
+
+// Funcion para crear la respuesta JSON de una revision, la entrada debe tener el estado de la tarea
+func newRevisionResponse(entry internal.AuditEntry) RevisionResponse {
+	return RevisionResponse{
+		Revision: entry.Revision,
+		Actor:    entry.Actor,
+		At:       entry.At,
+		Op:       entry.Op,
+		Task:     newTaskResponse(*entry.Task),
+	}
+}
+
+// Funcion para crear la respuesta JSON de un cambio
+func newChangeResponse(change internal.Change) ChangeResponse {
+	res := ChangeResponse{
+		Cursor: change.Cursor,
+		Type:   change.Type,
+		TaskID: change.TaskID,
+		At:     change.At,
+	}
+	if change.Task != nil {
+		task := newTaskResponse(*change.Task)
+		res.Task = &task
+	}
+	return res
+}
+
+// Funcion para crear la respuesta JSON de un arbol de tareas
+func newTaskTreeResponse(tree internal.TaskTree) TaskTreeResponse {
+	children := make([]TaskTreeResponse, 0, len(tree.Children))
+	for _, child := range tree.Children {
+		children = append(children, newTaskTreeResponse(child))
+	}
+
+	return TaskTreeResponse{
+		TaskResponse: newTaskResponse(tree.Task),
+		Children:     children,
+	}
+}
+
+// Reglas de los campos del body de una tarea completa
+var taskRequestSchema = tools.Schema{
+	{Field: "tittle", Required: true, Rules: []tools.Rule{tools.String()}},
+	{Field: "description", Required: true, Rules: []tools.Rule{tools.String()}},
+	{Field: "done", Required: true, Rules: []tools.Rule{tools.Bool()}},
+	{Field: "start_at", Rules: []tools.Rule{tools.Time()}},
+	{Field: "due_at", Rules: []tools.Rule{tools.Time()}},
+	{Field: "priority", Rules: []tools.Rule{tools.String(), internal.PriorityRule()}},
+	{Field: "tags", Rules: []tools.Rule{tools.StringList()}},
+	{Field: "parent_id", Rules: []tools.Rule{tools.Integer()}},
+	{Field: "blocked_by", Rules: []tools.Rule{tools.IntList()}},
+	{Field: "recurrence", Rules: []tools.Rule{tools.String(), internal.RecurrenceRule()}},
+}
+
+// Funcion para decodificar el body de una tarea completa, valida los campos con taskRequestSchema
+// Si hay campos invalidos devuelve un tools.ValidationError con un tools.FieldError por cada uno
+func decodeTaskRequest(bytes []byte) (body TaskRequest, err error) {
+	// Paso 1: Decodificar el body y crear un map[string]any
+	bodyMap := map[string]any{}
+	if err = json.Unmarshal(bytes, &bodyMap); err != nil {
+		err = invalidRequest("invalid request body")
+		return
+	}
+
+	// Paso 2: Validar todos los campos juntos, asi se informan todos los errores en una sola respuesta
+	if err = taskRequestSchema.Validate(bodyMap); err != nil {
+		return
+	}
+
+	// Paso 3: Decodificar el JSON y asignarlo a la estructura TaskRequest
+	if err = json.Unmarshal(bytes, &body); err != nil {
+		err = invalidRequest("invalid request body")
+		return
+	}
+	return
+}
+
+// Funcion para crear una tarea a partir de un request, valida la prioridad y la regla de repeticion
+func newTaskFromRequest(body TaskRequest) (task internal.Task, err error) {
+	var invalid []error
+	priority, errPriority := internal.ParsePriority(body.Priority)
+	if errPriority != nil {
+		invalid = append(invalid, invalidField("priority", internal.MsgInvalidPriority))
+	}
+
+	recurrence, errRecurrence := internal.ParseRecurrence(body.Recurrence)
+	if errRecurrence != nil {
+		invalid = append(invalid, invalidField("recurrence", internal.MsgInvalidRecurrence))
+	}
+
+	if err = errors.Join(invalid...); err != nil {
+		return
+	}
+
+	task = internal.Task{
+		Tittle:      body.Tittle,
+		Description: body.Description,
+		Done:        body.Done,
+		StartAt:     body.StartAt,
+		DueAt:       body.DueAt,
+		Priority:    priority,
+		Tags:        body.Tags,
+		ParentID:    body.ParentID,
+		BlockedBy:   body.BlockedBy,
+		Recurrence:  recurrence,
+	}
+	return
 }
 
 // Funcion para inicializar el handler de tareas
@@ -43,6 +274,21 @@ func NewTaskHandler(sv internal.TaskService) *TaskHandler {
 	}
 }
 
+// Tamaño maximo del body de un request en bytes, es un tope para no leer bodies enormes
+// Los largos del titulo y la descripcion los valida el servicio con sus reglas
+const RequestMaxBytes = 1 << 20
+
+// Funcion para limitar el body de todos los requests a maxBytes bytes
+// Leer un body mas grande falla con un *http.MaxBytesError y el handler responde 413
+func LimitBody(maxBytes int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // --------------------- HANDLER DE CREATE ---------------------
 
 // Metodo para crear una nueva tarea
@@ -64,83 +310,39 @@ func (t *TaskHandler) CreateTask() http.HandlerFunc {
 		//Paso 0: Leer el body
 		bytes, err := io.ReadAll(r.Body)
 		if err != nil {
-			response.ResponseJSON(w, http.StatusBadRequest, map[string]any{"message": "invalid request body"})
-			return
-		}
-
-		// Paso 1: Decodificar el body y crear un map[string]any
-		bodyMap := map[string]any{}
-		if err := json.Unmarshal(bytes, &bodyMap); err != nil {
-			response.ResponseJSON(w, http.StatusBadRequest, map[string]any{
-				"message": "invalid request body",
-			})
-			return
-		}
-
-		//Pso 2: Validar que todos los campos esten completos
-		if err := tools.CheckFieldExistance(bodyMap, "tittle", "description", "done"); err != nil {
-			var fieldError *tools.FieldError
-			if errors.As(err, &fieldError) {
-				response.ResponseJSON(w, http.StatusBadRequest, map[string]any{
-					"message": fmt.Sprintf("%s is required", fieldError.Field),
-				})
-				return
-			}
-
-			response.ResponseJSON(w, http.StatusInternalServerError, map[string]any{
-				"message": "internal server error",
-			})
+			writeError(w, r, bodyError(err))
 			return
 		}
 
-		// Paso 3: Crear una instancia de TaskRequest para almacenar los datos del cuerpo JSON
-		var body TaskRequest
-
-		// Paso 4: Decodificar el JSON del cuerpo de la solicitud y asignarlo a la estructura TaskRequest
-		if err := json.Unmarshal(bytes, &body); err != nil {
-
-			//Si ocurre un error decodificando el JSON, enviar una respuesta HTTP con error
-			response.ResponseJSON(w, http.StatusBadRequest, map[string]any{
-				"--Message": "invalid request body",
-			})
+		// Paso 1: Decodificar el body validando que todos los campos esten completos
+		body, err := decodeTaskRequest(bytes)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		//process
-		// Paso 5: Crear una instancia de Task a partir de los datos recibidos
-		task := internal.Task{
-			Tittle:      body.Tittle,
-			Description: body.Description,
-			Done:        body.Done,
+		// Paso 2: Crear una instancia de Task a partir de los datos recibidos
+		// Si no se envian la prioridad o la regla de repeticion, la tarea no tiene prioridad y no se repite
+		task, err := newTaskFromRequest(body)
+		if err != nil {
+			writeError(w, r, err)
+			return
 		}
 
-		// Paso 6: Agregar la tarea al mapa de tareas, usando el metodo Save del repositorio
+		// Paso 3: Agregar la tarea al mapa de tareas, usando el metodo Save del repositorio
 		// Al Save se le pasa la tarea con los datos recibidos y en el repository se gestiona el guarda en el mapa y el id
-		if err := t.sv.Save(&task); err != nil {
-
-			// Se gestiona que tipo de error se produce y se envia la respuesta correspondiente
-			switch {
-			case errors.Is(err, internal.ErrTaskDuplicated):
-				response.ResponseJSON(w, http.StatusConflict, map[string]any{"message": "task already exists"})
-			case errors.Is(err, internal.ErrTaskInvalidField):
-				response.ResponseJSON(w, http.StatusBadRequest, map[string]any{"message": "invalid field"})
-			default:
-				response.ResponseJSON(w, http.StatusInternalServerError, map[string]any{"message": "internal server error"})
-			}
+		if err := t.sv.WithActor(requestActor(r)).Save(&task); err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		//response
 
-		// Paso 7: Crear  una tarea en formatoJSON que se va a enviar como respuesta del handler
-		data := TaskResponse{
-			ID:          task.ID,
-			Tittle:      task.Tittle,
-			Description: task.Description,
-			Done:        task.Done,
-		}
+		// Paso 4: Crear  una tarea en formatoJSON que se va a enviar como respuesta del handler
+		data := newTaskResponse(task)
 
-		// Paso 8: Enviar una respuesta HTTP exitosa (201 Created) junto con los datos de la tarea creada
+		// Paso 5: Enviar una respuesta HTTP exitosa (201 Created) junto con los datos de la tarea creada
 		response.ResponseJSON(w, http.StatusCreated, map[string]any{
 			"message": "task created successfully",
 			"data":    data,
@@ -156,78 +358,56 @@ func (t *TaskHandler) UpdateTask() http.HandlerFunc {
 		// Paso 1: Leer el id de la URL y convertirlo a entero
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
-			//Otra forma de enviar una respuesta HTTP con error en formato texto
-			response.Text(w, http.StatusBadRequest, "invalid id")
+			writeError(w, r, invalidRequest("invalid id"))
 			return
 		}
 
-		// Paso 2: Leer el cuerpo de la solicitud y decodificarlo
-		bytes, err := io.ReadAll(r.Body)
+		// Leer la version esperada del header If-Match, sin header no se verifica la version
+		version, err := ifMatchVersion(r)
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid request body")
+			writeError(w, r, err)
 			return
 		}
 
-		// Paso 3: Decodificar el cuerpo y crear un map[string]any
-		bodyMap := map[string]any{}
-		if err := json.Unmarshal(bytes, &bodyMap); err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid request body")
+		// Paso 2: Leer el cuerpo de la solicitud
+		bytes, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, r, bodyError(err))
 			return
 		}
 
-		// Paso 4: Validar que todos los campos esten completos
-		if err := tools.CheckFieldExistance(bodyMap, "tittle", "description", "done"); err != nil {
-			var fieldError *tools.FieldError
-			if errors.As(err, &fieldError) {
-				response.Text(w, http.StatusBadRequest, fmt.Sprintf("%s is required", fieldError.Field))
-				return
-			}
-
-			response.Text(w, http.StatusInternalServerError, "internal server error")
+		// Paso 3: Decodificar el cuerpo validando que todos los campos esten completos
+		body, err := decodeTaskRequest(bytes)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
-		// Paso 5: Decodificar el JSON del cuerpo de la solicitud y asignarlo a la estructura TaskRequestBody
-		var body TaskRequest
-		if err := json.Unmarshal(bytes, &body); err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid request body")
+		// process
+		// Paso 4: Crear una instancia de Task a partir de los datos recibidos
+		// Si no se envian la prioridad o la regla de repeticion, la tarea queda sin prioridad y deja de repetirse
+		task, err := newTaskFromRequest(body)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
+		task.ID = id
+		task.Version = version
 
-		// process
-		//Paso 6: Crear una instancia de Task a partir de los datos recibidos
-		task := internal.Task{
-			ID:          id,
-			Tittle:      body.Tittle,
-			Description: body.Description,
-			Done:        body.Done,
-		}
-
-		// Paso 7: Actualizar la tarea en el mapa de tareas, usando el metodo Update del repositorio
-		if err := t.sv.Update(task); err != nil {
-			switch {
-			case errors.Is(err, internal.ErrTaskNotFound):
-				response.Text(w, http.StatusNotFound, "task not found")
-			case errors.Is(err, internal.ErrTaskInvalidField):
-				response.Text(w, http.StatusBadRequest, "task is invalid")
-			case errors.Is(err, internal.ErrTaskDuplicated):
-				response.Text(w, http.StatusConflict, "task already exists")
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
+		// Paso 5: Actualizar la tarea en el mapa de tareas, usando el metodo Update del servicio
+		// El servicio devuelve la tarea guardada con su nueva version
+		task, err = t.sv.WithActor(requestActor(r)).Update(task)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		// response
-		// Paso 8: Crear  una tarea en formatoJSON que se va a enviar como respuesta del handler
-		data := TaskResponse{
-			ID:          task.ID,
-			Tittle:      task.Tittle,
-			Description: task.Description,
-			Done:        task.Done,
-		}
+		// Paso 6: Crear  una tarea en formatoJSON que se va a enviar como respuesta del handler
+		data := newTaskResponse(task)
 
-		// Paso 9: Enviar una respuesta HTTP exitosa (200 OK) junto con los datos de la tarea actualizada
+		// Paso 7: Enviar una respuesta HTTP exitosa (200 OK) junto con los datos de la tarea actualizada y su version
+		setValidators(w, task)
 		response.ResponseJSON(w, http.StatusOK, map[string]any{
 			"message": "task updated",
 			"data":    data,
@@ -242,36 +422,47 @@ func (d *TaskHandler) UpdatePartialTask() http.HandlerFunc {
 		// Paso 1: Leer el id de la URL y convertirlo a entero
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid id")
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+
+		// Leer la version esperada del header If-Match, sin header no se verifica la version
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		// Paso 2: Leer el cuerpo de la solicitud y decodificarlo
 		bodyMap := make(map[string]any)
 		if err := request.RequestJSON(r, &bodyMap); err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid request body")
+			writeError(w, r, bodyError(err))
+			return
+		}
+
+		// Convertir los campos en una actualizacion parcial, rechaza los campos desconocidos y los tipos invalidos
+		patch, err := internal.ParseTaskPatch(bodyMap)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		// process
-		// Paso 3: Actualizar la tarea en el mapa de tareas, usando el metodo UpdatePartial del repositorio
-		if err := d.sv.UpdatePartial(id, bodyMap); err != nil {
-			switch {
-			case errors.Is(err, internal.ErrTaskNotFound):
-				response.Text(w, http.StatusNotFound, "task not found")
-			case errors.Is(err, internal.ErrTaskInvalidField):
-				response.Text(w, http.StatusBadRequest, "task is invalid")
-			case errors.Is(err, internal.ErrTaskDuplicated):
-				response.Text(w, http.StatusConflict, "task already exists")
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
+		// Paso 3: Actualizar la tarea en el mapa de tareas, usando el metodo UpdatePartial del servicio
+		// El servicio devuelve la tarea guardada con su nueva version
+		task, err := d.sv.WithActor(requestActor(r)).UpdatePartial(id, version, patch)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		// response
-		// Paso 4: Enviar una respuesta HTTP exitosa (200 OK) junto con un mensaje de tarea actualizada
-		response.Text(w, http.StatusOK, "task updated")
+		// Paso 4: Enviar una respuesta HTTP exitosa (200 OK) junto con los datos de la tarea actualizada y su version
+		setValidators(w, task)
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "task updated",
+			"data":    newTaskResponse(task),
+		})
 	}
 }
 
@@ -282,28 +473,122 @@ func (d *TaskHandler) DeleteTask() http.HandlerFunc {
 		// Paso 1: Leer el id de la URL y convertirlo a entero
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid id")
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+
+		// Leer la version esperada del header If-Match, sin header no se verifica la version
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// Paso 2: Elegir que pasa con las subtareas, por defecto pasan a ser hijas del padre de la tarea
+		sv := d.sv.WithActor(requestActor(r))
+		remove := sv.Delete
+		switch r.URL.Query().Get("children") {
+		case "", "reparent":
+		case "cascade":
+			remove = sv.DeleteCascade
+		default:
+			writeError(w, r, invalidRequest("invalid children"))
 			return
 		}
 
 		// process
-		// Paso 2: Eliminar la tarea del mapa de tareas, usando el metodo Delete del repositorio
-		if err := d.sv.Delete(id); err != nil {
-			switch {
-			case errors.Is(err, internal.ErrTaskNotFound):
-				response.Text(w, http.StatusNotFound, "task not found")
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
+		// Paso 3: Eliminar la tarea del mapa de tareas, usando el metodo Delete o DeleteCascade del servicio
+		if err := remove(id, version); err != nil {
+			writeError(w, r, err)
 			return
 		}
 
 		// response
-		// Paso 3: Enviar una respuesta HTTP exitosa (204 No Content) sin ningun contenido
+		// Paso 4: Enviar una respuesta HTTP exitosa (204 No Content) sin ningun contenido
 		response.Text(w, http.StatusNoContent, "Tarea eliminada con exito")
 	}
 }
 
+// --------------------- HANDLER DE LISTTRASH ---------------------
+func (d *TaskHandler) ListTrash() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// process
+		// Paso 1: Obtener las tareas de la papelera, usando el metodo ListTrash del servicio
+		tasks, err := d.sv.ListTrash()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 2: Crear las tareas en formato JSON, cada una con su fecha de eliminacion
+		data := make([]TaskResponse, 0, len(tasks))
+		for _, task := range tasks {
+			data = append(data, newTaskResponse(task))
+		}
+
+		// Paso 3: Enviar una respuesta HTTP exitosa (200 OK) junto con las tareas
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "trash found",
+			"data":    data,
+		})
+	}
+}
+
+// --------------------- HANDLER DE RESTORE ---------------------
+func (d *TaskHandler) RestoreTask() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer el id de la URL y convertirlo a entero
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+
+		// process
+		// Paso 2: Sacar la tarea de la papelera, usando el metodo Restore del servicio
+		// El servicio devuelve la tarea restaurada con su nueva version
+		task, err := d.sv.WithActor(requestActor(r)).Restore(id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 3: Enviar una respuesta HTTP exitosa (200 OK) junto con los datos de la tarea y su version
+		setValidators(w, task)
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "task restored",
+			"data":    newTaskResponse(task),
+		})
+	}
+}
+
+// --------------------- HANDLER DE PURGE ---------------------
+func (d *TaskHandler) PurgeTask() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer el id de la URL y convertirlo a entero
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+
+		// process
+		// Paso 2: Eliminar definitivamente la tarea de la papelera, usando el metodo Purge del servicio
+		if err := d.sv.WithActor(requestActor(r)).Purge(id); err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 3: Enviar una respuesta HTTP exitosa (204 No Content) sin ningun contenido
+		response.Text(w, http.StatusNoContent, "Tarea eliminada definitivamente")
+	}
+}
+
 // --------------------- HANDLER DE GETBYID ---------------------
 func (d *TaskHandler) GetTaskByID() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -311,7 +596,7 @@ func (d *TaskHandler) GetTaskByID() http.HandlerFunc {
 		// Paso 1: Leer el id de la URL y convertirlo a entero
 		id, err := strconv.Atoi(chi.URLParam(r, "id"))
 		if err != nil {
-			response.Text(w, http.StatusBadRequest, "invalid id")
+			writeError(w, r, invalidRequest("invalid id"))
 			return
 		}
 
@@ -319,28 +604,736 @@ func (d *TaskHandler) GetTaskByID() http.HandlerFunc {
 		// Paso 2: Obtener la tarea del mapa de tareas, usando el metodo GetByID del repositorio
 		task, err := d.sv.GetByID(id)
 		if err != nil {
-			switch {
-			case errors.Is(err, internal.ErrTaskNotFound):
-				response.Text(w, http.StatusNotFound, "task not found")
-			default:
-				response.Text(w, http.StatusInternalServerError, "internal server error")
-			}
+			writeError(w, r, err)
 			return
 		}
 
 		// response
 		// Paso 3: Crear  una tarea en formatoJSON que se va a enviar como respuesta del handler
-		data := TaskResponse{
-			ID:          task.ID,
-			Tittle:      task.Tittle,
-			Description: task.Description,
-			Done:        task.Done,
+		data := newTaskResponse(task)
+
+		// Paso 4: Enviar la version y la fecha de modificacion, si el cliente ya tiene esta version
+		// se responde 304 Not Modified sin cuerpo
+		setValidators(w, task)
+		if notModified(r, task) {
+			w.WriteHeader(http.StatusNotModified)
+			return
 		}
 
-		// Paso 4: Enviar una respuesta HTTP exitosa (200 OK) junto con los datos de la tarea
+		// Paso 5: Enviar una respuesta HTTP exitosa (200 OK) junto con los datos de la tarea
 		response.ResponseJSON(w, http.StatusOK, map[string]any{
 			"message": "task found",
 			"data":    data,
 		})
 	}
 }
+
+// --------------------- HANDLER DE LIST ---------------------
+func (d *TaskHandler) ListTasks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer los parametros de la consulta
+		query, err := parseTaskQuery(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// process
+		// Paso 2: Obtener las tareas que cumplen con la consulta, usando el metodo List del servicio
+		page, err := d.sv.List(query)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 3: Crear las tareas en formato JSON que se van a enviar como respuesta del handler
+		data := make([]TaskResponse, 0, len(page.Tasks))
+		for _, task := range page.Tasks {
+			data = append(data, newTaskResponse(task))
+		}
+
+		// Paso 4: Enviar una respuesta HTTP exitosa (200 OK) junto con las tareas y los datos de paginacion
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message":     "tasks found",
+			"data":        data,
+			"total":       page.Total,
+			"next_cursor": page.NextCursor,
+		})
+	}
+}
+
+// Funcion para leer los parametros de la consulta de tareas desde la URL
+//
+// Parametros soportados: done, search, due_from, due_to, overdue, tags_any, tags_all, parent_id, sort,
+// order (asc o desc), limit, offset y cursor
+// Las fechas tienen el formato RFC 3339 y las etiquetas se separan con comas
+func parseTaskQuery(r *http.Request) (query internal.TaskQuery, err error) {
+	values := r.URL.Query()
+
+	if value := values.Get("done"); value != "" {
+		done, errParse := strconv.ParseBool(value)
+		if errParse != nil {
+			err = invalidRequest("invalid done")
+			return
+		}
+		query.Done = &done
+	}
+
+	query.Search = values.Get("search")
+
+	if value := values.Get("due_from"); value != "" {
+		dueFrom, errParse := time.Parse(time.RFC3339, value)
+		if errParse != nil {
+			err = invalidRequest("invalid due_from")
+			return
+		}
+		query.DueFrom = &dueFrom
+	}
+
+	if value := values.Get("due_to"); value != "" {
+		dueTo, errParse := time.Parse(time.RFC3339, value)
+		if errParse != nil {
+			err = invalidRequest("invalid due_to")
+			return
+		}
+		query.DueTo = &dueTo
+	}
+
+	if value := values.Get("overdue"); value != "" {
+		overdue, errParse := strconv.ParseBool(value)
+		if errParse != nil {
+			err = invalidRequest("invalid overdue")
+			return
+		}
+		if overdue {
+			now := time.Now()
+			query.OverdueAt = &now
+		}
+	}
+
+	if value := values.Get("tags_any"); value != "" {
+		query.TagsAny = strings.Split(value, ",")
+	}
+
+	if value := values.Get("tags_all"); value != "" {
+		query.TagsAll = strings.Split(value, ",")
+	}
+
+	if value := values.Get("parent_id"); value != "" {
+		parentID, errParse := strconv.Atoi(value)
+		if errParse != nil {
+			err = invalidRequest("invalid parent_id")
+			return
+		}
+		query.ParentID = &parentID
+	}
+
+	query.SortBy = values.Get("sort")
+	query.Cursor = values.Get("cursor")
+
+	switch values.Get("order") {
+	case "", "asc":
+	case "desc":
+		query.SortDesc = true
+	default:
+		err = invalidRequest("invalid order")
+		return
+	}
+
+	if value := values.Get("limit"); value != "" {
+		query.Limit, err = strconv.Atoi(value)
+		if err != nil || query.Limit < 0 {
+			err = invalidRequest("invalid limit")
+			return
+		}
+	}
+
+	if value := values.Get("offset"); value != "" {
+		query.Offset, err = strconv.Atoi(value)
+		if err != nil || query.Offset < 0 {
+			err = invalidRequest("invalid offset")
+			return
+		}
+	}
+
+	return
+}
+
+// --------------------- HANDLER DE HISTORY ---------------------
+func (d *TaskHandler) GetHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer el id de la URL y la paginacion de la consulta
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+
+		query := internal.AuditQuery{TaskID: id}
+		values := r.URL.Query()
+		if value := values.Get("limit"); value != "" {
+			query.Limit, err = strconv.Atoi(value)
+			if err != nil || query.Limit < 0 {
+				writeError(w, r, invalidRequest("invalid limit"))
+				return
+			}
+		}
+		if value := values.Get("offset"); value != "" {
+			query.Offset, err = strconv.Atoi(value)
+			if err != nil || query.Offset < 0 {
+				writeError(w, r, invalidRequest("invalid offset"))
+				return
+			}
+		}
+
+		// process
+		// Paso 2: Obtener el historial, usando el metodo History del servicio
+		// El historial se conserva aunque la tarea este en la papelera o se haya eliminado definitivamente
+		page, err := d.sv.History(query)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// Paso 3: Una tarea que nunca existio no tiene historial
+		if page.Total == 0 {
+			writeError(w, r, internal.ErrTaskNotFound)
+			return
+		}
+
+		// response
+		// Paso 4: Crear las entradas en formato JSON, de la mas antigua a la mas reciente
+		data := make([]AuditEntryResponse, 0, len(page.Entries))
+		for _, entry := range page.Entries {
+			data = append(data, newAuditEntryResponse(entry))
+		}
+
+		// Paso 5: Enviar una respuesta HTTP exitosa (200 OK) junto con las entradas y el total
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "history found",
+			"data":    data,
+			"total":   page.Total,
+		})
+	}
+}
+
+// --------------------- HANDLER DE GETREVISION ---------------------
+func (d *TaskHandler) GetRevision() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer el id y la revision de la URL
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+
+		revision, err := strconv.Atoi(chi.URLParam(r, "revision"))
+		if err != nil || revision < 1 {
+			writeError(w, r, invalidRequest("invalid revision"))
+			return
+		}
+
+		// process
+		// Paso 2: Obtener la revision, usando el metodo Revision del servicio
+		entry, err := d.sv.Revision(id, revision)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 3: Enviar una respuesta HTTP exitosa (200 OK) junto con la tarea como era en esa revision
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "revision found",
+			"data":    newRevisionResponse(entry),
+		})
+	}
+}
+
+// --------------------- HANDLER DE GETREVISIONAT ---------------------
+func (d *TaskHandler) GetRevisionAt() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer el id de la URL y el instante de la consulta
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+
+		at, err := time.Parse(time.RFC3339, r.URL.Query().Get("at"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid at"))
+			return
+		}
+
+		// process
+		// Paso 2: Obtener la revision vigente en ese instante, usando el metodo RevisionAt del servicio
+		entry, err := d.sv.RevisionAt(id, at)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 3: Enviar una respuesta HTTP exitosa (200 OK) junto con la tarea como era en ese instante
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "revision found",
+			"data":    newRevisionResponse(entry),
+		})
+	}
+}
+
+// --------------------- HANDLER DE REVERT ---------------------
+func (d *TaskHandler) RevertTask() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer el id y la revision de la URL
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+
+		revision, err := strconv.Atoi(chi.URLParam(r, "revision"))
+		if err != nil || revision < 1 {
+			writeError(w, r, invalidRequest("invalid revision"))
+			return
+		}
+
+		// Leer la version esperada del header If-Match, sin header no se verifica la version
+		version, err := ifMatchVersion(r)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// process
+		// Paso 2: Volver la tarea a la revision, usando el metodo Revert del servicio
+		// El servicio devuelve la tarea con los datos de la revision y su nueva version
+		task, err := d.sv.WithActor(requestActor(r)).Revert(id, revision, version)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 3: Enviar una respuesta HTTP exitosa (200 OK) junto con los datos de la tarea y su version
+		setValidators(w, task)
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "task reverted",
+			"data":    newTaskResponse(task),
+		})
+	}
+}
+
+// --------------------- HANDLER DE GETCHANGES ---------------------
+func (d *TaskHandler) GetChanges() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer el cursor, el limite y el tiempo de espera de la consulta
+		var err error
+		values := r.URL.Query()
+		query := internal.ChangeQuery{Cursor: values.Get("since")}
+		if value := values.Get("limit"); value != "" {
+			query.Limit, err = strconv.Atoi(value)
+			if err != nil || query.Limit < 0 || query.Limit > internal.ChangeMaxLimit {
+				writeError(w, r, invalidRequest("invalid limit"))
+				return
+			}
+		}
+		if value := values.Get("wait"); value != "" {
+			query.Wait, err = time.ParseDuration(value)
+			if err != nil || query.Wait < 0 {
+				writeError(w, r, invalidRequest("invalid wait"))
+				return
+			}
+		}
+
+		// process
+		// Paso 2: Obtener los cambios, usando el metodo Changes del servicio
+		// Si no hay cambios nuevos la consulta espera hasta el tiempo indicado o hasta que el cliente se desconecte
+		page, err := d.sv.Changes(r.Context(), query)
+		if err != nil {
+			//Si el cliente ya no espera la respuesta no se envia nada
+			if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+				writeError(w, r, err)
+			}
+			return
+		}
+
+		// response
+		// Paso 3: Crear los cambios en formato JSON, en el orden en que se hicieron
+		data := make([]ChangeResponse, 0, len(page.Changes))
+		for _, change := range page.Changes {
+			data = append(data, newChangeResponse(change))
+		}
+
+		// Paso 4: Enviar una respuesta HTTP exitosa (200 OK) junto con los cambios y el cursor para seguir leyendo
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "changes found",
+			"data":    data,
+			"cursor":  page.Cursor,
+		})
+	}
+}
+
+// Cada cuanto se envia un comentario por el stream de eventos para que no se cierre la conexion si no hay cambios
+const eventsKeepAlive = 15 * time.Second
+
+// --------------------- HANDLER DE STREAMEVENTS ---------------------
+func (d *TaskHandler) StreamEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer los filtros de la consulta, ?id= se puede repetir o separar con comas
+		var filter internal.ChangeFilter
+		values := r.URL.Query()
+		for _, value := range values["id"] {
+			for _, part := range strings.Split(value, ",") {
+				id, err := strconv.Atoi(strings.TrimSpace(part))
+				if err != nil {
+					writeError(w, r, invalidRequest("invalid id"))
+					return
+				}
+				filter.TaskIDs = append(filter.TaskIDs, id)
+			}
+		}
+		if value := values.Get("done"); value != "" {
+			done, err := strconv.ParseBool(value)
+			if err != nil {
+				writeError(w, r, invalidRequest("invalid done"))
+				return
+			}
+			filter.Done = &done
+		}
+
+		// Paso 2: El stream necesita enviar cada evento apenas se escribe
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			writeError(w, r, errors.New("streaming unsupported"))
+			return
+		}
+
+		// process
+		// Paso 3: Suscribirse a los cambios, el navegador envia Last-Event-ID al reconectarse para recibir los que se perdio
+		subscription, err := d.sv.Subscribe(r.Context(), r.Header.Get("Last-Event-ID"), filter)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 4: Enviar los encabezados del stream
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.WriteHeader(http.StatusOK)
+
+		// Paso 5: Si se perdieron cambios que ya no se conservan el cliente debe volver a leer las tareas
+		if subscription.Gap {
+			fmt.Fprint(w, "event: reset\ndata: {}\n\n")
+		}
+		flusher.Flush()
+
+		// Paso 6: Enviar cada cambio como un evento con el cursor como ID, hasta que el cliente se desconecte
+		// Si la suscripcion se cierra porque el cliente no lee a tiempo, se termina el stream y el cliente se reconecta
+		keepAlive := time.NewTicker(eventsKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			select {
+			case change, ok := <-subscription.Changes:
+				if !ok {
+					return
+				}
+				data, err := json.Marshal(newChangeResponse(change))
+				if err != nil {
+					return
+				}
+				fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", change.Cursor, change.Type, data)
+			case <-keepAlive.C:
+				fmt.Fprint(w, ": keep-alive\n\n")
+			case <-r.Context().Done():
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// --------------------- HANDLER DE GETCHILDREN ---------------------
+func (d *TaskHandler) GetChildren() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer el id de la URL y convertirlo a entero
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+
+		// process
+		// Paso 2: Obtener las subtareas directas, usando el metodo GetChildren del servicio
+		children, err := d.sv.GetChildren(id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 3: Crear las subtareas en formato JSON
+		data := make([]TaskResponse, 0, len(children))
+		for _, child := range children {
+			data = append(data, newTaskResponse(child))
+		}
+
+		// Paso 4: Enviar una respuesta HTTP exitosa (200 OK) junto con las subtareas
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "children found",
+			"data":    data,
+		})
+	}
+}
+
+// --------------------- HANDLER DE GETSUBTREE ---------------------
+func (d *TaskHandler) GetSubtree() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer el id de la URL y convertirlo a entero
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+
+		// process
+		// Paso 2: Obtener la tarea con todas sus subtareas, usando el metodo GetSubtree del servicio
+		tree, err := d.sv.GetSubtree(id)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 3: Enviar una respuesta HTTP exitosa (200 OK) junto con el arbol de tareas anidado
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "subtree found",
+			"data":    newTaskTreeResponse(tree),
+		})
+	}
+}
+
+// --------------------- HANDLER DE ADDDEPENDENCY ---------------------
+func (d *TaskHandler) AddDependency() http.HandlerFunc {
+	return d.changeDependency(internal.TaskService.AddDependency, "dependency added")
+}
+
+// --------------------- HANDLER DE REMOVEDEPENDENCY ---------------------
+func (d *TaskHandler) RemoveDependency() http.HandlerFunc {
+	return d.changeDependency(internal.TaskService.RemoveDependency, "dependency removed")
+}
+
+// Funcion comun para agregar o quitar una dependencia de una tarea
+// change recibe el servicio con el actor de la solicitud
+func (d *TaskHandler) changeDependency(change func(sv internal.TaskService, id int, blockedBy int) (internal.Task, error), message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer el id de la tarea y el de la tarea que la bloquea de la URL
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+		blockedBy, err := strconv.Atoi(chi.URLParam(r, "blockedBy"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid dependency"))
+			return
+		}
+
+		// process
+		// Paso 2: Cambiar la dependencia de la tarea, usando el metodo del servicio
+		// El servicio devuelve la tarea con sus dependencias actualizadas y su nueva version
+		task, err := change(d.sv.WithActor(requestActor(r)), id, blockedBy)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 3: Enviar una respuesta HTTP exitosa (200 OK) junto con los datos de la tarea y su version
+		setValidators(w, task)
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": message,
+			"data":    newTaskResponse(task),
+		})
+	}
+}
+
+// --------------------- HANDLER DE NEXTTASKS ---------------------
+func (d *TaskHandler) NextTasks() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// process
+		// Paso 1: Obtener las tareas sin terminar en orden de dependencias, usando el metodo NextTasks del servicio
+		tasks, err := d.sv.NextTasks()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 2: Crear las tareas en formato JSON, las que no estan bloqueadas se pueden empezar ya
+		data := make([]TaskResponse, 0, len(tasks))
+		for _, task := range tasks {
+			data = append(data, newTaskResponse(task))
+		}
+
+		// Paso 3: Enviar una respuesta HTTP exitosa (200 OK) junto con las tareas ordenadas
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "tasks found",
+			"data":    data,
+		})
+	}
+}
+
+// --------------------- HANDLER DE ADDTAG ---------------------
+func (d *TaskHandler) AddTag() http.HandlerFunc {
+	return d.changeTag(internal.TaskService.AddTag, "tag added")
+}
+
+// --------------------- HANDLER DE REMOVETAG ---------------------
+func (d *TaskHandler) RemoveTag() http.HandlerFunc {
+	return d.changeTag(internal.TaskService.RemoveTag, "tag removed")
+}
+
+// Funcion comun para agregar o quitar una etiqueta de una tarea
+// change recibe el servicio con el actor de la solicitud
+func (d *TaskHandler) changeTag(change func(sv internal.TaskService, id int, tag string) (internal.Task, error), message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Leer el id y la etiqueta de la URL
+		id, err := strconv.Atoi(chi.URLParam(r, "id"))
+		if err != nil {
+			writeError(w, r, invalidRequest("invalid id"))
+			return
+		}
+		tag := chi.URLParam(r, "tag")
+
+		// process
+		// Paso 2: Cambiar la etiqueta de la tarea, usando el metodo del servicio
+		// El servicio devuelve la tarea con sus etiquetas actualizadas y su nueva version
+		task, err := change(d.sv.WithActor(requestActor(r)), id, tag)
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 3: Enviar una respuesta HTTP exitosa (200 OK) junto con los datos de la tarea y su version
+		setValidators(w, task)
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": message,
+			"data":    newTaskResponse(task),
+		})
+	}
+}
+
+// --------------------- HANDLER DE LISTTAGS ---------------------
+func (d *TaskHandler) ListTags() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// process
+		// Paso 1: Obtener las etiquetas, usando el metodo ListTags del servicio
+		tags, err := d.sv.ListTags()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+
+		// response
+		// Paso 2: Crear las etiquetas en formato JSON
+		data := make([]TagResponse, 0, len(tags))
+		for _, tag := range tags {
+			data = append(data, TagResponse{
+				Tag:   tag.Tag,
+				Count: tag.Count,
+			})
+		}
+
+		// Paso 3: Enviar una respuesta HTTP exitosa (200 OK) junto con las etiquetas
+		response.ResponseJSON(w, http.StatusOK, map[string]any{
+			"message": "tags found",
+			"data":    data,
+		})
+	}
+}
+
+// Funcion para leer quien hace la solicitud del header X-Actor, se registra en la auditoria
+// Sin header los cambios se registran como internal.AuditActorAnonymous
+func requestActor(r *http.Request) string {
+	actor := strings.TrimSpace(r.Header.Get("X-Actor"))
+	if actor == "" {
+		return internal.AuditActorAnonymous
+	}
+	return actor
+}
+
+// Funcion para enviar la version de una tarea en el header ETag y su fecha de modificacion en Last-Modified
+// Si no se conoce la fecha de modificacion no se envia Last-Modified
+// El repositorio tambien cambia la version y la fecha cuando cambia Blocked, asi cubren toda la respuesta
+func setValidators(w http.ResponseWriter, task internal.Task) {
+	w.Header().Set("ETag", strconv.Quote(strconv.Itoa(task.Version)))
+	if !task.UpdatedAt.IsZero() {
+		w.Header().Set("Last-Modified", task.UpdatedAt.Format(http.TimeFormat))
+	}
+}
+
+// Funcion para saber si el cliente ya tiene la version actual de una tarea
+//
+// If-None-Match usa la comparacion debil y acepta una lista de ETags o *. Si esta presente
+// If-Modified-Since se ignora, si no la tarea no cambio cuando su fecha de modificacion,
+// redondeada a segundos como en Last-Modified, no es posterior a la del header.
+func notModified(r *http.Request, task internal.Task) bool {
+	if value := r.Header.Get("If-None-Match"); value != "" {
+		etag := strconv.Quote(strconv.Itoa(task.Version))
+		for _, candidate := range strings.Split(value, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || task.UpdatedAt.IsZero() {
+		return false
+	}
+	return !task.UpdatedAt.Truncate(time.Second).After(since)
+}
+
+// Funcion para leer la version esperada del header If-Match
+//
+// Sin header o con * no se verifica la version y devuelve 0. If-Match usa la comparacion fuerte,
+// por eso un ETag debil (W/"1") o un valor que no es una version nunca coincide y devuelve error.
+func ifMatchVersion(r *http.Request) (version int, err error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return
+	}
+
+	unquoted, err := strconv.Unquote(value)
+	if err != nil || !strings.HasPrefix(value, `"`) {
+		err = internal.ErrTaskVersionConflict
+		return
+	}
+
+	version, err = strconv.Atoi(unquoted)
+	if err != nil || version < 1 {
+		version = 0
+		err = internal.ErrTaskVersionConflict
+		return
+	}
+	return
+}
@@ -1,21 +1,57 @@
 package handler_test
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/Taks/internal"
 	"github.com/Taks/internal/handler"
 	"github.com/Taks/internal/repository"
 	"github.com/Taks/internal/service"
+	"github.com/Taks/pkg/response"
 	"github.com/go-chi/chi"
 	"github.com/stretchr/testify/require"
 )
 
+// Funcion para crear un servicio sobre un TaskMap y una auditoria en memoria
+// Tambien devuelve el repositorio para preparar o revisar las tareas desde el test
+func newTestService(db map[int]internal.Task, lastID int) (sv *service.TaskService, rp *repository.TaskMap) {
+	rp = repository.NewTaskMap(db, lastID)
+	sv = service.NewTaskService(rp, repository.NewAuditMap())
+	return
+}
+
+// Auditoria que no puede guardar sus primeras entradas, para probar un cambio que se hace pero no se registra
+type failingAudit struct {
+	*repository.AuditMap
+
+	//Cantidad de entradas que todavia van a fallar
+	failures *int
+}
+
+// Metodo para guardar una entrada, falla mientras queden fallas
+func (a failingAudit) Append(entry *internal.AuditEntry) error {
+	if *a.failures > 0 {
+		*a.failures--
+		return errors.New("audit unavailable")
+	}
+	return a.AuditMap.Append(entry)
+}
+
+// Funcion para crear un handler sobre el servicio de newTestService
+func newTestHandler(db map[int]internal.Task, lastID int) (h *handler.TaskHandler, rp *repository.TaskMap) {
+	sv, rp := newTestService(db, lastID)
+	h = handler.NewTaskHandler(sv)
+	return
+}
+
 // Test de handler GetByID
 func TestGetByID(t *testing.T) {
 
@@ -31,6 +67,7 @@ func TestGetByID(t *testing.T) {
 				Tittle:      "task 1",
 				Description: "description 1",
 				Done:        false,
+				Version:     1,
 			},
 		}
 
@@ -39,7 +76,7 @@ func TestGetByID(t *testing.T) {
 		rp := repository.NewTaskMap(db, 0)
 
 		//Dependencia del service
-		sv := service.NewTaskService(rp)
+		sv := service.NewTaskService(rp, repository.NewAuditMap())
 
 		//Dependencia del handler
 		h := handler.NewTaskHandler(sv)
@@ -74,6 +111,7 @@ func TestGetByID(t *testing.T) {
 				Tittle:      "task 1",
 				Description: "description 1",
 				Done:        false,
+				Version:     1,
 			},
 		}
 
@@ -87,3 +125,1544 @@ func TestGetByID(t *testing.T) {
 		require.True(t, strings.HasPrefix(res.Header().Get("Content-Type"), "application/json"))
 	})
 }
+
+// Test de handler ListTasks
+func TestListTasks(t *testing.T) {
+
+	//Tareas de prueba
+	newDB := func() map[int]internal.Task {
+		return map[int]internal.Task{
+			1: {ID: 1, Tittle: "b task", Description: "first", Done: false},
+			2: {ID: 2, Tittle: "a task", Description: "second", Done: true},
+			3: {ID: 3, Tittle: "c task", Description: "third", Done: false},
+		}
+	}
+
+	//Test filtrar y ordenar las tareas
+	t.Run("Success - List filtered and sorted tasks", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(newDB(), 3)
+		hdFunc := h.ListTasks()
+
+		//act
+		req := httptest.NewRequest("GET", "/task?done=false&sort=tittle&order=desc", nil)
+		res := httptest.NewRecorder()
+		hdFunc(res, req)
+
+		//assert
+		expected := map[string]any{
+			"message": "tasks found",
+			"data": []handler.TaskResponse{
+				{ID: 3, Tittle: "c task", Description: "third", Done: false, Version: 1},
+				{ID: 1, Tittle: "b task", Description: "first", Done: false, Version: 1},
+			},
+			"total":       2,
+			"next_cursor": "",
+		}
+		expectedJSON, err := json.Marshal(expected)
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusOK, res.Code)
+		require.JSONEq(t, string(expectedJSON), res.Body.String())
+	})
+
+	//Test recorrer las paginas con el cursor
+	t.Run("Success - Paginate with cursor", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(newDB(), 3)
+		hdFunc := h.ListTasks()
+
+		//act
+		ids := []int{}
+		cursor := ""
+		for {
+			req := httptest.NewRequest("GET", "/task?sort=tittle&limit=2&cursor="+cursor, nil)
+			res := httptest.NewRecorder()
+			hdFunc(res, req)
+			require.Equal(t, http.StatusOK, res.Code)
+
+			var body struct {
+				Data       []handler.TaskResponse `json:"data"`
+				Total      int                    `json:"total"`
+				NextCursor string                 `json:"next_cursor"`
+			}
+			require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body))
+			require.Equal(t, 3, body.Total)
+
+			for _, task := range body.Data {
+				ids = append(ids, task.ID)
+			}
+
+			cursor = body.NextCursor
+			if cursor == "" {
+				break
+			}
+		}
+
+		//assert
+		require.Equal(t, []int{2, 1, 3}, ids)
+	})
+
+	//Test las tareas abiertas de mayor prioridad van primero
+	t.Run("Success - Sort open tasks by priority", func(t *testing.T) {
+
+		//arrange
+		db := map[int]internal.Task{
+			1: {ID: 1, Tittle: "low", Priority: internal.PriorityLow},
+			2: {ID: 2, Tittle: "urgent done", Priority: internal.PriorityUrgent, Done: true},
+			3: {ID: 3, Tittle: "high", Priority: internal.PriorityHigh},
+			4: {ID: 4, Tittle: "none"},
+		}
+		h, _ := newTestHandler(db, 4)
+		hdFunc := h.ListTasks()
+
+		//act
+		req := httptest.NewRequest("GET", "/task?done=false&sort=priority&order=desc", nil)
+		res := httptest.NewRecorder()
+		hdFunc(res, req)
+
+		//assert
+		expected := `{
+			"message": "tasks found",
+			"data": [
+				{"id": 3, "tittle": "high", "description": "", "done": false, "priority": "high", "blocked": false, "version": 1},
+				{"id": 1, "tittle": "low", "description": "", "done": false, "priority": "low", "blocked": false, "version": 1},
+				{"id": 4, "tittle": "none", "description": "", "done": false, "priority": "none", "blocked": false, "version": 1}
+			],
+			"total": 3,
+			"next_cursor": ""
+		}`
+		require.Equal(t, http.StatusOK, res.Code)
+		require.JSONEq(t, expected, res.Body.String())
+	})
+
+	//Test enviar un campo de ordenamiento invalido
+	t.Run("Error - Invalid sort field", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(newDB(), 3)
+		hdFunc := h.ListTasks()
+
+		//act
+		req := httptest.NewRequest("GET", "/task?sort=unknown", nil)
+		res := httptest.NewRecorder()
+		hdFunc(res, req)
+
+		//assert
+		require.Equal(t, http.StatusBadRequest, res.Code)
+	})
+}
+
+// Test de los handlers de etiquetas
+func TestTags(t *testing.T) {
+
+	//Test agregar una etiqueta y listar las etiquetas
+	t.Run("Success - Add tag and list tags", func(t *testing.T) {
+
+		//arrange
+		db := map[int]internal.Task{
+			1: {ID: 1, Tittle: "task 1", Tags: []string{"home"}},
+			2: {ID: 2, Tittle: "task 2"},
+		}
+		h, _ := newTestHandler(db, 2)
+
+		//act
+		req := httptest.NewRequest("POST", "/task/2/tags/Home", nil)
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "2")
+		chiCtx.URLParams.Add("tag", "Home")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h.AddTag()(res, req)
+
+		reqTags := httptest.NewRequest("GET", "/task/tags", nil)
+		resTags := httptest.NewRecorder()
+		h.ListTags()(resTags, reqTags)
+
+		//assert
+		require.Equal(t, http.StatusOK, res.Code)
+		var body struct {
+			Message string               `json:"message"`
+			Data    handler.TaskResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body))
+		require.Equal(t, "tag added", body.Message)
+
+		//La fecha de modificacion la asigna el repositorio al agregar la etiqueta
+		require.NotNil(t, body.Data.UpdatedAt)
+		body.Data.UpdatedAt = nil
+		require.Equal(t, handler.TaskResponse{ID: 2, Tittle: "task 2", Tags: []string{"home"}, Version: 2}, body.Data)
+
+		require.Equal(t, http.StatusOK, resTags.Code)
+		require.JSONEq(t, `{"message": "tags found", "data": [{"tag": "home", "count": 2}]}`, resTags.Body.String())
+	})
+
+	//Test agregar una etiqueta a una tarea que no existe
+	t.Run("Error - Task not found", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+
+		//act
+		req := httptest.NewRequest("POST", "/task/1/tags/home", nil)
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "1")
+		chiCtx.URLParams.Add("tag", "home")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h.AddTag()(res, req)
+
+		//assert
+		require.Equal(t, http.StatusNotFound, res.Code)
+	})
+}
+
+// Test de los handlers de subtareas
+func TestSubtasks(t *testing.T) {
+	parentID, childID := 1, 2
+	newDB := func() map[int]internal.Task {
+		return map[int]internal.Task{
+			1: {ID: 1, Tittle: "parent"},
+			2: {ID: 2, Tittle: "child", ParentID: &parentID},
+			3: {ID: 3, Tittle: "grandchild", ParentID: &childID},
+			4: {ID: 4, Tittle: "other"},
+		}
+	}
+
+	//Test obtener una tarea con todas sus subtareas anidadas
+	t.Run("Success - Get subtree", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(newDB(), 4)
+
+		//act
+		req := httptest.NewRequest("GET", "/task/1/subtree", nil)
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h.GetSubtree()(res, req)
+
+		//assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.JSONEq(t, `{
+			"message": "subtree found",
+			"data": {
+				"id": 1, "tittle": "parent", "description": "", "done": false, "priority": "none", "blocked": false, "version": 1,
+				"children": [{
+					"id": 2, "tittle": "child", "description": "", "done": false, "priority": "none", "blocked": false, "version": 1, "parent_id": 1,
+					"children": [{
+						"id": 3, "tittle": "grandchild", "description": "", "done": false, "priority": "none", "blocked": false, "version": 1, "parent_id": 2,
+						"children": []
+					}]
+				}]
+			}
+		}`, res.Body.String())
+	})
+
+	//Test eliminar una tarea junto con sus subtareas
+	t.Run("Success - Delete cascade", func(t *testing.T) {
+
+		//arrange
+		h, rp := newTestHandler(newDB(), 4)
+
+		//act
+		req := httptest.NewRequest("DELETE", "/task/delete/1?children=cascade", nil)
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h.DeleteTask()(res, req)
+
+		//assert
+		require.Equal(t, http.StatusNoContent, res.Code)
+		page, err := rp.List(internal.TaskQuery{})
+		require.NoError(t, err)
+		require.Equal(t, 1, page.Total)
+		require.Equal(t, 4, page.Tasks[0].ID)
+	})
+
+	//Test terminar una tarea con subtareas abiertas
+	t.Run("Error - Done with open children", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(newDB(), 4)
+
+		//act
+		req := httptest.NewRequest("PATCH", "/task/patch/1", strings.NewReader(`{"done": true}`))
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h.UpdatePartialTask()(res, req)
+
+		//assert
+		require.Equal(t, http.StatusConflict, res.Code)
+	})
+}
+
+// Test de los handlers de dependencias
+func TestDependencies(t *testing.T) {
+	newDB := func() map[int]internal.Task {
+		return map[int]internal.Task{
+			1: {ID: 1, Tittle: "deploy", BlockedBy: []int{2, 3}},
+			2: {ID: 2, Tittle: "build"},
+			3: {ID: 3, Tittle: "test", BlockedBy: []int{2}, Priority: internal.PriorityHigh},
+			4: {ID: 4, Tittle: "docs", Priority: internal.PriorityLow},
+			5: {ID: 5, Tittle: "old", Done: true},
+		}
+	}
+
+	//Test las tareas sin terminar se devuelven en orden de dependencias
+	t.Run("Success - Next tasks in topological order", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(newDB(), 5)
+
+		//act
+		req := httptest.NewRequest("GET", "/task/next", nil)
+		res := httptest.NewRecorder()
+		h.NextTasks()(res, req)
+
+		//assert
+		require.Equal(t, http.StatusOK, res.Code)
+		var body struct {
+			Data []handler.TaskResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &body))
+
+		ids := []int{}
+		blocked := []bool{}
+		for _, task := range body.Data {
+			ids = append(ids, task.ID)
+			blocked = append(blocked, task.Blocked)
+		}
+		require.Equal(t, []int{4, 2, 3, 1}, ids)
+		require.Equal(t, []bool{false, false, true, true}, blocked)
+	})
+
+	//Test agregar una dependencia que forma un ciclo
+	t.Run("Error - Dependency cycle", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(newDB(), 5)
+
+		//act
+		req := httptest.NewRequest("POST", "/task/2/dependencies/1", nil)
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "2")
+		chiCtx.URLParams.Add("blockedBy", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h.AddDependency()(res, req)
+
+		//assert
+		require.Equal(t, http.StatusConflict, res.Code)
+	})
+}
+
+// Test del control de concurrencia con ETag e If-Match
+func TestVersions(t *testing.T) {
+	newRequest := func(method, url, id, body string) *http.Request {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+
+	//Test la version se envia como ETag y se actualiza con If-Match
+	t.Run("Success - ETag and If-Match", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(map[int]internal.Task{1: {ID: 1, Tittle: "task 1"}}, 1)
+
+		//act
+		resGet := httptest.NewRecorder()
+		h.GetTaskByID()(resGet, newRequest("GET", "/task/get/1", "1", ""))
+
+		req := newRequest("PATCH", "/task/patch/1", "1", `{"done": true}`)
+		req.Header.Set("If-Match", resGet.Header().Get("ETag"))
+		resPatch := httptest.NewRecorder()
+		h.UpdatePartialTask()(resPatch, req)
+
+		req = newRequest("PUT", "/task/put/1", "1", `{"tittle": "task 1", "description": "updated", "done": true}`)
+		req.Header.Set("If-Match", resPatch.Header().Get("ETag"))
+		resPut := httptest.NewRecorder()
+		h.UpdateTask()(resPut, req)
+
+		//assert
+		require.Equal(t, http.StatusOK, resGet.Code)
+		require.Equal(t, `"1"`, resGet.Header().Get("ETag"))
+		require.Equal(t, http.StatusOK, resPatch.Code)
+		require.Equal(t, `"2"`, resPatch.Header().Get("ETag"))
+		require.Equal(t, http.StatusOK, resPut.Code)
+		require.Equal(t, `"3"`, resPut.Header().Get("ETag"))
+
+		//PATCH responde con la tarea actualizada igual que PUT
+		var patched struct {
+			Data handler.TaskResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(resPatch.Body.Bytes(), &patched))
+		require.True(t, patched.Data.Done)
+		require.Equal(t, 2, patched.Data.Version)
+	})
+
+	//Test un If-Match que no coincide con la version guardada
+	t.Run("Error - Precondition failed", func(t *testing.T) {
+
+		//arrange
+		h, rp := newTestHandler(map[int]internal.Task{1: {ID: 1, Tittle: "task 1", Version: 2}}, 1)
+
+		//act
+		requests := map[string]*http.Request{
+			"put":    newRequest("PUT", "/task/put/1", "1", `{"tittle": "task 1", "description": "", "done": true}`),
+			"patch":  newRequest("PATCH", "/task/patch/1", "1", `{"done": true}`),
+			"delete": newRequest("DELETE", "/task/delete/1", "1", ""),
+			"weak":   newRequest("DELETE", "/task/delete/1", "1", ""),
+		}
+		for name, req := range requests {
+			req.Header.Set("If-Match", `"1"`)
+			if name == "weak" {
+				req.Header.Set("If-Match", `W/"2"`)
+			}
+		}
+
+		results := map[string]int{}
+		for name, req := range requests {
+			res := httptest.NewRecorder()
+			switch req.Method {
+			case "PUT":
+				h.UpdateTask()(res, req)
+			case "PATCH":
+				h.UpdatePartialTask()(res, req)
+			case "DELETE":
+				h.DeleteTask()(res, req)
+			}
+			results[name] = res.Code
+		}
+
+		//assert
+		require.Equal(t, map[string]int{
+			"put":    http.StatusPreconditionFailed,
+			"patch":  http.StatusPreconditionFailed,
+			"delete": http.StatusPreconditionFailed,
+			"weak":   http.StatusPreconditionFailed,
+		}, results)
+
+		task, err := rp.GetByID(1)
+		require.NoError(t, err)
+		require.Equal(t, 2, task.Version)
+		require.False(t, task.Done)
+	})
+}
+
+// Test del GET condicional con If-None-Match e If-Modified-Since
+func TestConditionalGet(t *testing.T) {
+	updatedAt := time.Date(2024, 3, 1, 9, 30, 15, 500, time.UTC)
+	newHandler := func() *handler.TaskHandler {
+		db := map[int]internal.Task{
+			1: {ID: 1, Tittle: "task 1", Version: 3, CreatedAt: updatedAt.Add(-time.Hour), UpdatedAt: updatedAt},
+		}
+		h, _ := newTestHandler(db, 1)
+		return h
+	}
+	get := func(h *handler.TaskHandler, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/task/get/1", nil)
+		for header, value := range headers {
+			req.Header.Set(header, value)
+		}
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "1")
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+		res := httptest.NewRecorder()
+		h.GetTaskByID()(res, req)
+		return res
+	}
+
+	//Test sin headers condicionales se envia la tarea con sus validadores
+	t.Run("Success - Validators", func(t *testing.T) {
+
+		//arrange
+		h := newHandler()
+
+		//act
+		res := get(h, nil)
+
+		//assert
+		require.Equal(t, http.StatusOK, res.Code)
+		require.Equal(t, `"3"`, res.Header().Get("ETag"))
+		require.Equal(t, "Fri, 01 Mar 2024 09:30:15 GMT", res.Header().Get("Last-Modified"))
+		require.Contains(t, res.Body.String(), `"updated_at":"2024-03-01T09:30:15.0000005Z"`)
+	})
+
+	//Test el cliente ya tiene la version actual
+	t.Run("Success - Not modified", func(t *testing.T) {
+		for name, headers := range map[string]map[string]string{
+			"etag":           {"If-None-Match": `"3"`},
+			"weak etag list": {"If-None-Match": `"1", W/"3"`},
+			"any":            {"If-None-Match": "*"},
+			"same second":    {"If-Modified-Since": "Fri, 01 Mar 2024 09:30:15 GMT"},
+			"later":          {"If-Modified-Since": "Sat, 02 Mar 2024 00:00:00 GMT"},
+		} {
+			t.Run(name, func(t *testing.T) {
+
+				//arrange
+				h := newHandler()
+
+				//act
+				res := get(h, headers)
+
+				//assert
+				require.Equal(t, http.StatusNotModified, res.Code)
+				require.Empty(t, res.Body.String())
+				require.Equal(t, `"3"`, res.Header().Get("ETag"))
+				require.Equal(t, "Fri, 01 Mar 2024 09:30:15 GMT", res.Header().Get("Last-Modified"))
+			})
+		}
+	})
+
+	//Test la tarea cambio desde la version del cliente
+	t.Run("Success - Modified", func(t *testing.T) {
+		for name, headers := range map[string]map[string]string{
+			"other etag":   {"If-None-Match": `"2"`},
+			"earlier":      {"If-Modified-Since": "Fri, 01 Mar 2024 09:30:14 GMT"},
+			"invalid date": {"If-Modified-Since": "yesterday"},
+			"etag wins":    {"If-None-Match": `"2"`, "If-Modified-Since": "Sat, 02 Mar 2024 00:00:00 GMT"},
+		} {
+			t.Run(name, func(t *testing.T) {
+
+				//arrange
+				h := newHandler()
+
+				//act
+				res := get(h, headers)
+
+				//assert
+				require.Equal(t, http.StatusOK, res.Code)
+				require.Contains(t, res.Body.String(), `"version":3`)
+			})
+		}
+	})
+
+	//Test terminar la tarea que la bloquea cambia Blocked, el cliente con los validadores anteriores recibe la tarea
+	t.Run("Success - Blocker completed", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(map[int]internal.Task{
+			1: {ID: 1, Tittle: "task 1", Version: 3, BlockedBy: []int{2}, CreatedAt: updatedAt.Add(-time.Hour), UpdatedAt: updatedAt},
+			2: {ID: 2, Tittle: "task 2", Version: 1, CreatedAt: updatedAt.Add(-time.Hour), UpdatedAt: updatedAt},
+		}, 2)
+		resBlocked := get(h, nil)
+
+		req := httptest.NewRequest("PATCH", "/task/patch/2", strings.NewReader(`{"done": true}`))
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", "2")
+		resPatch := httptest.NewRecorder()
+		h.UpdatePartialTask()(resPatch, req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx)))
+
+		//act
+		resETag := get(h, map[string]string{"If-None-Match": resBlocked.Header().Get("ETag")})
+		resDate := get(h, map[string]string{"If-Modified-Since": resBlocked.Header().Get("Last-Modified")})
+
+		//assert
+		require.Equal(t, http.StatusOK, resBlocked.Code)
+		require.Contains(t, resBlocked.Body.String(), `"blocked":true`)
+		require.Equal(t, http.StatusOK, resPatch.Code)
+		for _, res := range []*httptest.ResponseRecorder{resETag, resDate} {
+			require.Equal(t, http.StatusOK, res.Code)
+			require.Contains(t, res.Body.String(), `"blocked":false`)
+			require.Equal(t, `"4"`, res.Header().Get("ETag"))
+		}
+	})
+}
+
+// Test de los handlers de la papelera
+func TestTrash(t *testing.T) {
+	newRequest := func(method, url, id string) *http.Request {
+		req := httptest.NewRequest(method, url, nil)
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+
+	//Test eliminar, listar la papelera, restaurar y eliminar definitivamente
+	t.Run("Success - Delete, restore and purge", func(t *testing.T) {
+
+		//arrange
+		h, rp := newTestHandler(map[int]internal.Task{
+			1: {ID: 1, Tittle: "task 1"},
+			2: {ID: 2, Tittle: "task 2"},
+		}, 2)
+
+		//act
+		resDelete := httptest.NewRecorder()
+		h.DeleteTask()(resDelete, newRequest("DELETE", "/task/delete/1", "1"))
+		h.DeleteTask()(httptest.NewRecorder(), newRequest("DELETE", "/task/delete/2", "2"))
+
+		resGet := httptest.NewRecorder()
+		h.GetTaskByID()(resGet, newRequest("GET", "/task/get/1", "1"))
+
+		resTrash := httptest.NewRecorder()
+		h.ListTrash()(resTrash, httptest.NewRequest("GET", "/task/trash", nil))
+
+		resRestore := httptest.NewRecorder()
+		h.RestoreTask()(resRestore, newRequest("POST", "/task/trash/1/restore", "1"))
+
+		resPurge := httptest.NewRecorder()
+		h.PurgeTask()(resPurge, newRequest("DELETE", "/task/trash/2", "2"))
+
+		//assert
+		require.Equal(t, http.StatusNoContent, resDelete.Code)
+		require.Equal(t, http.StatusNotFound, resGet.Code)
+
+		require.Equal(t, http.StatusOK, resTrash.Code)
+		var trash struct {
+			Data []handler.TaskResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(resTrash.Body.Bytes(), &trash))
+		require.Len(t, trash.Data, 2)
+		require.Equal(t, 2, trash.Data[0].ID)
+		require.NotNil(t, trash.Data[0].DeletedAt)
+
+		require.Equal(t, http.StatusOK, resRestore.Code)
+		require.Equal(t, `"3"`, resRestore.Header().Get("ETag"))
+		require.Contains(t, resRestore.Body.String(), `"message":"task restored"`)
+		require.NotContains(t, resRestore.Body.String(), "deleted_at")
+
+		require.Equal(t, http.StatusNoContent, resPurge.Code)
+		tasks, err := rp.ListTrash()
+		require.NoError(t, err)
+		require.Empty(t, tasks)
+	})
+
+	//Test errores al restaurar y eliminar definitivamente
+	t.Run("Error - Not in trash and duplicated", func(t *testing.T) {
+
+		//arrange
+		h, rp := newTestHandler(map[int]internal.Task{
+			1: {ID: 1, Tittle: "task 1"},
+		}, 1)
+		require.NoError(t, rp.Delete(1, 0))
+		task := internal.Task{Tittle: "task 1"}
+		require.NoError(t, rp.Save(&task))
+
+		//act
+		resDuplicated := httptest.NewRecorder()
+		h.RestoreTask()(resDuplicated, newRequest("POST", "/task/trash/1/restore", "1"))
+
+		resRestore := httptest.NewRecorder()
+		h.RestoreTask()(resRestore, newRequest("POST", "/task/trash/2/restore", "2"))
+
+		resPurge := httptest.NewRecorder()
+		h.PurgeTask()(resPurge, newRequest("DELETE", "/task/trash/2", "2"))
+
+		//assert
+		require.Equal(t, http.StatusConflict, resDuplicated.Code)
+		require.Equal(t, http.StatusNotFound, resRestore.Code)
+		require.Equal(t, http.StatusNotFound, resPurge.Code)
+	})
+}
+
+// Test de handler GetHistory
+func TestHistory(t *testing.T) {
+	newRequest := func(method, url, id, body, actor string) *http.Request {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		if actor != "" {
+			req.Header.Set("X-Actor", actor)
+		}
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+
+	type historyResponse struct {
+		Data  []handler.AuditEntryResponse `json:"data"`
+		Total int                          `json:"total"`
+	}
+
+	//Test crear, modificar y eliminar una tarea queda registrado con quien lo hizo y los campos que cambiaron
+	t.Run("Success - Create, patch and delete", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/save", "", `{"tittle": "task 1", "description": "", "done": false}`, "ana"))
+		h.UpdatePartialTask()(httptest.NewRecorder(), newRequest("PATCH", "/task/patch/1", "1", `{"done": true}`, " luis "))
+		h.DeleteTask()(httptest.NewRecorder(), newRequest("DELETE", "/task/delete/1", "1", "", ""))
+
+		//act
+		res := httptest.NewRecorder()
+		h.GetHistory()(res, newRequest("GET", "/task/1/history", "1", "", ""))
+
+		//assert
+		require.Equal(t, http.StatusOK, res.Code)
+		var history historyResponse
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &history))
+		require.Equal(t, 3, history.Total)
+		require.Len(t, history.Data, 3)
+
+		require.Equal(t, "ana", history.Data[0].Actor)
+		require.Equal(t, internal.AuditOpSave, history.Data[0].Op)
+		require.Contains(t, history.Data[0].Changes, handler.FieldChangeResponse{
+			Field: "tittle", Before: json.RawMessage("null"), After: json.RawMessage(`"task 1"`),
+		})
+
+		require.Equal(t, "luis", history.Data[1].Actor)
+		require.Equal(t, internal.AuditOpUpdatePartial, history.Data[1].Op)
+		require.Equal(t, []handler.FieldChangeResponse{
+			{Field: "done", Before: json.RawMessage("false"), After: json.RawMessage("true")},
+		}, history.Data[1].Changes)
+
+		require.Equal(t, internal.AuditActorAnonymous, history.Data[2].Actor)
+		require.Equal(t, internal.AuditOpDelete, history.Data[2].Op)
+		require.Len(t, history.Data[2].Changes, 1)
+		require.Equal(t, "deleted_at", history.Data[2].Changes[0].Field)
+		require.False(t, history.Data[2].At.Before(history.Data[0].At))
+	})
+
+	//Test restaurar la tarea con el mayor ID no registra una tarea relacionada creada por el cambio
+	t.Run("Success - Restore the task with the highest ID", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/save", "", `{"tittle": "task 1", "description": "", "done": false}`, ""))
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/save", "", `{"tittle": "task 2", "description": "", "done": false}`, ""))
+		h.DeleteTask()(httptest.NewRecorder(), newRequest("DELETE", "/task/delete/2", "2", "", ""))
+		resRestore := httptest.NewRecorder()
+		h.RestoreTask()(resRestore, newRequest("POST", "/task/trash/2/restore", "2", "", ""))
+
+		//act
+		res := httptest.NewRecorder()
+		h.GetHistory()(res, newRequest("GET", "/task/2/history", "2", "", ""))
+
+		//assert
+		require.Equal(t, http.StatusOK, resRestore.Code)
+		require.Equal(t, http.StatusOK, res.Code)
+		var history historyResponse
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &history))
+		ops := make([]string, 0, len(history.Data))
+		for _, entry := range history.Data {
+			ops = append(ops, entry.Op)
+		}
+		require.Equal(t, []string{internal.AuditOpSave, internal.AuditOpDelete, internal.AuditOpRestore}, ops)
+	})
+
+	//Test si no se puede registrar un cambio que ya se hizo, su entrada queda pendiente y no se hace otro cambio
+	//hasta guardarla, asi el historial y el registro de cambios tienen todos los cambios en orden
+	t.Run("Error - Audit failure after the change", func(t *testing.T) {
+
+		//arrange
+		failures := 2
+		rp := repository.NewTaskMap(nil, 0)
+		sv := service.NewTaskService(rp, failingAudit{AuditMap: repository.NewAuditMap(), failures: &failures})
+		h := handler.NewTaskHandler(sv)
+
+		//act
+		resCreate := httptest.NewRecorder()
+		h.CreateTask()(resCreate, newRequest("POST", "/task/save", "", `{"tittle": "task 1", "description": "", "done": false}`, ""))
+		created, errCreated := rp.GetByID(1)
+
+		resRejected := httptest.NewRecorder()
+		h.UpdatePartialTask()(resRejected, newRequest("PATCH", "/task/patch/1", "1", `{"done": true}`, ""))
+		rejected, errRejected := rp.GetByID(1)
+
+		resPatch := httptest.NewRecorder()
+		h.UpdatePartialTask()(resPatch, newRequest("PATCH", "/task/patch/1", "1", `{"done": true}`, ""))
+
+		resHistory := httptest.NewRecorder()
+		h.GetHistory()(resHistory, newRequest("GET", "/task/1/history", "1", "", ""))
+		changes, errChanges := sv.Changes(context.Background(), internal.ChangeQuery{})
+
+		//assert
+		//La tarea se creo aunque su entrada quedo pendiente
+		require.Equal(t, http.StatusServiceUnavailable, resCreate.Code)
+		require.NoError(t, errCreated)
+		require.Equal(t, "task 1", created.Tittle)
+
+		//Mientras la entrada pendiente no se guarda no se hacen otros cambios
+		require.Equal(t, http.StatusServiceUnavailable, resRejected.Code)
+		require.NoError(t, errRejected)
+		require.False(t, rejected.Done)
+
+		//Cuando la auditoria vuelve se guarda primero la entrada pendiente y despues el cambio
+		require.Equal(t, http.StatusOK, resPatch.Code)
+		var history historyResponse
+		require.NoError(t, json.Unmarshal(resHistory.Body.Bytes(), &history))
+		ops := make([]string, 0, len(history.Data))
+		for _, entry := range history.Data {
+			ops = append(ops, entry.Op)
+		}
+		require.Equal(t, []string{internal.AuditOpSave, internal.AuditOpUpdatePartial}, ops)
+
+		require.NoError(t, errChanges)
+		require.Len(t, changes.Changes, 2)
+		require.Equal(t, internal.ChangeCreated, changes.Changes[0].Type)
+		require.Equal(t, internal.ChangeUpdated, changes.Changes[1].Type)
+		require.True(t, changes.Changes[1].Task.Done)
+	})
+
+	//Test paginar el historial
+	t.Run("Success - Pagination", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/save", "", `{"tittle": "task 1", "description": "", "done": false}`, ""))
+		h.UpdatePartialTask()(httptest.NewRecorder(), newRequest("PATCH", "/task/patch/1", "1", `{"done": true}`, ""))
+		h.UpdatePartialTask()(httptest.NewRecorder(), newRequest("PATCH", "/task/patch/1", "1", `{"priority": "high"}`, ""))
+
+		//act
+		res := httptest.NewRecorder()
+		h.GetHistory()(res, newRequest("GET", "/task/1/history?limit=1&offset=1", "1", "", ""))
+
+		//assert
+		require.Equal(t, http.StatusOK, res.Code)
+		var history historyResponse
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &history))
+		require.Equal(t, 3, history.Total)
+		require.Len(t, history.Data, 1)
+		require.Equal(t, 2, history.Data[0].ID)
+		require.Equal(t, "done", history.Data[0].Changes[0].Field)
+	})
+
+	//Test errores al leer el historial
+	t.Run("Error - Not found and invalid pagination", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+
+		//act
+		resNotFound := httptest.NewRecorder()
+		h.GetHistory()(resNotFound, newRequest("GET", "/task/1/history", "1", "", ""))
+
+		resLimit := httptest.NewRecorder()
+		h.GetHistory()(resLimit, newRequest("GET", "/task/1/history?limit=-1", "1", "", ""))
+
+		//assert
+		require.Equal(t, http.StatusNotFound, resNotFound.Code)
+		require.Equal(t, http.StatusBadRequest, resLimit.Code)
+	})
+}
+
+// Test de handlers de revisiones
+func TestRevisions(t *testing.T) {
+	newRequest := func(method, url string, params map[string]string, body string) *http.Request {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		chiCtx := chi.NewRouteContext()
+		for key, value := range params {
+			chiCtx.URLParams.Add(key, value)
+		}
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+
+	type revisionResponse struct {
+		Data handler.RevisionResponse `json:"data"`
+	}
+
+	//Test leer una revision por numero y por instante y volver a ella
+	t.Run("Success - Get and revert", func(t *testing.T) {
+
+		//arrange
+		h, rp := newTestHandler(nil, 0)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "task 1", "description": "first", "done": false, "tags": ["home"]}`))
+		h.UpdatePartialTask()(httptest.NewRecorder(), newRequest("PATCH", "/task/patch/1", map[string]string{"id": "1"}, `{"tittle": "task 2", "done": true}`))
+
+		//act
+		resRevision := httptest.NewRecorder()
+		h.GetRevision()(resRevision, newRequest("GET", "/task/1/revisions/1", map[string]string{"id": "1", "revision": "1"}, ""))
+
+		resLatest := httptest.NewRecorder()
+		h.GetRevisionAt()(resLatest, newRequest("GET", "/task/1/revisions?at="+time.Now().Add(time.Hour).UTC().Format(time.RFC3339), map[string]string{"id": "1"}, ""))
+
+		resBefore := httptest.NewRecorder()
+		h.GetRevisionAt()(resBefore, newRequest("GET", "/task/1/revisions?at=2000-01-01T00:00:00Z", map[string]string{"id": "1"}, ""))
+
+		resRevert := httptest.NewRecorder()
+		h.RevertTask()(resRevert, newRequest("POST", "/task/1/revisions/1/revert", map[string]string{"id": "1", "revision": "1"}, ""))
+
+		resHistory := httptest.NewRecorder()
+		h.GetHistory()(resHistory, newRequest("GET", "/task/1/history", map[string]string{"id": "1"}, ""))
+
+		//assert
+		require.Equal(t, http.StatusOK, resRevision.Code)
+		var revision revisionResponse
+		require.NoError(t, json.Unmarshal(resRevision.Body.Bytes(), &revision))
+		require.Equal(t, 1, revision.Data.Revision)
+		require.Equal(t, internal.AuditOpSave, revision.Data.Op)
+		require.Equal(t, "task 1", revision.Data.Task.Tittle)
+		require.Equal(t, []string{"home"}, revision.Data.Task.Tags)
+		require.Equal(t, 1, revision.Data.Task.Version)
+
+		require.Equal(t, http.StatusOK, resLatest.Code)
+		var latest revisionResponse
+		require.NoError(t, json.Unmarshal(resLatest.Body.Bytes(), &latest))
+		require.Equal(t, 2, latest.Data.Revision)
+		require.Equal(t, "task 2", latest.Data.Task.Tittle)
+		require.True(t, latest.Data.Task.Done)
+
+		require.Equal(t, http.StatusNotFound, resBefore.Code)
+
+		require.Equal(t, http.StatusOK, resRevert.Code)
+		require.Equal(t, `"3"`, resRevert.Header().Get("ETag"))
+		task, err := rp.GetByID(1)
+		require.NoError(t, err)
+		require.Equal(t, "task 1", task.Tittle)
+		require.Equal(t, "first", task.Description)
+		require.False(t, task.Done)
+		require.Equal(t, []string{"home"}, task.Tags)
+
+		var history struct {
+			Data []handler.AuditEntryResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(resHistory.Body.Bytes(), &history))
+		require.Len(t, history.Data, 3)
+		require.Equal(t, 3, history.Data[2].Revision)
+		require.Equal(t, internal.AuditOpRevert, history.Data[2].Op)
+	})
+
+	//Test errores al leer una revision y al volver a ella
+	t.Run("Error - Revision not found, duplicated title and version conflict", func(t *testing.T) {
+
+		//arrange
+		h, rp := newTestHandler(nil, 0)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "task 1", "description": "", "done": false}`))
+		h.UpdatePartialTask()(httptest.NewRecorder(), newRequest("PATCH", "/task/patch/1", map[string]string{"id": "1"}, `{"tittle": "task 2"}`))
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "task 1", "description": "", "done": false}`))
+
+		//act
+		resNotFound := httptest.NewRecorder()
+		h.GetRevision()(resNotFound, newRequest("GET", "/task/1/revisions/3", map[string]string{"id": "1", "revision": "3"}, ""))
+
+		resInvalid := httptest.NewRecorder()
+		h.GetRevision()(resInvalid, newRequest("GET", "/task/1/revisions/0", map[string]string{"id": "1", "revision": "0"}, ""))
+
+		resDuplicated := httptest.NewRecorder()
+		h.RevertTask()(resDuplicated, newRequest("POST", "/task/1/revisions/1/revert", map[string]string{"id": "1", "revision": "1"}, ""))
+
+		req := newRequest("POST", "/task/1/revisions/1/revert", map[string]string{"id": "1", "revision": "1"}, "")
+		req.Header.Set("If-Match", `"1"`)
+		resConflict := httptest.NewRecorder()
+		h.RevertTask()(resConflict, req)
+
+		//assert
+		require.Equal(t, http.StatusNotFound, resNotFound.Code)
+		require.Equal(t, http.StatusBadRequest, resInvalid.Code)
+		require.Equal(t, http.StatusConflict, resDuplicated.Code)
+		require.Equal(t, http.StatusPreconditionFailed, resConflict.Code)
+		task, err := rp.GetByID(1)
+		require.NoError(t, err)
+		require.Equal(t, "task 2", task.Tittle)
+	})
+
+	//Test volver a una revision valida el contenido con las reglas actuales del servicio
+	t.Run("Error - Revision breaks the current rules", func(t *testing.T) {
+
+		//arrange
+		sv, rp := newTestService(nil, 0)
+		h := handler.NewTaskHandler(sv)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "a long task title", "description": "", "done": false}`))
+		h.UpdatePartialTask()(httptest.NewRecorder(), newRequest("PATCH", "/task/patch/1", map[string]string{"id": "1"}, `{"tittle": "short"}`))
+		strict := handler.NewTaskHandler(sv.WithRules(internal.TaskRules{TittleMaxLength: 10}))
+
+		//act
+		res := httptest.NewRecorder()
+		strict.RevertTask()(res, newRequest("POST", "/task/1/revisions/1/revert", map[string]string{"id": "1", "revision": "1"}, ""))
+
+		//assert
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		var problem response.Problem
+		require.NoError(t, json.NewDecoder(res.Body).Decode(&problem))
+		require.Equal(t, []response.FieldProblem{
+			{Field: "tittle", Detail: "must be at most 10 characters"},
+		}, problem.Errors)
+		task, err := rp.GetByID(1)
+		require.NoError(t, err)
+		require.Equal(t, "short", task.Tittle)
+	})
+}
+
+// Test del registro de cambios
+func TestChanges(t *testing.T) {
+	newRequest := func(method, url string, params map[string]string, body string) *http.Request {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		chiCtx := chi.NewRouteContext()
+		for key, value := range params {
+			chiCtx.URLParams.Add(key, value)
+		}
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+
+	type changesResponse struct {
+		Data   []handler.ChangeResponse `json:"data"`
+		Cursor string                   `json:"cursor"`
+	}
+
+	//Funcion para leer los cambios posteriores a un cursor
+	getChanges := func(t *testing.T, h *handler.TaskHandler, query string) (res changesResponse) {
+		rec := httptest.NewRecorder()
+		h.GetChanges()(rec, newRequest("GET", "/task/changes?"+query, nil, ""))
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+		return
+	}
+
+	//Test los cambios de todas las tareas se leen en orden y se puede seguir desde un cursor
+	t.Run("Success - Created, updated and deleted with resumable cursor", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "parent", "description": "", "done": false}`))
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "child", "description": "", "done": false, "parent_id": 1}`))
+		h.UpdatePartialTask()(httptest.NewRecorder(), newRequest("PATCH", "/task/patch/1", map[string]string{"id": "1"}, `{"description": "new"}`))
+		h.DeleteTask()(httptest.NewRecorder(), newRequest("DELETE", "/task/delete/1", map[string]string{"id": "1"}, ""))
+
+		//act
+		all := getChanges(t, h, "")
+		resumed := getChanges(t, h, "since="+all.Data[2].Cursor+"&limit=1")
+		last := getChanges(t, h, "since="+all.Cursor)
+
+		//assert
+		require.Len(t, all.Data, 5)
+		types := []string{}
+		for _, change := range all.Data {
+			types = append(types, change.Type)
+		}
+		require.Equal(t, []string{internal.ChangeCreated, internal.ChangeCreated, internal.ChangeUpdated, internal.ChangeDeleted, internal.ChangeUpdated}, types)
+		require.Equal(t, "new", all.Data[2].Task.Description)
+		require.NotNil(t, all.Data[3].Task.DeletedAt)
+		require.Equal(t, 2, all.Data[4].TaskID, "the child moved to the parent of the deleted task")
+		require.Nil(t, all.Data[4].Task.ParentID)
+		require.Equal(t, all.Data[4].Cursor, all.Cursor)
+
+		require.Len(t, resumed.Data, 1)
+		require.Equal(t, all.Data[3], resumed.Data[0])
+		require.Equal(t, all.Data[3].Cursor, resumed.Cursor)
+
+		require.Empty(t, last.Data)
+		require.Equal(t, all.Cursor, last.Cursor)
+	})
+
+	//Test completar una tarea recurrente registra la siguiente ocurrencia como creada
+	t.Run("Success - Next occurrence is created", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "daily", "description": "", "done": false, "due_at": "2024-05-01T09:00:00Z", "recurrence": "FREQ=DAILY"}`))
+		cursor := getChanges(t, h, "").Cursor
+
+		//act
+		h.UpdatePartialTask()(httptest.NewRecorder(), newRequest("PATCH", "/task/patch/1", map[string]string{"id": "1"}, `{"done": true}`))
+		res := getChanges(t, h, "since="+cursor)
+
+		//assert
+		require.Len(t, res.Data, 2)
+		require.Equal(t, internal.ChangeUpdated, res.Data[0].Type)
+		require.True(t, res.Data[0].Task.Done)
+		require.Equal(t, internal.ChangeCreated, res.Data[1].Type)
+		require.Equal(t, 2, res.Data[1].TaskID)
+		require.False(t, res.Data[1].Task.Done)
+	})
+
+	//Test una consulta con espera devuelve el cambio que llega mientras espera, o nada si se acaba el tiempo
+	t.Run("Success - Long polling", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			rec := httptest.NewRecorder()
+			h.GetChanges()(rec, newRequest("GET", "/task/changes?wait=5s", nil, ""))
+			done <- rec
+		}()
+
+		//act
+		time.Sleep(20 * time.Millisecond)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "task 1", "description": "", "done": false}`))
+		rec := <-done
+		var res changesResponse
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+
+		start := time.Now()
+		timeout := getChanges(t, h, "since="+res.Cursor+"&wait=50ms")
+
+		//assert
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.Len(t, res.Data, 1)
+		require.Equal(t, internal.ChangeCreated, res.Data[0].Type)
+		require.Equal(t, 1, res.Data[0].TaskID)
+
+		require.Empty(t, timeout.Data)
+		require.Equal(t, res.Cursor, timeout.Cursor)
+		require.GreaterOrEqual(t, time.Since(start), 50*time.Millisecond)
+	})
+
+	//Test un cursor, un limite o una espera invalidos
+	t.Run("Error - Invalid query", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+
+		for _, query := range []string{"since=not-a-cursor", "limit=-1", "limit=5000", "wait=soon", "wait=-1s"} {
+			//act
+			res := httptest.NewRecorder()
+			h.GetChanges()(res, newRequest("GET", "/task/changes?"+query, nil, ""))
+
+			//assert
+			require.Equal(t, http.StatusBadRequest, res.Code, query)
+		}
+	})
+}
+
+// Test del stream de eventos (SSE)
+func TestStreamEvents(t *testing.T) {
+	newRequest := func(method, url string, params map[string]string, body string) *http.Request {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		chiCtx := chi.NewRouteContext()
+		for key, value := range params {
+			chiCtx.URLParams.Add(key, value)
+		}
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+
+	type event struct {
+		ID     string
+		Event  string
+		Change handler.ChangeResponse
+	}
+
+	//Funcion para conectarse al stream, devuelve una funcion para leer el siguiente evento
+	connect := func(t *testing.T, h *handler.TaskHandler, query string, lastEventID string) (next func() event) {
+		server := httptest.NewServer(h.StreamEvents())
+		t.Cleanup(server.Close)
+
+		req, err := http.NewRequest("GET", server.URL+"/task/events?"+query, nil)
+		require.NoError(t, err)
+		if lastEventID != "" {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		client := &http.Client{Timeout: 5 * time.Second}
+		res, err := client.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { res.Body.Close() })
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+		reader := bufio.NewReader(res.Body)
+		next = func() (e event) {
+			for {
+				line, err := reader.ReadString('\n')
+				require.NoError(t, err)
+				line = strings.TrimSuffix(line, "\n")
+				switch {
+				case line == "" && e.Event != "":
+					return
+				case strings.HasPrefix(line, "id: "):
+					e.ID = strings.TrimPrefix(line, "id: ")
+				case strings.HasPrefix(line, "event: "):
+					e.Event = strings.TrimPrefix(line, "event: ")
+				case strings.HasPrefix(line, "data: "):
+					require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e.Change))
+				}
+			}
+		}
+		return
+	}
+
+	//Test los cambios llegan a medida que ocurren, solo los de las tareas del filtro
+	t.Run("Success - Live events filtered by task and done", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		next := connect(t, h, "id=1,2&done=false", "")
+
+		//act
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "task 1", "description": "", "done": false}`))
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "task 2", "description": "", "done": true}`))
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "task 3", "description": "", "done": false}`))
+		h.UpdatePartialTask()(httptest.NewRecorder(), newRequest("PATCH", "/task/patch/2", map[string]string{"id": "2"}, `{"done": false}`))
+		created, updated := next(), next()
+
+		//assert
+		require.Equal(t, internal.ChangeCreated, created.Event)
+		require.Equal(t, 1, created.Change.TaskID)
+		require.Equal(t, created.ID, created.Change.Cursor)
+		require.Equal(t, "task 1", created.Change.Task.Tittle)
+
+		require.Equal(t, internal.ChangeUpdated, updated.Event)
+		require.Equal(t, 2, updated.Change.TaskID)
+		require.False(t, updated.Change.Task.Done)
+	})
+
+	//Test al reconectarse con Last-Event-ID se reenvian los cambios que se perdieron
+	t.Run("Success - Replay from Last-Event-ID", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "task 1", "description": "", "done": false}`))
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "task 2", "description": "", "done": false}`))
+		h.DeleteTask()(httptest.NewRecorder(), newRequest("DELETE", "/task/delete/1", map[string]string{"id": "1"}, ""))
+
+		rec := httptest.NewRecorder()
+		h.GetChanges()(rec, newRequest("GET", "/task/changes", nil, ""))
+		var changes struct {
+			Data []handler.ChangeResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &changes))
+
+		//act
+		next := connect(t, h, "", changes.Data[0].Cursor)
+		replayed := []event{next(), next()}
+		h.UpdatePartialTask()(httptest.NewRecorder(), newRequest("PATCH", "/task/patch/2", map[string]string{"id": "2"}, `{"done": true}`))
+		live := next()
+
+		//assert
+		require.Equal(t, internal.ChangeCreated, replayed[0].Event)
+		require.Equal(t, changes.Data[1].Cursor, replayed[0].ID)
+		require.Equal(t, internal.ChangeDeleted, replayed[1].Event)
+		require.Equal(t, changes.Data[2].Cursor, replayed[1].ID)
+
+		require.Equal(t, internal.ChangeUpdated, live.Event)
+		require.True(t, live.Change.Task.Done)
+	})
+
+	//Test si los cambios posteriores a Last-Event-ID ya no se conservan se envia un evento reset
+	t.Run("Success - Reset when events were lost", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+
+		//act
+		next := connect(t, h, "", internal.EncodeChangeCursor(50))
+		reset := next()
+
+		//assert
+		require.Equal(t, "reset", reset.Event)
+	})
+
+	//Test filtros y Last-Event-ID invalidos
+	t.Run("Error - Invalid query", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		requests := []*http.Request{
+			newRequest("GET", "/task/events?id=abc", nil, ""),
+			newRequest("GET", "/task/events?done=maybe", nil, ""),
+			newRequest("GET", "/task/events", nil, ""),
+		}
+		requests[2].Header.Set("Last-Event-ID", "not-a-cursor")
+
+		for _, req := range requests {
+			//act
+			res := httptest.NewRecorder()
+			h.StreamEvents()(res, req)
+
+			//assert
+			require.Equal(t, http.StatusBadRequest, res.Code, req.URL.String())
+		}
+	})
+}
+
+// Test de los errores en formato problem+json
+func TestProblemDetails(t *testing.T) {
+	newRequest := func(method, url string, params map[string]string, body string) *http.Request {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		chiCtx := chi.NewRouteContext()
+		for key, value := range params {
+			chiCtx.URLParams.Add(key, value)
+		}
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+
+	//Funcion para leer el problema de una respuesta
+	readProblem := func(t *testing.T, res *httptest.ResponseRecorder) (problem response.Problem) {
+		require.Equal(t, "application/problem+json; charset=utf-8", res.Header().Get("Content-Type"))
+		require.NoError(t, json.Unmarshal(res.Body.Bytes(), &problem))
+		require.Equal(t, res.Code, problem.Status)
+		return
+	}
+
+	//Test cada error tiene su tipo, titulo, status y la ruta del request
+	t.Run("Error - Domain and request errors", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		resCreated := httptest.NewRecorder()
+		h.CreateTask()(resCreated, newRequest("POST", "/task/post", nil, `{"tittle": "task 1", "description": "", "done": false}`))
+
+		cases := []struct {
+			name        string
+			handler     http.HandlerFunc
+			req         *http.Request
+			status      int
+			problemType string
+			title       string
+		}{
+			{"invalid id", h.GetTaskByID(), newRequest("GET", "/task/get/abc", map[string]string{"id": "abc"}, ""), http.StatusBadRequest, "/problems/invalid-request", "Invalid request"},
+			{"not found", h.GetTaskByID(), newRequest("GET", "/task/get/9", map[string]string{"id": "9"}, ""), http.StatusNotFound, "/problems/task-not-found", "Task not found"},
+			{"duplicated", h.CreateTask(), newRequest("POST", "/task/post", nil, `{"tittle": "task 1", "description": "", "done": false}`), http.StatusConflict, "/problems/task-duplicated", "Task already exists"},
+			{"invalid body", h.UpdatePartialTask(), newRequest("PATCH", "/task/patch/1", map[string]string{"id": "1"}, `{`), http.StatusBadRequest, "/problems/invalid-request", "Invalid request"},
+			{"invalid query", h.ListTasks(), newRequest("GET", "/task/?limit=-1", nil, ""), http.StatusBadRequest, "/problems/invalid-request", "Invalid request"},
+			{"revision not found", h.GetRevision(), newRequest("GET", "/task/1/revisions/5", map[string]string{"id": "1", "revision": "5"}, ""), http.StatusNotFound, "/problems/revision-not-found", "Revision not found"},
+		}
+
+		for _, c := range cases {
+			//act
+			res := httptest.NewRecorder()
+			c.handler(res, c.req)
+
+			//assert
+			require.Equal(t, c.status, res.Code, c.name)
+			problem := readProblem(t, res)
+			require.Equal(t, c.problemType, problem.Type, c.name)
+			require.Equal(t, c.title, problem.Title, c.name)
+			require.NotEmpty(t, problem.Detail, c.name)
+			require.Equal(t, c.req.URL.Path, problem.Instance, c.name)
+		}
+
+		require.Equal(t, http.StatusCreated, resCreated.Code)
+		require.Equal(t, "application/json; charset=utf-8", resCreated.Header().Get("Content-Type"))
+	})
+
+	//Test una version que no coincide es un problema de conflicto de version
+	t.Run("Error - Version conflict", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "task 1", "description": "", "done": false}`))
+		req := newRequest("PUT", "/task/put/1", map[string]string{"id": "1"}, `{"tittle": "task 1", "description": "new", "done": false}`)
+		req.Header.Set("If-Match", `"4"`)
+
+		//act
+		res := httptest.NewRecorder()
+		h.UpdateTask()(res, req)
+
+		//assert
+		require.Equal(t, http.StatusPreconditionFailed, res.Code)
+		require.Equal(t, "/problems/version-conflict", readProblem(t, res).Type)
+	})
+
+	//Test los errores de validacion del body tienen el error de cada campo
+	t.Run("Error - Validation errors per field", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+
+		//act
+		resMissing := httptest.NewRecorder()
+		h.CreateTask()(resMissing, newRequest("POST", "/task/post", nil, `{"tittle": "task 1"}`))
+
+		resInvalid := httptest.NewRecorder()
+		h.CreateTask()(resInvalid, newRequest("POST", "/task/post", nil, `{"tittle": "task 1", "description": "", "done": false, "priority": "later", "recurrence": "FREQ=NEVER"}`))
+
+		//assert
+		require.Equal(t, http.StatusBadRequest, resMissing.Code)
+		missing := readProblem(t, resMissing)
+		require.Equal(t, "/problems/validation-failed", missing.Type)
+		require.Equal(t, []response.FieldProblem{
+			{Field: "description", Detail: "field is required"},
+			{Field: "done", Detail: "field is required"},
+		}, missing.Errors)
+
+		require.Equal(t, http.StatusBadRequest, resInvalid.Code)
+		require.Equal(t, []response.FieldProblem{
+			{Field: "priority", Detail: "invalid priority"},
+			{Field: "recurrence", Detail: "invalid recurrence"},
+		}, readProblem(t, resInvalid).Errors)
+	})
+
+	//Test los campos obligatorios enviados como null son invalidos
+	t.Run("Error - Null required fields", func(t *testing.T) {
+
+		//arrange
+		h, rp := newTestHandler(nil, 0)
+
+		//act
+		res := httptest.NewRecorder()
+		h.CreateTask()(res, newRequest("POST", "/task/post", nil, `{"tittle": null, "description": "", "done": null}`))
+		page, errList := rp.List(internal.TaskQuery{})
+
+		//assert
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, []response.FieldProblem{
+			{Field: "tittle", Detail: "must not be null"},
+			{Field: "done", Detail: "must not be null"},
+		}, readProblem(t, res).Errors)
+		require.NoError(t, errList)
+		require.Zero(t, page.Total)
+	})
+
+	//Test un body mas grande que el limite se rechaza con 413 en todos los metodos
+	t.Run("Error - Request body too large", func(t *testing.T) {
+
+		//arrange
+		h, rp := newTestHandler(map[int]internal.Task{1: {ID: 1, Tittle: "task 1", Version: 1}}, 1)
+		limit := handler.LimitBody(64)
+		body := `{"tittle": "` + strings.Repeat("a", 64) + `", "description": "", "done": false}`
+
+		//act
+		resPost := httptest.NewRecorder()
+		limit(h.CreateTask()).ServeHTTP(resPost, newRequest("POST", "/task/post", nil, body))
+		resPut := httptest.NewRecorder()
+		limit(h.UpdateTask()).ServeHTTP(resPut, newRequest("PUT", "/task/put/1", map[string]string{"id": "1"}, body))
+		resPatch := httptest.NewRecorder()
+		limit(h.UpdatePartialTask()).ServeHTTP(resPatch, newRequest("PATCH", "/task/patch/1", map[string]string{"id": "1"}, body))
+		task, errGet := rp.GetByID(1)
+
+		//assert
+		for _, res := range []*httptest.ResponseRecorder{resPost, resPut, resPatch} {
+			require.Equal(t, http.StatusRequestEntityTooLarge, res.Code)
+			require.Equal(t, "/problems/request-too-large", readProblem(t, res).Type)
+		}
+		require.NoError(t, errGet)
+		require.Equal(t, "task 1", task.Tittle)
+	})
+
+	//Test una actualizacion parcial con campos desconocidos o tipos invalidos no modifica la tarea
+	t.Run("Error - Partial update with unknown fields and invalid types", func(t *testing.T) {
+
+		//arrange
+		h, rp := newTestHandler(nil, 0)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", nil, `{"tittle": "task 1", "description": "", "done": false}`))
+
+		//act
+		res := httptest.NewRecorder()
+		h.UpdatePartialTask()(res, newRequest("PATCH", "/task/patch/1", map[string]string{"id": "1"}, `{"description": 1, "Done": "yes", "owner": "luis"}`))
+		task, err := rp.GetByID(1)
+
+		//assert
+		require.Equal(t, http.StatusBadRequest, res.Code)
+		require.Equal(t, []response.FieldProblem{
+			{Field: "Done", Detail: "must be a boolean"},
+			{Field: "description", Detail: "must be a string"},
+			{Field: "owner", Detail: "unknown field"},
+		}, readProblem(t, res).Errors)
+		require.NoError(t, err)
+		require.Equal(t, 1, task.Version)
+	})
+
+	//Test un body vacio o con tipos incorrectos informa todos los errores en una sola respuesta
+	t.Run("Error - All validation errors at once", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+
+		//act
+		resEmpty := httptest.NewRecorder()
+		h.CreateTask()(resEmpty, newRequest("POST", "/task/post", nil, `{}`))
+
+		resTypes := httptest.NewRecorder()
+		h.UpdateTask()(resTypes, newRequest("PUT", "/task/put/1", map[string]string{"id": "1"}, `{"tittle": 1, "description": "", "done": "no", "due_at": "tomorrow", "priority": "high", "tags": ["a", 2], "parent_id": 1.5, "blocked_by": "2"}`))
+
+		//assert
+		require.Equal(t, http.StatusBadRequest, resEmpty.Code)
+		require.Equal(t, []response.FieldProblem{
+			{Field: "tittle", Detail: "field is required"},
+			{Field: "description", Detail: "field is required"},
+			{Field: "done", Detail: "field is required"},
+		}, readProblem(t, resEmpty).Errors)
+
+		require.Equal(t, http.StatusBadRequest, resTypes.Code)
+		require.Equal(t, []response.FieldProblem{
+			{Field: "tittle", Detail: "must be a string"},
+			{Field: "done", Detail: "must be a boolean"},
+			{Field: "due_at", Detail: "must be a date in RFC 3339 format"},
+			{Field: "tags", Detail: "must be a list of strings"},
+			{Field: "parent_id", Detail: "must be an integer"},
+			{Field: "blocked_by", Detail: "must be a list of integers"},
+		}, readProblem(t, resTypes).Errors)
+	})
+}
+
+// Test de las reglas del titulo y la descripcion que aplica el servicio
+func TestTaskRules(t *testing.T) {
+	newRequest := func(method, url, id, body string) *http.Request {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		chiCtx := chi.NewRouteContext()
+		chiCtx.URLParams.Add("id", id)
+		return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, chiCtx))
+	}
+
+	//Test el titulo se guarda sin espacios alrededor y en NFC, asi un titulo equivalente es un duplicado
+	t.Run("Success - Normalized tittle", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+
+		//act
+		resCreated := httptest.NewRecorder()
+		h.CreateTask()(resCreated, newRequest("POST", "/task/post", "", `{"tittle": "  cafe\u0301  ", "description": "", "done": false}`))
+
+		resDuplicated := httptest.NewRecorder()
+		h.CreateTask()(resDuplicated, newRequest("POST", "/task/post", "", `{"tittle": "café", "description": "", "done": false}`))
+
+		//assert
+		require.Equal(t, http.StatusCreated, resCreated.Code)
+		var body struct {
+			Data handler.TaskResponse `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(resCreated.Body.Bytes(), &body))
+		require.Equal(t, "café", body.Data.Tittle)
+		require.Equal(t, http.StatusConflict, resDuplicated.Code)
+	})
+
+	//Test un titulo vacio o una descripcion con caracteres de control no se guardan
+	t.Run("Error - Invalid tittle and description", func(t *testing.T) {
+
+		//arrange
+		h, _ := newTestHandler(nil, 0)
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", "", `{"tittle": "task 1", "description": "", "done": false}`))
+
+		//act
+		resCreate := httptest.NewRecorder()
+		h.CreateTask()(resCreate, newRequest("POST", "/task/post", "", `{"tittle": "   ", "description": "bell \u0007", "done": false}`))
+
+		resPatch := httptest.NewRecorder()
+		h.UpdatePartialTask()(resPatch, newRequest("PATCH", "/task/patch/1", "1", `{"tittle": "`+strings.Repeat("a", internal.TaskTittleMaxLength+1)+`"}`))
+
+		//assert
+		require.Equal(t, http.StatusBadRequest, resCreate.Code)
+		var problem response.Problem
+		require.NoError(t, json.Unmarshal(resCreate.Body.Bytes(), &problem))
+		require.Equal(t, []response.FieldProblem{
+			{Field: "tittle", Detail: "must not be empty"},
+			{Field: "description", Detail: "must not contain control characters"},
+		}, problem.Errors)
+
+		require.Equal(t, http.StatusBadRequest, resPatch.Code)
+		require.NoError(t, json.Unmarshal(resPatch.Body.Bytes(), &problem))
+		require.Equal(t, []response.FieldProblem{
+			{Field: "tittle", Detail: "must be at most 200 characters"},
+		}, problem.Errors)
+	})
+
+	//Test con titulos sin distinguir mayusculas otra tarea no puede usar el mismo titulo en otro caso
+	t.Run("Error - Case insensitive tittles", func(t *testing.T) {
+
+		//arrange
+		rules := internal.DefaultTaskRules()
+		rules.CaseInsensitiveTittles = true
+		sv, _ := newTestService(nil, 0)
+		h := handler.NewTaskHandler(sv.WithRules(rules))
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", "", `{"tittle": "Task 1", "description": "", "done": false}`))
+		h.CreateTask()(httptest.NewRecorder(), newRequest("POST", "/task/post", "", `{"tittle": "task 2", "description": "", "done": false}`))
+
+		//act
+		resCreate := httptest.NewRecorder()
+		h.CreateTask()(resCreate, newRequest("POST", "/task/post", "", `{"tittle": "TASK 1", "description": "", "done": false}`))
+
+		resUpdate := httptest.NewRecorder()
+		h.UpdateTask()(resUpdate, newRequest("PUT", "/task/put/2", "2", `{"tittle": "task 1", "description": "", "done": false}`))
+
+		resPatch := httptest.NewRecorder()
+		h.UpdatePartialTask()(resPatch, newRequest("PATCH", "/task/patch/2", "2", `{"tittle": "tAsK 1"}`))
+
+		resOwn := httptest.NewRecorder()
+		h.UpdatePartialTask()(resOwn, newRequest("PATCH", "/task/patch/1", "1", `{"tittle": "TASK 1"}`))
+
+		//assert
+		require.Equal(t, http.StatusConflict, resCreate.Code)
+		require.Equal(t, http.StatusConflict, resUpdate.Code)
+		require.Equal(t, http.StatusConflict, resPatch.Code)
+		require.Equal(t, http.StatusOK, resOwn.Code)
+	})
+}
@@ -0,0 +1,401 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/pkg/response"
+	"github.com/gorilla/websocket"
+)
+
+/*
+	Este archivo contiene el WebSocket de tareas.
+
+	Por la misma conexion el cliente envia comandos y recibe los cambios de las tareas a las que
+	esta suscrito. Cada comando tiene un id elegido por el cliente y se responde con un ack que
+	tiene ese id y el codigo HTTP del handler HTTP equivalente. Si el comando falla el ack tiene
+	el mismo problema (RFC 7807) que enviaria el handler HTTP.
+*/
+
+// Tipos de comandos que envia el cliente
+const (
+	SocketSubscribe   = "subscribe"
+	SocketUnsubscribe = "unsubscribe"
+	SocketCreate      = "create"
+	SocketUpdate      = "update"
+	SocketPatch       = "patch"
+	SocketDelete      = "delete"
+)
+
+// Tipos de mensajes que envia el servidor
+const (
+	//Respuesta a un comando
+	SocketAck = "ack"
+
+	//Cambio de una tarea de la suscripcion
+	SocketChange = "change"
+
+	//Se perdieron cambios de la suscripcion, el cliente debe volver a leer las tareas
+	SocketReset = "reset"
+
+	//El servidor cerro la suscripcion porque el cliente no leia los cambios a tiempo
+	SocketUnsubscribed = "unsubscribed"
+)
+
+// Limites de la conexion
+const (
+	//Tiempo maximo para escribir un mensaje
+	socketWriteWait = 10 * time.Second
+
+	//Tiempo maximo sin recibir nada del cliente, los pongs cuentan
+	socketPongWait = 60 * time.Second
+
+	//Cada cuanto se envia un ping, debe ser menor a socketPongWait
+	socketPingPeriod = socketPongWait * 9 / 10
+
+	//Tamaño maximo de un comando
+	socketMaxMessage = 1 << 20
+
+	//Cantidad de mensajes pendientes de escribir
+	socketBuffer = 64
+)
+
+// Se crea una estructura para leer los comandos que envia el cliente
+type SocketCommand struct {
+	//Identificador elegido por el cliente, se devuelve en el ack
+	ID   string `json:"id"`
+	Type string `json:"type"`
+
+	//Tarea de update, patch y delete, y la version esperada (0 no verifica la version)
+	TaskID  int `json:"task_id"`
+	Version int `json:"version"`
+
+	//Tarea completa de create y update, con el mismo formato que el body de los handlers HTTP
+	Task json.RawMessage `json:"task"`
+
+	//Campos a modificar de patch
+	Fields map[string]any `json:"fields"`
+
+	//Que pasa con las subtareas en delete: reparent (por defecto) o cascade
+	Children string `json:"children"`
+
+	//Filtros de subscribe y el cursor desde el que se retoma, vacio solo recibe los cambios nuevos
+	TaskIDs []int  `json:"task_ids"`
+	Done    *bool  `json:"done"`
+	Since   string `json:"since"`
+}
+
+// Se crea una estructura para enviar los mensajes del servidor en forma de JSON
+type SocketMessage struct {
+	Type string `json:"type"`
+
+	//Campos de un ack, si el comando fallo tiene el problema en lugar del mensaje
+	ID      string            `json:"id,omitempty"`
+	Status  int               `json:"status,omitempty"`
+	Message string            `json:"message,omitempty"`
+	Data    *TaskResponse     `json:"data,omitempty"`
+	Problem *response.Problem `json:"problem,omitempty"`
+
+	//Campo de un change
+	Change *ChangeResponse `json:"change,omitempty"`
+}
+
+// Por defecto solo se aceptan conexiones desde el mismo origen
+var socketUpgrader = websocket.Upgrader{}
+
+// Conexion de un cliente con el WebSocket
+type taskSocket struct {
+	sv   internal.TaskService
+	conn *websocket.Conn
+
+	//Mensajes pendientes de escribir, solo el escritor escribe en la conexion
+	out chan SocketMessage
+
+	//Cancela la suscripcion actual, es nil si no hay suscripcion
+	//Solo lo usa el lector de comandos
+	unsubscribe context.CancelFunc
+}
+
+// --------------------- HANDLER DE TASKSOCKET ---------------------
+func (d *TaskHandler) TaskSocket() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		// request
+		// Paso 1: Convertir la conexion en un WebSocket, si falla el upgrader ya envio el error
+		conn, err := socketUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		// process
+		// Paso 2: Todos los comandos de la conexion se hacen a nombre del actor del request
+		socket := &taskSocket{
+			sv:   d.sv.WithActor(requestActor(r)),
+			conn: conn,
+			out:  make(chan SocketMessage, socketBuffer),
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		// Paso 3: Escribir los mensajes en otra goroutine y leer los comandos hasta que se cierre la conexion
+		written := make(chan struct{})
+		go func() {
+			socket.write(ctx)
+			close(written)
+		}()
+
+		socket.read(ctx)
+		cancel()
+		<-written
+	}
+}
+
+// Funcion para leer los comandos del cliente y responder cada uno con un ack, termina cuando se cierra la conexion
+func (s *taskSocket) read(ctx context.Context) {
+	(*s).conn.SetReadLimit(socketMaxMessage)
+	(*s).conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	(*s).conn.SetPongHandler(func(string) error {
+		return (*s).conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+
+	for {
+		_, bytes, err := (*s).conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var command SocketCommand
+		if err := json.Unmarshal(bytes, &command); err != nil {
+			s.fail(ctx, command, invalidRequest("invalid command"))
+			continue
+		}
+
+		s.dispatch(ctx, command)
+	}
+}
+
+// Funcion para escribir los mensajes pendientes y los pings, termina cuando se cancela ctx o falla la escritura
+func (s *taskSocket) write(ctx context.Context) {
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
+
+	//Si falla la escritura se cierra la conexion, asi tambien termina el lector
+	defer (*s).conn.Close()
+
+	for {
+		select {
+		case message := <-(*s).out:
+			(*s).conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			if err := (*s).conn.WriteJSON(message); err != nil {
+				return
+			}
+		case <-ping.C:
+			if err := (*s).conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			(*s).conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(socketWriteWait))
+			return
+		}
+	}
+}
+
+// Funcion para dejar un mensaje pendiente de escribir, si se cierra la conexion se descarta
+func (s *taskSocket) send(ctx context.Context, message SocketMessage) {
+	select {
+	case (*s).out <- message:
+	case <-ctx.Done():
+	}
+}
+
+// Funcion para responder un comando
+func (s *taskSocket) ack(ctx context.Context, command SocketCommand, code int, message string, task *internal.Task) {
+	ack := SocketMessage{
+		Type:    SocketAck,
+		ID:      command.ID,
+		Status:  code,
+		Message: message,
+	}
+	if task != nil {
+		data := newTaskResponse(*task)
+		ack.Data = &data
+	}
+
+	s.send(ctx, ack)
+}
+
+// Funcion para responder un comando que fallo con el problema que corresponde al error
+func (s *taskSocket) fail(ctx context.Context, command SocketCommand, err error) {
+	problem := newProblem(err)
+	s.send(ctx, SocketMessage{
+		Type:    SocketAck,
+		ID:      command.ID,
+		Status:  problem.Status,
+		Problem: &problem,
+	})
+}
+
+// Funcion para ejecutar un comando con el mismo metodo del servicio que usa el handler HTTP equivalente
+func (s *taskSocket) dispatch(ctx context.Context, command SocketCommand) {
+	switch command.Type {
+	case SocketSubscribe:
+		s.subscribe(ctx, command)
+	case SocketUnsubscribe:
+		s.stopSubscription()
+		s.ack(ctx, command, http.StatusOK, "unsubscribed", nil)
+	case SocketCreate:
+		s.create(ctx, command)
+	case SocketUpdate:
+		s.update(ctx, command)
+	case SocketPatch:
+		s.patch(ctx, command)
+	case SocketDelete:
+		s.delete(ctx, command)
+	default:
+		s.fail(ctx, command, invalidRequest("invalid command type"))
+	}
+}
+
+// Funcion para el comando subscribe, reemplaza la suscripcion anterior
+func (s *taskSocket) subscribe(ctx context.Context, command SocketCommand) {
+	s.stopSubscription()
+
+	subCtx, cancel := context.WithCancel(ctx)
+	subscription, err := (*s).sv.Subscribe(subCtx, command.Since, internal.ChangeFilter{TaskIDs: command.TaskIDs, Done: command.Done})
+	if err != nil {
+		cancel()
+		s.fail(ctx, command, err)
+		return
+	}
+	(*s).unsubscribe = cancel
+
+	//El ack se envia antes que los cambios de la suscripcion
+	s.ack(ctx, command, http.StatusOK, "subscribed", nil)
+	go s.forward(subCtx, subscription)
+}
+
+// Funcion para cancelar la suscripcion actual, si no hay suscripcion no hace nada
+func (s *taskSocket) stopSubscription() {
+	if (*s).unsubscribe != nil {
+		(*s).unsubscribe()
+		(*s).unsubscribe = nil
+	}
+}
+
+// Funcion para enviar los cambios de una suscripcion hasta que se cancele
+func (s *taskSocket) forward(ctx context.Context, subscription internal.ChangeSubscription) {
+	if subscription.Gap {
+		s.send(ctx, SocketMessage{Type: SocketReset})
+	}
+
+	for {
+		select {
+		case change, ok := <-subscription.Changes:
+			if !ok {
+				//Si no se cancelo la suscripcion la cerro el servicio porque el cliente no leia a tiempo
+				if ctx.Err() == nil {
+					s.send(ctx, SocketMessage{Type: SocketUnsubscribed})
+				}
+				return
+			}
+			data := newChangeResponse(change)
+			s.send(ctx, SocketMessage{Type: SocketChange, Change: &data})
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Funcion para el comando create, equivalente a CreateTask
+func (s *taskSocket) create(ctx context.Context, command SocketCommand) {
+	body, err := decodeTaskRequest(command.Task)
+	if err != nil {
+		s.fail(ctx, command, err)
+		return
+	}
+
+	task, err := newTaskFromRequest(body)
+	if err != nil {
+		s.fail(ctx, command, err)
+		return
+	}
+
+	if err = (*s).sv.Save(&task); err != nil {
+		s.fail(ctx, command, err)
+		return
+	}
+
+	s.ack(ctx, command, http.StatusCreated, "task created successfully", &task)
+}
+
+// Funcion para el comando update, equivalente a UpdateTask
+func (s *taskSocket) update(ctx context.Context, command SocketCommand) {
+	body, err := decodeTaskRequest(command.Task)
+	if err != nil {
+		s.fail(ctx, command, err)
+		return
+	}
+
+	task, err := newTaskFromRequest(body)
+	if err != nil {
+		s.fail(ctx, command, err)
+		return
+	}
+	task.ID = command.TaskID
+	task.Version = command.Version
+
+	//El servicio devuelve la tarea guardada con su nueva version
+	if task, err = (*s).sv.Update(task); err != nil {
+		s.fail(ctx, command, err)
+		return
+	}
+
+	s.ack(ctx, command, http.StatusOK, "task updated", &task)
+}
+
+// Funcion para el comando patch, equivalente a UpdatePartialTask
+func (s *taskSocket) patch(ctx context.Context, command SocketCommand) {
+	if command.Fields == nil {
+		s.fail(ctx, command, invalidRequest("invalid request body"))
+		return
+	}
+
+	patch, err := internal.ParseTaskPatch(command.Fields)
+	if err != nil {
+		s.fail(ctx, command, err)
+		return
+	}
+
+	//El servicio devuelve la tarea guardada con su nueva version
+	task, err := (*s).sv.UpdatePartial(command.TaskID, command.Version, patch)
+	if err != nil {
+		s.fail(ctx, command, err)
+		return
+	}
+
+	s.ack(ctx, command, http.StatusOK, "task updated", &task)
+}
+
+// Funcion para el comando delete, equivalente a DeleteTask
+func (s *taskSocket) delete(ctx context.Context, command SocketCommand) {
+	remove := (*s).sv.Delete
+	switch command.Children {
+	case "", "reparent":
+	case "cascade":
+		remove = (*s).sv.DeleteCascade
+	default:
+		s.fail(ctx, command, invalidRequest("invalid children"))
+		return
+	}
+
+	if err := remove(command.TaskID, command.Version); err != nil {
+		s.fail(ctx, command, err)
+		return
+	}
+
+	s.ack(ctx, command, http.StatusNoContent, "task deleted", nil)
+}
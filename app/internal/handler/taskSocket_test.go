@@ -0,0 +1,161 @@
+package handler_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/handler"
+	"github.com/Taks/pkg/response"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// Test del WebSocket de tareas
+func TestTaskSocket(t *testing.T) {
+
+	//Funcion para conectarse al WebSocket de un handler nuevo
+	connect := func(t *testing.T) (conn *websocket.Conn) {
+		h, _ := newTestHandler(nil, 0)
+		server := httptest.NewServer(h.TaskSocket())
+		t.Cleanup(server.Close)
+
+		header := http.Header{}
+		header.Set("X-Actor", "ana")
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+		require.NoError(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return
+	}
+
+	//Funcion para leer el siguiente mensaje del servidor
+	next := func(t *testing.T, conn *websocket.Conn) (message handler.SocketMessage) {
+		require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+		require.NoError(t, conn.ReadJSON(&message))
+		return
+	}
+
+	//Funcion para enviar un comando y leer su ack, junto con los cambios que lleguen antes o despues del ack
+	send := func(t *testing.T, conn *websocket.Conn, command string, changes int) (ack handler.SocketMessage, received []handler.ChangeResponse) {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(command)))
+		for ack.Type == "" || len(received) < changes {
+			message := next(t, conn)
+			switch message.Type {
+			case handler.SocketAck:
+				ack = message
+			case handler.SocketChange:
+				received = append(received, *message.Change)
+			}
+		}
+		return
+	}
+
+	//Test los comandos se responden con un ack y los cambios llegan a la suscripcion
+	t.Run("Success - Commands are acknowledged and changes are received", func(t *testing.T) {
+
+		//arrange
+		conn := connect(t)
+		subscribed, _ := send(t, conn, `{"id": "s1", "type": "subscribe"}`, 0)
+
+		//act
+		created, createdChanges := send(t, conn, `{"id": "c1", "type": "create", "task": {"tittle": "task 1", "description": "", "done": false}}`, 1)
+		patched, patchedChanges := send(t, conn, `{"id": "c2", "type": "patch", "task_id": 1, "version": 1, "fields": {"done": true}}`, 1)
+		updated, _ := send(t, conn, `{"id": "c3", "type": "update", "task_id": 1, "task": {"tittle": "task 1 renamed", "description": "new", "done": true}}`, 1)
+		deleted, deletedChanges := send(t, conn, `{"id": "c4", "type": "delete", "task_id": 1, "version": 3}`, 1)
+
+		//assert
+		require.Equal(t, handler.SocketMessage{Type: handler.SocketAck, ID: "s1", Status: http.StatusOK, Message: "subscribed"}, subscribed)
+
+		require.Equal(t, "c1", created.ID)
+		require.Equal(t, http.StatusCreated, created.Status)
+		require.Equal(t, 1, created.Data.ID)
+		require.Equal(t, internal.ChangeCreated, createdChanges[0].Type)
+		require.Equal(t, 1, createdChanges[0].TaskID)
+
+		require.Equal(t, "c2", patched.ID)
+		require.Equal(t, http.StatusOK, patched.Status)
+		require.True(t, patched.Data.Done)
+		require.Equal(t, 2, patched.Data.Version)
+		require.Equal(t, internal.ChangeUpdated, patchedChanges[0].Type)
+
+		require.Equal(t, http.StatusOK, updated.Status)
+		require.Equal(t, "task 1 renamed", updated.Data.Tittle)
+		require.Equal(t, 3, updated.Data.Version)
+
+		require.Equal(t, "c4", deleted.ID)
+		require.Equal(t, http.StatusNoContent, deleted.Status)
+		require.Nil(t, deleted.Data)
+		require.Equal(t, internal.ChangeDeleted, deletedChanges[0].Type)
+	})
+
+	//Test una suscripcion con filtro solo recibe los cambios de sus tareas y al cancelarla no recibe mas
+	t.Run("Success - Filtered subscription and unsubscribe", func(t *testing.T) {
+
+		//arrange
+		conn := connect(t)
+		send(t, conn, `{"id": "s1", "type": "subscribe", "task_ids": [2]}`, 0)
+
+		//act
+		send(t, conn, `{"id": "c1", "type": "create", "task": {"tittle": "task 1", "description": "", "done": false}}`, 0)
+		_, changes := send(t, conn, `{"id": "c2", "type": "create", "task": {"tittle": "task 2", "description": "", "done": false}}`, 1)
+		unsubscribed, _ := send(t, conn, `{"id": "u1", "type": "unsubscribe"}`, 0)
+		_, afterPatch := send(t, conn, `{"id": "c3", "type": "patch", "task_id": 2, "fields": {"done": true}}`, 0)
+		last, afterLast := send(t, conn, `{"id": "c4", "type": "patch", "task_id": 2, "fields": {"tittle": "task 2 renamed"}}`, 0)
+
+		//assert
+		require.Len(t, changes, 1)
+		require.Equal(t, 2, changes[0].TaskID)
+		require.Equal(t, http.StatusOK, unsubscribed.Status)
+		require.Equal(t, "c4", last.ID)
+		require.Empty(t, afterPatch, "no changes arrive after unsubscribe")
+		require.Empty(t, afterLast, "no changes arrive after unsubscribe")
+	})
+
+	//Test los acks de los errores tienen el mismo problema que los handlers HTTP
+	t.Run("Error - Acks use the HTTP error mapping", func(t *testing.T) {
+
+		//arrange
+		conn := connect(t)
+		send(t, conn, `{"id": "c0", "type": "create", "task": {"tittle": "task 1", "description": "", "done": false}}`, 0)
+
+		cases := []struct {
+			command     string
+			status      int
+			problemType string
+		}{
+			{`{"id": "e1", "type": "patch", "task_id": 99, "fields": {"done": true}}`, http.StatusNotFound, "/problems/task-not-found"},
+			{`{"id": "e2", "type": "create", "task": {"tittle": "task 1", "description": "", "done": false}}`, http.StatusConflict, "/problems/task-duplicated"},
+			{`{"id": "e3", "type": "patch", "task_id": 1, "version": 7, "fields": {"done": true}}`, http.StatusPreconditionFailed, "/problems/version-conflict"},
+			{`{"id": "e4", "type": "create", "task": {"tittle": "task 2", "done": false}}`, http.StatusBadRequest, "/problems/validation-failed"},
+			{`{"id": "e5", "type": "update", "task_id": 1, "task": {"tittle": "task 1", "description": "", "done": false, "priority": "later"}}`, http.StatusBadRequest, "/problems/validation-failed"},
+			{`{"id": "e6", "type": "delete", "task_id": 99}`, http.StatusNotFound, "/problems/task-not-found"},
+			{`{"id": "e7", "type": "delete", "task_id": 1, "children": "orphan"}`, http.StatusBadRequest, "/problems/invalid-request"},
+			{`{"id": "e8", "type": "subscribe", "since": "not-a-cursor"}`, http.StatusBadRequest, "/problems/invalid-field"},
+			{`{"id": "e9", "type": "archive"}`, http.StatusBadRequest, "/problems/invalid-request"},
+			{`not json`, http.StatusBadRequest, "/problems/invalid-request"},
+		}
+
+		for _, c := range cases {
+			//act
+			ack, _ := send(t, conn, c.command, 0)
+
+			//assert
+			require.Equal(t, c.status, ack.Status, c.command)
+			require.NotNil(t, ack.Problem, c.command)
+			require.Equal(t, c.problemType, ack.Problem.Type, c.command)
+			require.Equal(t, c.status, ack.Problem.Status, c.command)
+			require.Empty(t, ack.Message, c.command)
+		}
+
+		//Los errores de validacion tienen el error de cada campo
+		ack, _ := send(t, conn, `{"id": "e10", "type": "create", "task": {"tittle": "task 3", "priority": "later"}}`, 0)
+		require.Equal(t, []response.FieldProblem{
+			{Field: "description", Detail: "field is required"},
+			{Field: "done", Detail: "field is required"},
+			{Field: "priority", Detail: "invalid priority"},
+		}, ack.Problem.Errors)
+	})
+}
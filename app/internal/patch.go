@@ -0,0 +1,223 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Taks/internal/tools"
+)
+
+/*
+	Este archivo contiene la actualizacion parcial de una tarea.
+
+	El handler recibe los campos como un map[string]any y los convierte en un TaskPatch con
+	ParseTaskPatch, que valida los nombres y los tipos de todos los campos en un solo lugar.
+	Los repositorios solo reciben el TaskPatch ya validado y lo aplican con Apply, asi todas las
+	implementaciones aceptan exactamente los mismos campos.
+*/
+
+// Valor de un campo de una actualizacion parcial, si Set es false el campo no se modifica
+type PatchField[T any] struct {
+	Set   bool
+	Value T
+}
+
+// Funcion para crear un campo que se modifica con el valor recibido
+// En los campos opcionales un valor nil borra el valor de la tarea
+func Set[T any](value T) PatchField[T] {
+	return PatchField[T]{Set: true, Value: value}
+}
+
+// Actualizacion parcial de una tarea, solo se modifican los campos con Set en true
+type TaskPatch struct {
+	Tittle      PatchField[string]
+	Description PatchField[string]
+	Done        PatchField[bool]
+	StartAt     PatchField[*time.Time]
+	DueAt       PatchField[*time.Time]
+	Priority    PatchField[Priority]
+	Tags        PatchField[[]string]
+	ParentID    PatchField[*int]
+	BlockedBy   PatchField[[]int]
+	Recurrence  PatchField[*Recurrence]
+}
+
+// Campo de una actualizacion parcial: las reglas de su valor y como se asigna en el TaskPatch
+// set solo recibe valores que cumplen las reglas
+type taskPatchField struct {
+	rules tools.FieldRules
+	set   func(patch *TaskPatch, value any)
+}
+
+// Campos de una actualizacion parcial, con el nombre en snake_case que usa el body de una tarea completa
+// Los campos obligatorios de la tarea no aceptan null, en los opcionales null borra el valor
+var taskPatchFields = []taskPatchField{
+	{
+		rules: tools.FieldRules{Field: "tittle", Rules: []tools.Rule{tools.NotNull(), tools.String()}},
+		set:   func(patch *TaskPatch, value any) { patch.Tittle = Set(value.(string)) },
+	},
+	{
+		rules: tools.FieldRules{Field: "description", Rules: []tools.Rule{tools.NotNull(), tools.String()}},
+		set:   func(patch *TaskPatch, value any) { patch.Description = Set(value.(string)) },
+	},
+	{
+		rules: tools.FieldRules{Field: "done", Rules: []tools.Rule{tools.NotNull(), tools.Bool()}},
+		set:   func(patch *TaskPatch, value any) { patch.Done = Set(value.(bool)) },
+	},
+	{
+		rules: tools.FieldRules{Field: "start_at", Rules: []tools.Rule{tools.Time()}},
+		set:   func(patch *TaskPatch, value any) { patch.StartAt = Set(patchTime(value)) },
+	},
+	{
+		rules: tools.FieldRules{Field: "due_at", Rules: []tools.Rule{tools.Time()}},
+		set:   func(patch *TaskPatch, value any) { patch.DueAt = Set(patchTime(value)) },
+	},
+	{
+		rules: tools.FieldRules{Field: "priority", Rules: []tools.Rule{tools.NotNull(), tools.String(), PriorityRule()}},
+		set: func(patch *TaskPatch, value any) {
+			priority, _ := ParsePriority(value.(string))
+			patch.Priority = Set(priority)
+		},
+	},
+	{
+		rules: tools.FieldRules{Field: "tags", Rules: []tools.Rule{tools.StringList()}},
+		set: func(patch *TaskPatch, value any) {
+			patch.Tags = Set(patchList(value, func(item any) string { return item.(string) }))
+		},
+	},
+	{
+		rules: tools.FieldRules{Field: "parent_id", Rules: []tools.Rule{tools.Integer()}},
+		set: func(patch *TaskPatch, value any) {
+			var parentID *int
+			if id, ok := tools.Int(value); ok {
+				parentID = &id
+			}
+			patch.ParentID = Set(parentID)
+		},
+	},
+	{
+		rules: tools.FieldRules{Field: "blocked_by", Rules: []tools.Rule{tools.IntList()}},
+		set: func(patch *TaskPatch, value any) {
+			patch.BlockedBy = Set(patchList(value, func(item any) int {
+				id, _ := tools.Int(item)
+				return id
+			}))
+		},
+	},
+	{
+		rules: tools.FieldRules{Field: "recurrence", Rules: []tools.Rule{tools.String(), RecurrenceRule()}},
+		set: func(patch *TaskPatch, value any) {
+			var recurrence *Recurrence
+			if rule, ok := value.(string); ok {
+				recurrence, _ = ParseRecurrence(rule)
+			}
+			patch.Recurrence = Set(recurrence)
+		},
+	},
+}
+
+// Funcion para buscar el campo de una clave del body
+// Acepta el nombre en snake_case, camelCase o PascalCase sin distinguir mayusculas, asi "due_at", "dueAt" y "DueAt"
+// son el mismo campo, pero no otras formas de separar el nombre como "d_ueat"
+func findPatchField(key string) (field taskPatchField, ok bool) {
+	key = strings.ToLower(key)
+	for _, field = range taskPatchFields {
+		if key == field.rules.Field || key == strings.ReplaceAll(field.rules.Field, "_", "") {
+			ok = true
+			return
+		}
+	}
+	return
+}
+
+// Funcion para crear una actualizacion parcial a partir de los campos de un body JSON
+// Los campos desconocidos, repetidos o con un tipo invalido se informan todos juntos: el error
+// envuelve ErrTaskInvalidField y un tools.ValidationError con un tools.FieldError por campo
+func ParseTaskPatch(fields map[string]any) (patch TaskPatch, err error) {
+	//Los campos se leen ordenados para que los errores siempre tengan el mismo orden
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var v tools.Validator
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		field, ok := findPatchField(key)
+		if !ok {
+			v.Add(key, "unknown field")
+			continue
+		}
+
+		if seen[field.rules.Field] {
+			v.Add(key, "duplicated field")
+			continue
+		}
+		seen[field.rules.Field] = true
+
+		if msg := field.rules.Check(fields[key]); msg != "" {
+			v.Add(key, msg)
+			continue
+		}
+		field.set(&patch, fields[key])
+	}
+
+	if err = invalidFields(&v); err != nil {
+		patch = TaskPatch{}
+		return
+	}
+	return
+}
+
+// Funcion para obtener el error de los campos invalidos acumulados, nil si no hay errores
+// Envuelve ErrTaskInvalidField y el tools.ValidationError con el detalle de cada campo
+func invalidFields(v *tools.Validator) (err error) {
+	if validationErr := v.Err(); validationErr != nil {
+		err = fmt.Errorf("%w: %w", ErrTaskInvalidField, validationErr)
+	}
+	return
+}
+
+// Metodo para aplicar la actualizacion parcial sobre una tarea, los campos sin Set no cambian
+func (p TaskPatch) Apply(task Task) (patched Task) {
+	patched = task
+	applyPatch(&patched.Tittle, p.Tittle)
+	applyPatch(&patched.Description, p.Description)
+	applyPatch(&patched.Done, p.Done)
+	applyPatch(&patched.StartAt, p.StartAt)
+	applyPatch(&patched.DueAt, p.DueAt)
+	applyPatch(&patched.Priority, p.Priority)
+	applyPatch(&patched.Tags, p.Tags)
+	applyPatch(&patched.ParentID, p.ParentID)
+	applyPatch(&patched.BlockedBy, p.BlockedBy)
+	applyPatch(&patched.Recurrence, p.Recurrence)
+	return
+}
+
+// Funcion para asignar el valor de un campo de la actualizacion si se envio
+func applyPatch[T any](target *T, field PatchField[T]) {
+	if field.Set {
+		*target = field.Value
+	}
+}
+
+// Funcion para leer una fecha de JSON ya validada con tools.Time, null borra la fecha
+func patchTime(value any) (date *time.Time) {
+	if text, ok := value.(string); ok {
+		parsed, _ := time.Parse(time.RFC3339, text)
+		date = &parsed
+	}
+	return
+}
+
+// Funcion para leer una lista de JSON ya validada, null es una lista vacia
+func patchList[T any](value any, item func(value any) T) (list []T) {
+	values, _ := value.([]any)
+	for _, value := range values {
+		list = append(list, item(value))
+	}
+	return
+}
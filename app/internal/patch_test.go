@@ -0,0 +1,159 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/tools"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de la conversion de los campos de un body JSON en una actualizacion parcial
+func TestParseTaskPatch(t *testing.T) {
+
+	//Test los campos con los tipos que llegan de JSON, en snake_case, camelCase o PascalCase y sin distinguir mayusculas
+	t.Run("Success - Fields from JSON", func(t *testing.T) {
+
+		//arrange
+		fields := map[string]any{
+			"Tittle":     "task 1",
+			"done":       true,
+			"dueAt":      "2024-05-01T10:00:00Z",
+			"PRIORITY":   "urgent",
+			"tags":       []any{"Work", "home"},
+			"parent_id":  float64(1),
+			"BlockedBy":  []any{float64(2), float64(3)},
+			"recurrence": "FREQ=DAILY",
+		}
+
+		//act
+		patch, err := internal.ParseTaskPatch(fields)
+
+		//assert
+		require.NoError(t, err)
+		require.Equal(t, internal.Set("task 1"), patch.Tittle)
+		require.False(t, patch.Description.Set)
+		require.Equal(t, internal.Set(true), patch.Done)
+		require.False(t, patch.StartAt.Set)
+		require.True(t, patch.DueAt.Set)
+		require.True(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC).Equal(*patch.DueAt.Value))
+		require.Equal(t, internal.Set(internal.PriorityUrgent), patch.Priority)
+		require.Equal(t, internal.Set([]string{"Work", "home"}), patch.Tags)
+		require.Equal(t, 1, *patch.ParentID.Value)
+		require.Equal(t, internal.Set([]int{2, 3}), patch.BlockedBy)
+		require.Equal(t, internal.FrequencyDaily, patch.Recurrence.Value.Frequency)
+	})
+
+	//Test null borra los campos opcionales
+	t.Run("Success - Null clears optional fields", func(t *testing.T) {
+
+		//act
+		patch, err := internal.ParseTaskPatch(map[string]any{"start_at": nil, "tags": nil, "parent_id": nil, "blocked_by": nil, "recurrence": nil})
+
+		//assert
+		require.NoError(t, err)
+		require.Equal(t, internal.Set[*time.Time](nil), patch.StartAt)
+		require.Equal(t, internal.Set[[]string](nil), patch.Tags)
+		require.Equal(t, internal.Set[*int](nil), patch.ParentID)
+		require.Equal(t, internal.Set[[]int](nil), patch.BlockedBy)
+		require.Equal(t, internal.Set[*internal.Recurrence](nil), patch.Recurrence)
+	})
+
+	//Test los campos desconocidos, repetidos o con un tipo invalido se informan todos, en orden
+	t.Run("Error - Unknown fields and invalid types", func(t *testing.T) {
+
+		//arrange
+		fields := map[string]any{
+			"tittle":      1.0,
+			"description": 2.0,
+			"done":        "yes",
+			"Done":        true,
+			"start_at":    true,
+			"due_at":      "tomorrow",
+			"priority":    "later",
+			"tags":        []any{1.0},
+			"parent_id":   1.5,
+			"blocked_by":  []any{"1"},
+			"recurrence":  7.0,
+			"owner":       "luis",
+		}
+
+		//act
+		patch, err := internal.ParseTaskPatch(fields)
+
+		//assert
+		require.ErrorIs(t, err, internal.ErrTaskInvalidField)
+		require.Equal(t, internal.TaskPatch{}, patch)
+
+		var validationErr *tools.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []*tools.FieldError{
+			{Field: "blocked_by", Msg: tools.MsgIntList},
+			{Field: "description", Msg: tools.MsgString},
+			{Field: "done", Msg: "duplicated field"},
+			{Field: "due_at", Msg: tools.MsgTime},
+			{Field: "owner", Msg: "unknown field"},
+			{Field: "parent_id", Msg: tools.MsgInteger},
+			{Field: "priority", Msg: internal.MsgInvalidPriority},
+			{Field: "recurrence", Msg: tools.MsgString},
+			{Field: "start_at", Msg: tools.MsgTime},
+			{Field: "tags", Msg: tools.MsgStringList},
+			{Field: "tittle", Msg: tools.MsgString},
+		}, validationErr.Errors)
+	})
+
+	//Test solo se aceptan las formas snake_case, camelCase y PascalCase de cada campo, no otros guiones bajos
+	t.Run("Error - Unknown key forms", func(t *testing.T) {
+
+		//act
+		_, err := internal.ParseTaskPatch(map[string]any{"t_ittle": "task 1", "d_u_e_at": nil, "DUE_AT": nil, "_done": true})
+
+		//assert
+		var validationErr *tools.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []*tools.FieldError{
+			{Field: "_done", Msg: "unknown field"},
+			{Field: "d_u_e_at", Msg: "unknown field"},
+			{Field: "t_ittle", Msg: "unknown field"},
+		}, validationErr.Errors)
+	})
+
+	//Test los campos obligatorios de la tarea no se pueden borrar con null
+	t.Run("Error - Null required fields", func(t *testing.T) {
+
+		//act
+		_, err := internal.ParseTaskPatch(map[string]any{"tittle": nil, "description": nil, "done": nil, "priority": nil})
+
+		//assert
+		var validationErr *tools.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []*tools.FieldError{
+			{Field: "description", Msg: tools.MsgNotNull},
+			{Field: "done", Msg: tools.MsgNotNull},
+			{Field: "priority", Msg: tools.MsgNotNull},
+			{Field: "tittle", Msg: tools.MsgNotNull},
+		}, validationErr.Errors)
+	})
+}
+
+// Test de la aplicacion de una actualizacion parcial sobre una tarea
+func TestTaskPatchApply(t *testing.T) {
+
+	//Test solo cambian los campos con Set, los demas quedan como estaban
+	t.Run("Success - Only set fields", func(t *testing.T) {
+
+		//arrange
+		dueAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		parentID := 1
+		task := internal.Task{ID: 2, Tittle: "task 2", Description: "keep", DueAt: &dueAt, ParentID: &parentID, Tags: []string{"home"}, Version: 3}
+		patch := internal.TaskPatch{Done: internal.Set(true), DueAt: internal.Set[*time.Time](nil), Tags: internal.Set([]string{"work"})}
+
+		//act
+		patched := patch.Apply(task)
+
+		//assert
+		require.Equal(t, internal.Task{ID: 2, Tittle: "task 2", Description: "keep", Done: true, ParentID: &parentID, Tags: []string{"work"}, Version: 3}, patched)
+		require.Equal(t, &dueAt, task.DueAt)
+	})
+}
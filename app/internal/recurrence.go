@@ -0,0 +1,426 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Taks/internal/tools"
+)
+
+/*
+	Este archivo contiene las reglas de repeticion de las tareas recurrentes.
+
+	Las reglas siguen el formato RRULE de iCalendar (RFC 5545), con un subconjunto de sus partes:
+
+		FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,WE;COUNT=10
+
+	Cuando se termina una tarea recurrente el repositorio crea la siguiente ocurrencia con la
+	fecha limite calculada por NextOccurrence.
+*/
+
+// Frecuencia de una regla de repeticion
+type Frequency string
+
+const (
+	FrequencyDaily   Frequency = "DAILY"
+	FrequencyWeekly  Frequency = "WEEKLY"
+	FrequencyMonthly Frequency = "MONTHLY"
+	FrequencyYearly  Frequency = "YEARLY"
+)
+
+// Cantidad maxima de periodos que se revisan buscando la siguiente ocurrencia
+// Evita un bucle infinito con reglas que no vuelven a cumplirse, por ejemplo el 31 de febrero
+const maxRecurrencePeriods = 1000
+
+// Nombres de los dias de la semana en RRULE, en el orden de time.Weekday
+var weekdayNames = []string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// Regla de repeticion de una tarea
+type Recurrence struct {
+	//Frecuencia con la que se repite la tarea
+	Frequency Frequency
+
+	//Cada cuantos periodos se repite, 0 equivale a 1
+	Interval int
+
+	//Dias de la semana en que se repite, ordenados
+	ByWeekday []time.Weekday
+
+	//Dias del mes en que se repite, los negativos cuentan desde el final del mes (-1 es el ultimo dia)
+	ByMonthDay []int
+
+	//Fecha despues de la cual la tarea no se repite mas, es opcional
+	Until *time.Time
+
+	//Cantidad de ocurrencias que quedan contando la tarea actual, 0 es sin limite
+	//La siguiente ocurrencia se crea con Count - 1, asi con Count 1 la tarea ya no se repite
+	Count int
+}
+
+// Mensaje del error de validacion de una regla de repeticion invalida
+const MsgInvalidRecurrence = "invalid recurrence"
+
+// Regla para que un string sea una regla de repeticion valida, los valores que no son string no se validan
+func RecurrenceRule() tools.Rule {
+	return tools.Format(MsgInvalidRecurrence, func(text string) bool {
+		_, err := ParseRecurrence(text)
+		return err == nil
+	})
+}
+
+// Funcion para leer una regla de repeticion en formato RRULE, vacio no es una regla y devuelve nil
+// El prefijo "RRULE:" es opcional
+func ParseRecurrence(rule string) (recurrence *Recurrence, err error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	if rule == "" {
+		return
+	}
+
+	parsed := Recurrence{}
+	for _, part := range strings.Split(rule, ";") {
+		key, value, found := strings.Cut(part, "=")
+		if !found {
+			err = fmt.Errorf("%w: invalid recurrence part %q", ErrTaskInvalidField, part)
+			return
+		}
+
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			parsed.Frequency = Frequency(strings.ToUpper(value))
+		case "INTERVAL":
+			parsed.Interval, err = strconv.Atoi(value)
+		case "BYDAY":
+			for _, name := range strings.Split(value, ",") {
+				weekday, ok := parseWeekday(name)
+				if !ok {
+					err = fmt.Errorf("unknown weekday %q", name)
+					break
+				}
+				parsed.ByWeekday = append(parsed.ByWeekday, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, number := range strings.Split(value, ",") {
+				var day int
+				if day, err = strconv.Atoi(number); err != nil {
+					break
+				}
+				parsed.ByMonthDay = append(parsed.ByMonthDay, day)
+			}
+		case "UNTIL":
+			var until time.Time
+			until, err = parseUntil(value)
+			parsed.Until = &until
+		case "COUNT":
+			parsed.Count, err = strconv.Atoi(value)
+		default:
+			err = fmt.Errorf("unknown part %q", key)
+		}
+
+		if err != nil {
+			err = fmt.Errorf("%w: invalid recurrence %q: %v", ErrTaskInvalidField, rule, err)
+			return
+		}
+	}
+
+	if err = parsed.Validate(); err != nil {
+		return
+	}
+
+	parsed = NormalizeRecurrence(parsed)
+	recurrence = &parsed
+	return
+}
+
+// Metodo para validar una regla de repeticion
+func (r Recurrence) Validate() (err error) {
+	switch {
+	case r.Frequency != FrequencyDaily && r.Frequency != FrequencyWeekly &&
+		r.Frequency != FrequencyMonthly && r.Frequency != FrequencyYearly:
+		err = fmt.Errorf("%w: unknown recurrence frequency %q", ErrTaskInvalidField, r.Frequency)
+	case r.Interval < 0:
+		err = fmt.Errorf("%w: invalid recurrence interval %d", ErrTaskInvalidField, r.Interval)
+	case r.Count < 0:
+		err = fmt.Errorf("%w: invalid recurrence count %d", ErrTaskInvalidField, r.Count)
+	case r.Count > 0 && r.Until != nil:
+		//Igual que en RRULE, no se pueden usar las dos a la vez
+		err = fmt.Errorf("%w: recurrence can't have both until and count", ErrTaskInvalidField)
+	}
+	if err != nil {
+		return
+	}
+
+	for _, weekday := range r.ByWeekday {
+		if weekday < time.Sunday || weekday > time.Saturday {
+			err = fmt.Errorf("%w: invalid recurrence weekday %d", ErrTaskInvalidField, weekday)
+			return
+		}
+	}
+
+	for _, day := range r.ByMonthDay {
+		if day == 0 || day < -31 || day > 31 {
+			err = fmt.Errorf("%w: invalid recurrence month day %d", ErrTaskInvalidField, day)
+			return
+		}
+	}
+	return
+}
+
+// Metodo para escribir la regla en formato RRULE, las partes van siempre en el mismo orden
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + string(r.Frequency)}
+
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+
+	if len(r.ByWeekday) > 0 {
+		names := make([]string, 0, len(r.ByWeekday))
+		for _, weekday := range r.ByWeekday {
+			names = append(names, weekdayNames[weekday])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(names, ","))
+	}
+
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, 0, len(r.ByMonthDay))
+		for _, day := range r.ByMonthDay {
+			days = append(days, strconv.Itoa(day))
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// Metodo para escribir la regla en JSON en formato RRULE
+func (r Recurrence) MarshalText() ([]byte, error) {
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+	return []byte(r.String()), nil
+}
+
+// Metodo para leer la regla de JSON en formato RRULE
+func (r *Recurrence) UnmarshalText(text []byte) (err error) {
+	parsed, err := ParseRecurrence(string(text))
+	if err != nil {
+		return
+	}
+	if parsed == nil {
+		err = fmt.Errorf("%w: empty recurrence", ErrTaskInvalidField)
+		return
+	}
+
+	*r = *parsed
+	return
+}
+
+// Metodo para calcular la primera ocurrencia posterior a after
+//
+// La hora del dia y la zona horaria se toman de after. Las semanas empiezan el lunes, como en RRULE.
+// Sin BYDAY ni BYMONTHDAY la regla se repite el mismo dia de la semana, del mes o del año que after;
+// las reglas anuales solo se repiten en el mes de after.
+// Devuelve false si la regla ya no tiene mas ocurrencias.
+func (r Recurrence) NextOccurrence(after time.Time) (next time.Time, ok bool) {
+	if r.Count == 1 {
+		return
+	}
+
+	interval := r.Interval
+	if interval == 0 {
+		interval = 1
+	}
+
+	for period := 0; period <= maxRecurrencePeriods; period += interval {
+		for _, candidate := range r.candidates(after, period) {
+			if !candidate.After(after) {
+				continue
+			}
+			if r.Until != nil && candidate.After(*r.Until) {
+				return
+			}
+
+			next, ok = candidate, true
+			return
+		}
+	}
+	return
+}
+
+// Metodo para obtener los dias del periodo numero period contando desde el de after, ordenados
+func (r Recurrence) candidates(after time.Time, period int) (days []time.Time) {
+	year, month, day := after.Date()
+	hour, min, sec := after.Clock()
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, after.Nanosecond(), after.Location())
+	}
+
+	switch r.Frequency {
+	case FrequencyDaily:
+		candidate := date(year, month, day+period)
+		if r.matchWeekday(candidate) && r.matchMonthDay(candidate) {
+			days = append(days, candidate)
+		}
+	case FrequencyWeekly:
+		//Lunes de la semana del periodo
+		offset := (int(after.Weekday()) + 6) % 7
+		monday := date(year, month, day-offset+7*period)
+		for i := 0; i < 7; i++ {
+			candidate := monday.AddDate(0, 0, i)
+			if len(r.ByWeekday) == 0 && candidate.Weekday() != after.Weekday() {
+				continue
+			}
+			if r.matchWeekday(candidate) && r.matchMonthDay(candidate) {
+				days = append(days, candidate)
+			}
+		}
+	case FrequencyMonthly:
+		days = r.monthCandidates(date(year, month+time.Month(period), 1), day)
+	case FrequencyYearly:
+		days = r.monthCandidates(date(year+period, month, 1), day)
+	}
+	return
+}
+
+// Metodo para obtener los dias de un mes que cumplen la regla, first es el primer dia del mes
+// Sin BYDAY ni BYMONTHDAY se usa el dia del mes day, si el mes no lo tiene se saltea
+func (r Recurrence) monthCandidates(first time.Time, day int) (days []time.Time) {
+	for candidate := first; candidate.Month() == first.Month(); candidate = candidate.AddDate(0, 0, 1) {
+		if len(r.ByWeekday) == 0 && len(r.ByMonthDay) == 0 && candidate.Day() != day {
+			continue
+		}
+		if r.matchWeekday(candidate) && r.matchMonthDay(candidate) {
+			days = append(days, candidate)
+		}
+	}
+	return
+}
+
+// Metodo para saber si un dia cumple con BYDAY, sin BYDAY todos los dias lo cumplen
+func (r Recurrence) matchWeekday(day time.Time) bool {
+	if len(r.ByWeekday) == 0 {
+		return true
+	}
+	for _, weekday := range r.ByWeekday {
+		if day.Weekday() == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+// Metodo para saber si un dia cumple con BYMONTHDAY, sin BYMONTHDAY todos los dias lo cumplen
+func (r Recurrence) matchMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+
+	//Cantidad de dias del mes, el dia 0 del mes siguiente es el ultimo de este mes
+	year, month, _ := day.Date()
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	for _, monthDay := range r.ByMonthDay {
+		if monthDay == day.Day() || (monthDay < 0 && last+monthDay+1 == day.Day()) {
+			return true
+		}
+	}
+	return false
+}
+
+// Expresion para reconocer el sufijo que se agrega al titulo de cada ocurrencia
+var occurrenceTitleSuffix = regexp.MustCompile(` \(\d{4}-\d{2}-\d{2}\)( #\d+)?$`)
+
+// Funcion para generar el titulo de una ocurrencia de una tarea recurrente
+//
+// Se quita el sufijo de la ocurrencia anterior y se agrega la fecha limite de la nueva, por ejemplo
+// "Regar las plantas (2026-10-25)". Si ese titulo ya existe, attempt (desde 2) agrega un numero: " #2".
+func OccurrenceTitle(title string, due time.Time, attempt int) string {
+	base := occurrenceTitleSuffix.ReplaceAllString(title, "")
+	occurrence := fmt.Sprintf("%s (%s)", base, due.Format("2006-01-02"))
+	if attempt > 1 {
+		occurrence = fmt.Sprintf("%s #%d", occurrence, attempt)
+	}
+	return occurrence
+}
+
+// Funcion para leer un dia de la semana en formato RRULE
+func parseWeekday(name string) (weekday time.Weekday, ok bool) {
+	for i, weekdayName := range weekdayNames {
+		if weekdayName == strings.ToUpper(strings.TrimSpace(name)) {
+			return time.Weekday(i), true
+		}
+	}
+	return
+}
+
+// Funcion para leer la fecha UNTIL, en formato iCalendar (con o sin hora) o RFC 3339
+// Una fecha sin hora incluye todo ese dia
+func parseUntil(value string) (until time.Time, err error) {
+	if until, err = time.Parse("20060102", value); err == nil {
+		until = until.Add(24*time.Hour - time.Nanosecond)
+		return
+	}
+
+	for _, layout := range []string{"20060102T150405Z", time.RFC3339} {
+		if until, err = time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+	err = fmt.Errorf("invalid until %q", value)
+	return
+}
+
+// Funcion para normalizar una regla de repeticion: intervalo 1 por defecto, dias sin repetir y ordenados
+// y UNTIL en UTC con precision de segundos, igual que en formato RRULE
+// Devuelve una copia, no modifica los dias de la regla original
+func NormalizeRecurrence(r Recurrence) Recurrence {
+	if r.Interval == 0 {
+		r.Interval = 1
+	}
+
+	if r.Until != nil {
+		until := r.Until.UTC().Truncate(time.Second)
+		r.Until = &until
+	}
+
+	weekdays := make([]time.Weekday, 0, len(r.ByWeekday))
+	seenWeekday := make(map[time.Weekday]bool)
+	for _, weekday := range r.ByWeekday {
+		if !seenWeekday[weekday] {
+			seenWeekday[weekday] = true
+			weekdays = append(weekdays, weekday)
+		}
+	}
+	sort.Slice(weekdays, func(i, j int) bool { return weekdays[i] < weekdays[j] })
+
+	monthDays := make([]int, 0, len(r.ByMonthDay))
+	seenMonthDay := make(map[int]bool)
+	for _, day := range r.ByMonthDay {
+		if !seenMonthDay[day] {
+			seenMonthDay[day] = true
+			monthDays = append(monthDays, day)
+		}
+	}
+	sort.Ints(monthDays)
+
+	r.ByWeekday, r.ByMonthDay = nil, nil
+	if len(weekdays) > 0 {
+		r.ByWeekday = weekdays
+	}
+	if len(monthDays) > 0 {
+		r.ByMonthDay = monthDays
+	}
+	return r
+}
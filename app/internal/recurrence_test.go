@@ -0,0 +1,119 @@
+package internal_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de las reglas de repeticion
+func TestRecurrence(t *testing.T) {
+	// Miercoles 7 de octubre de 2026 a las 9
+	after := time.Date(2026, 10, 7, 9, 0, 0, 0, time.UTC)
+
+	//Test calcular la siguiente ocurrencia de distintas reglas
+	t.Run("Success - Next occurrence", func(t *testing.T) {
+		cases := map[string]time.Time{
+			"FREQ=DAILY":                            time.Date(2026, 10, 8, 9, 0, 0, 0, time.UTC),
+			"FREQ=DAILY;INTERVAL=3":                 time.Date(2026, 10, 10, 9, 0, 0, 0, time.UTC),
+			"FREQ=DAILY;BYDAY=MO":                   time.Date(2026, 10, 12, 9, 0, 0, 0, time.UTC),
+			"FREQ=WEEKLY":                           time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC),
+			"FREQ=WEEKLY;BYDAY=MO,FR":               time.Date(2026, 10, 9, 9, 0, 0, 0, time.UTC),
+			"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO":       time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+			"FREQ=MONTHLY":                          time.Date(2026, 11, 7, 9, 0, 0, 0, time.UTC),
+			"FREQ=MONTHLY;BYMONTHDAY=1,15":          time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC),
+			"FREQ=MONTHLY;BYMONTHDAY=-1":            time.Date(2026, 10, 31, 9, 0, 0, 0, time.UTC),
+			"FREQ=MONTHLY;BYMONTHDAY=31;INTERVAL=1": time.Date(2026, 10, 31, 9, 0, 0, 0, time.UTC),
+			"FREQ=MONTHLY;BYDAY=MO;BYMONTHDAY=1":    time.Date(2027, 2, 1, 9, 0, 0, 0, time.UTC),
+			"FREQ=YEARLY":                           time.Date(2027, 10, 7, 9, 0, 0, 0, time.UTC),
+		}
+
+		for rule, expected := range cases {
+			//arrange
+			recurrence, err := internal.ParseRecurrence(rule)
+			require.NoError(t, err, rule)
+
+			//act
+			next, ok := recurrence.NextOccurrence(after)
+
+			//assert
+			require.True(t, ok, rule)
+			require.Equal(t, expected, next, rule)
+		}
+	})
+
+	//Test los meses sin el dia de la regla se saltean
+	t.Run("Success - Skip months without the day", func(t *testing.T) {
+
+		//arrange
+		recurrence, err := internal.ParseRecurrence("FREQ=MONTHLY")
+		require.NoError(t, err)
+		january := time.Date(2027, 1, 31, 9, 0, 0, 0, time.UTC)
+
+		//act
+		next, ok := recurrence.NextOccurrence(january)
+
+		//assert
+		require.True(t, ok)
+		require.Equal(t, time.Date(2027, 3, 31, 9, 0, 0, 0, time.UTC), next)
+	})
+
+	//Test la regla termina con UNTIL o con COUNT
+	t.Run("Success - Until and count", func(t *testing.T) {
+
+		//arrange
+		until, err := internal.ParseRecurrence("FREQ=WEEKLY;UNTIL=20261014")
+		require.NoError(t, err)
+		last, err := internal.ParseRecurrence("FREQ=DAILY;COUNT=1")
+		require.NoError(t, err)
+
+		//act
+		next, ok := until.NextOccurrence(after)
+		_, okAfterUntil := until.NextOccurrence(next)
+		_, okLast := last.NextOccurrence(after)
+
+		//assert
+		require.True(t, ok)
+		require.Equal(t, time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC), next)
+		require.False(t, okAfterUntil)
+		require.False(t, okLast)
+	})
+
+	//Test escribir la regla en formato RRULE
+	t.Run("Success - Format", func(t *testing.T) {
+
+		//act
+		recurrence, err := internal.ParseRecurrence("RRULE:freq=weekly;byday=fr,mo,fr;interval=2;count=3")
+
+		//assert
+		require.NoError(t, err)
+		require.Equal(t, "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR;COUNT=3", recurrence.String())
+	})
+
+	//Test reglas invalidas
+	t.Run("Error - Invalid rules", func(t *testing.T) {
+		for _, rule := range []string{
+			"FREQ=HOURLY",
+			"INTERVAL=2",
+			"FREQ=DAILY;BYDAY=XX",
+			"FREQ=MONTHLY;BYMONTHDAY=0",
+			"FREQ=DAILY;COUNT=2;UNTIL=20261231",
+			"FREQ=DAILY;FOO=1",
+			"FREQ",
+		} {
+			_, err := internal.ParseRecurrence(rule)
+			require.ErrorIs(t, err, internal.ErrTaskInvalidField, rule)
+		}
+	})
+}
+
+// Test de los titulos de las ocurrencias
+func TestOccurrenceTitle(t *testing.T) {
+	due := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+
+	require.Equal(t, "water plants (2026-10-14)", internal.OccurrenceTitle("water plants", due, 1))
+	require.Equal(t, "water plants (2026-10-14)", internal.OccurrenceTitle("water plants (2026-10-07)", due, 1))
+	require.Equal(t, "water plants (2026-10-14) #2", internal.OccurrenceTitle("water plants (2026-10-07) #3", due, 2))
+}
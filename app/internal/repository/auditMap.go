@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/Taks/internal"
+)
+
+// Esta es una implementacion de la interfaz AuditStore en memoria
+// Opcionalmente cada entrada se agrega a un archivo con una entrada JSON por linea, ver NewAuditFile
+type AuditMap struct {
+	mu      sync.RWMutex
+	entries []internal.AuditEntry
+
+	// Posiciones en entries de las entradas de cada tarea, en orden
+	byTask map[int][]int
+
+	// Archivo donde se agregan las entradas y su tamaño hasta la ultima entrada valida, si es nil solo viven en memoria
+	file *os.File
+	size int64
+}
+
+// Funcion para inicializar la auditoria en memoria
+func NewAuditMap() *AuditMap {
+	return &AuditMap{
+		byTask: make(map[int][]int),
+	}
+}
+
+// Funcion para inicializar la auditoria persistida en un archivo
+//
+// Las entradas nunca se modifican, por eso el archivo solo crece: cada entrada se agrega al final y se
+// asegura que este en disco antes de devolver. Si el proceso se corta a mitad de una escritura la ultima
+// linea queda incompleta, al iniciar se descarta.
+func NewAuditFile(file string) (a *AuditMap, err error) {
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return
+	}
+
+	a = NewAuditMap()
+	a.file = f
+	if err = a.load(); err != nil {
+		f.Close()
+		a = nil
+		return
+	}
+	return
+}
+
+// Funcion para guardar una entrada
+func (a *AuditMap) Append(entry *internal.AuditEntry) (err error) {
+	//Bloquear para escritura
+	(*a).mu.Lock()
+	defer (*a).mu.Unlock()
+
+	//Se asigna el siguiente ID
+	stored := copyAuditEntry(*entry)
+	stored.ID = len((*a).entries) + 1
+	stored.Revision = len((*a).byTask[stored.TaskID]) + 1
+
+	//Se persiste antes de agregarla en memoria, si falla la entrada no existe
+	if err = a.write(stored); err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrTaskInternal, err)
+		return
+	}
+
+	a.add(stored)
+	(*entry).ID = stored.ID
+	(*entry).Revision = stored.Revision
+	return
+}
+
+// Funcion para obtener una pagina del historial de una tarea
+func (a *AuditMap) List(query internal.AuditQuery) (page internal.AuditPage, err error) {
+	if err = query.Validate(); err != nil {
+		return
+	}
+
+	//Bloquear para lectura
+	(*a).mu.RLock()
+	defer (*a).mu.RUnlock()
+
+	positions := (*a).byTask[query.TaskID]
+	page.Total = len(positions)
+
+	start := min(query.Offset, len(positions))
+	end := len(positions)
+	if query.Limit > 0 && start+query.Limit < end {
+		end = start + query.Limit
+	}
+
+	page.Entries = make([]internal.AuditEntry, 0, end-start)
+	for _, position := range positions[start:end] {
+		page.Entries = append(page.Entries, copyAuditEntry((*a).entries[position]))
+	}
+	return
+}
+
+// Funcion para obtener las entradas de todas las tareas posteriores a una entrada
+func (a *AuditMap) ListSince(after int, limit int) (entries []internal.AuditEntry, err error) {
+	if after < 0 || limit < 0 {
+		err = fmt.Errorf("%w: invalid pagination", internal.ErrTaskInvalidField)
+		return
+	}
+
+	//Bloquear para lectura
+	(*a).mu.RLock()
+	defer (*a).mu.RUnlock()
+
+	//Las entradas estan ordenadas por ID, se busca la primera posterior a after
+	start := sort.Search(len((*a).entries), func(i int) bool {
+		return (*a).entries[i].ID > after
+	})
+	end := len((*a).entries)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+
+	entries = make([]internal.AuditEntry, 0, end-start)
+	for _, entry := range (*a).entries[start:end] {
+		entries = append(entries, copyAuditEntry(entry))
+	}
+	return
+}
+
+// Funcion para cerrar el archivo, si las entradas solo viven en memoria no hace nada
+func (a *AuditMap) Close() (err error) {
+	(*a).mu.Lock()
+	defer (*a).mu.Unlock()
+
+	if (*a).file == nil {
+		return
+	}
+
+	err = (*a).file.Close()
+	(*a).file = nil
+	return
+}
+
+// Funcion para agregar una entrada en memoria, se llama con la auditoria bloqueada para escritura
+// La revision se calcula con las entradas de la tarea, asi tambien la tienen las entradas que se guardaron sin ella
+func (a *AuditMap) add(entry internal.AuditEntry) {
+	entry.Revision = len((*a).byTask[entry.TaskID]) + 1
+	(*a).byTask[entry.TaskID] = append((*a).byTask[entry.TaskID], len((*a).entries))
+	(*a).entries = append((*a).entries, entry)
+}
+
+// Funcion para agregar una entrada al final del archivo
+// Se llama con la auditoria bloqueada para escritura
+func (a *AuditMap) write(entry internal.AuditEntry) (err error) {
+	if (*a).file == nil {
+		return
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	if _, err = (*a).file.Write(line); err == nil {
+		err = (*a).file.Sync()
+	}
+	if err != nil {
+		// Quitar lo que se haya escrito para no dejar una linea a medias antes de las siguientes
+		(*a).file.Truncate((*a).size)
+		(*a).file.Seek((*a).size, io.SeekStart)
+		return
+	}
+
+	(*a).size += int64(len(line))
+	return
+}
+
+// Funcion para cargar las entradas del archivo y descartar una ultima linea incompleta
+func (a *AuditMap) load() (err error) {
+	reader := bufio.NewReader((*a).file)
+	for {
+		var line []byte
+		line, err = reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Una linea sin salto final es una entrada incompleta, se descarta
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+
+		var entry internal.AuditEntry
+		if errJSON := json.Unmarshal(bytes.TrimSpace(line), &entry); errJSON != nil {
+			err = fmt.Errorf("invalid audit file %s: %w", (*a).file.Name(), errJSON)
+			return
+		}
+
+		a.add(entry)
+		(*a).size += int64(len(line))
+	}
+
+	// Truncar el archivo en la ultima entrada valida y seguir escribiendo desde ahi
+	if err = (*a).file.Truncate((*a).size); err != nil {
+		return
+	}
+	_, err = (*a).file.Seek((*a).size, io.SeekStart)
+	return
+}
+
+// Funcion para copiar una entrada, asi quien la recibe no puede modificar la guardada
+// Una entrada sin cambios queda con Changes nil
+func copyAuditEntry(entry internal.AuditEntry) internal.AuditEntry {
+	if entry.Task != nil {
+		task := *entry.Task
+		task.Tags = append([]string(nil), task.Tags...)
+		task.BlockedBy = append([]int(nil), task.BlockedBy...)
+		entry.Task = &task
+	}
+
+	var changes []internal.FieldChange
+	for _, change := range entry.Changes {
+		changes = append(changes, internal.FieldChange{
+			Field:  change.Field,
+			Before: append(json.RawMessage(nil), change.Before...),
+			After:  append(json.RawMessage(nil), change.After...),
+		})
+	}
+
+	entry.Changes = changes
+	return entry
+}
@@ -0,0 +1,270 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/Taks/internal"
+)
+
+// Esta es una implementacion de la interfaz AuditStore basada en una base de datos SQL
+// Puede usar la misma base de datos que TaskSQL, las entradas se guardan en su propia tabla
+type AuditSQL struct {
+	db *sql.DB
+}
+
+// Esquema de la auditoria, la tabla solo se crea si no existe
+// Las entradas no se modifican, los cambios de cada entrada se guardan juntos en JSON
+var auditSQLSchema = []string{
+	`CREATE TABLE IF NOT EXISTS audit_entries (
+		id      INTEGER PRIMARY KEY AUTOINCREMENT,
+		task_id INTEGER NOT NULL,
+		actor   TEXT    NOT NULL,
+		at      TEXT    NOT NULL,
+		op      TEXT    NOT NULL,
+		changes TEXT    NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS audit_entries_task_id ON audit_entries (task_id, id)`,
+}
+
+// Columnas que se agregaron despues de crear la tabla, se agregan si la tabla no las tiene
+var auditSQLColumns = []struct {
+	name       string
+	definition string
+}{
+	//Estado completo de la tarea despues del cambio, en JSON
+	{"task", "TEXT"},
+}
+
+// Funcion para inicializar la auditoria, crea la tabla si no existe
+func NewAuditSQL(db *sql.DB) (*AuditSQL, error) {
+	for _, statement := range auditSQLSchema {
+		if _, err := db.Exec(statement); err != nil {
+			return nil, sqlError(err)
+		}
+	}
+
+	for _, column := range auditSQLColumns {
+		if err := addSQLColumn(db, "audit_entries", column.name, column.definition); err != nil {
+			return nil, err
+		}
+	}
+
+	return &AuditSQL{
+		db: db,
+	}, nil
+}
+
+// Funcion para guardar una entrada
+func (a *AuditSQL) Append(entry *internal.AuditEntry) (err error) {
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrTaskInternal, err)
+		return
+	}
+
+	task, err := auditTaskValue(entry.Task)
+	if err != nil {
+		return
+	}
+
+	//La entrada y su revision se calculan en la misma transaccion
+	tx, err := a.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		"INSERT INTO audit_entries (task_id, actor, at, op, changes, task) VALUES (?, ?, ?, ?, ?, ?)",
+		entry.TaskID, entry.Actor, timeValue(&entry.At), entry.Op, string(changes), task,
+	)
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	//Se asigna a la entrada el ID generado por la base de datos
+	id, err := result.LastInsertId()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	//La revision es la cantidad de entradas de la tarea hasta esta
+	var revision int
+	if err = tx.QueryRow("SELECT COUNT(*) FROM audit_entries WHERE task_id = ? AND id <= ?", entry.TaskID, id).Scan(&revision); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	(*entry).ID = int(id)
+	(*entry).Revision = revision
+	return
+}
+
+// Funcion para obtener una pagina del historial de una tarea
+func (a *AuditSQL) List(query internal.AuditQuery) (page internal.AuditPage, err error) {
+	if err = query.Validate(); err != nil {
+		return
+	}
+
+	//El total y la pagina se leen en la misma transaccion para que sean consistentes
+	tx, err := a.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	if err = tx.QueryRow("SELECT COUNT(*) FROM audit_entries WHERE task_id = ?", query.TaskID).Scan(&page.Total); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	//En SQLite un LIMIT negativo no limita
+	limit := query.Limit
+	if limit == 0 {
+		limit = -1
+	}
+
+	rows, err := tx.Query(
+		"SELECT id, task_id, actor, at, op, changes, task FROM audit_entries WHERE task_id = ? ORDER BY id LIMIT ? OFFSET ?",
+		query.TaskID, limit, query.Offset,
+	)
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer rows.Close()
+
+	page.Entries = []internal.AuditEntry{}
+	for rows.Next() {
+		var entry internal.AuditEntry
+		if entry, err = scanAuditEntry(rows); err != nil {
+			return
+		}
+		//Las entradas estan en orden, la revision es su posicion en el historial de la tarea
+		entry.Revision = query.Offset + len(page.Entries) + 1
+		page.Entries = append(page.Entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para obtener las entradas de todas las tareas posteriores a una entrada
+func (a *AuditSQL) ListSince(after int, limit int) (entries []internal.AuditEntry, err error) {
+	if after < 0 || limit < 0 {
+		err = fmt.Errorf("%w: invalid pagination", internal.ErrTaskInvalidField)
+		return
+	}
+
+	//En SQLite un LIMIT negativo no limita
+	if limit == 0 {
+		limit = -1
+	}
+
+	//La revision de cada entrada es la cantidad de entradas de su tarea hasta ella
+	rows, err := a.db.Query(
+		`SELECT id, task_id, actor, at, op, changes, task,
+			(SELECT COUNT(*) FROM audit_entries AS previous WHERE previous.task_id = audit_entries.task_id AND previous.id <= audit_entries.id)
+		FROM audit_entries WHERE id > ? ORDER BY id LIMIT ?`,
+		after, limit,
+	)
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer rows.Close()
+
+	entries = []internal.AuditEntry{}
+	for rows.Next() {
+		var entry internal.AuditEntry
+		var revision int
+		if entry, err = scanAuditEntry(rows, &revision); err != nil {
+			return
+		}
+		entry.Revision = revision
+		entries = append(entries, entry)
+	}
+	if err = rows.Err(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para leer una entrada de una fila, extra recibe las columnas que siguen a las de la entrada
+func scanAuditEntry(row taskScanner, extra ...any) (entry internal.AuditEntry, err error) {
+	var at, changes string
+	var task sql.NullString
+	dest := append([]any{&entry.ID, &entry.TaskID, &entry.Actor, &at, &entry.Op, &changes, &task}, extra...)
+	if err = row.Scan(dest...); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if entry.At, err = time.Parse(time.RFC3339Nano, at); err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrTaskInternal, err)
+		return
+	}
+
+	if err = json.Unmarshal([]byte(changes), &entry.Changes); err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrTaskInternal, err)
+		return
+	}
+
+	if task.Valid {
+		entry.Task = &internal.Task{}
+		if err = json.Unmarshal([]byte(task.String), entry.Task); err != nil {
+			err = fmt.Errorf("%w: %v", internal.ErrTaskInternal, err)
+			return
+		}
+	}
+	return
+}
+
+// Funcion para convertir el estado de una tarea en el valor que se guarda, si no hay tarea es NULL
+func auditTaskValue(task *internal.Task) (value any, err error) {
+	if task == nil {
+		return
+	}
+
+	raw, err := json.Marshal(task)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrTaskInternal, err)
+		return
+	}
+
+	value = string(raw)
+	return
+}
+
+// Funcion para agregar una columna a una tabla si todavia no la tiene
+func addSQLColumn(db *sql.DB, table, column, definition string) (err error) {
+	var count int
+	if err = db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count); err != nil {
+		err = sqlError(err)
+		return
+	}
+	if count > 0 {
+		return
+	}
+
+	if _, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
@@ -0,0 +1,243 @@
+package repository_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de todas las implementaciones de AuditStore
+func TestAuditStore(t *testing.T) {
+	factories := map[string]func(t *testing.T) internal.AuditStore{
+		"map": func(t *testing.T) internal.AuditStore {
+			return repository.NewAuditMap()
+		},
+		"file": func(t *testing.T) internal.AuditStore {
+			audit, err := repository.NewAuditFile(filepath.Join(t.TempDir(), "audit.jsonl"))
+			require.NoError(t, err)
+			t.Cleanup(func() { audit.Close() })
+			return audit
+		},
+		"sqlite": func(t *testing.T) internal.AuditStore {
+			db, err := repository.OpenSQLite(":memory:")
+			require.NoError(t, err)
+			t.Cleanup(func() { db.Close() })
+
+			audit, err := repository.NewAuditSQL(db)
+			require.NoError(t, err)
+			return audit
+		},
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+
+			//Test guardar entradas y leer el historial de una tarea paginado
+			t.Run("Success - Append and list", func(t *testing.T) {
+
+				//arrange
+				audit := factory(t)
+				at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+				entries := []internal.AuditEntry{
+					{TaskID: 1, Actor: "ana", At: at, Op: internal.AuditOpSave, Changes: []internal.FieldChange{
+						{Field: "tittle", Before: json.RawMessage("null"), After: json.RawMessage(`"task 1"`)},
+					}, Task: &internal.Task{ID: 1, Tittle: "task 1", Tags: []string{"home"}, Version: 1, CreatedAt: at, UpdatedAt: at}},
+					{TaskID: 2, Actor: "ana", At: at, Op: internal.AuditOpSave},
+					{TaskID: 1, Actor: "luis", At: at.Add(time.Minute), Op: internal.AuditOpUpdate, Changes: []internal.FieldChange{
+						{Field: "done", Before: json.RawMessage("false"), After: json.RawMessage("true")},
+					}},
+				}
+
+				//act
+				for i := range entries {
+					require.NoError(t, audit.Append(&entries[i]))
+				}
+				all, errAll := audit.List(internal.AuditQuery{TaskID: 1})
+				page, errPage := audit.List(internal.AuditQuery{TaskID: 1, Limit: 1, Offset: 1})
+				empty, errEmpty := audit.List(internal.AuditQuery{TaskID: 3})
+
+				//assert
+				require.Equal(t, 1, entries[0].ID)
+				require.Equal(t, 2, entries[1].ID)
+				require.Equal(t, 3, entries[2].ID)
+				require.Equal(t, 1, entries[0].Revision)
+				require.Equal(t, 1, entries[1].Revision)
+				require.Equal(t, 2, entries[2].Revision)
+
+				require.NoError(t, errAll)
+				require.Equal(t, 2, all.Total)
+				require.Equal(t, []internal.AuditEntry{entries[0], entries[2]}, all.Entries)
+
+				require.NoError(t, errPage)
+				require.Equal(t, 2, page.Total)
+				require.Equal(t, []internal.AuditEntry{entries[2]}, page.Entries)
+				require.Equal(t, 2, page.Entries[0].Revision)
+				require.Nil(t, page.Entries[0].Task)
+
+				require.NoError(t, errEmpty)
+				require.Equal(t, 0, empty.Total)
+				require.Empty(t, empty.Entries)
+			})
+
+			//Test leer las entradas de todas las tareas posteriores a una entrada
+			t.Run("Success - List since", func(t *testing.T) {
+
+				//arrange
+				audit := factory(t)
+				at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+				entries := []internal.AuditEntry{
+					{TaskID: 1, Actor: "ana", At: at, Op: internal.AuditOpSave},
+					{TaskID: 2, Actor: "ana", At: at, Op: internal.AuditOpSave, Task: &internal.Task{ID: 2, Tittle: "task 2", Version: 1, CreatedAt: at, UpdatedAt: at}},
+					{TaskID: 1, Actor: "luis", At: at, Op: internal.AuditOpUpdate},
+					{TaskID: 1, Actor: "luis", At: at, Op: internal.AuditOpDelete},
+				}
+				for i := range entries {
+					require.NoError(t, audit.Append(&entries[i]))
+				}
+
+				//act
+				all, errAll := audit.ListSince(0, 0)
+				since, errSince := audit.ListSince(1, 2)
+				end, errEnd := audit.ListSince(4, 0)
+
+				//assert
+				require.NoError(t, errAll)
+				require.Equal(t, entries, all)
+
+				require.NoError(t, errSince)
+				require.Equal(t, entries[1:3], since)
+				require.Equal(t, 2, since[1].Revision)
+
+				require.NoError(t, errEnd)
+				require.Empty(t, end)
+			})
+
+			//Test una paginacion negativa es invalida
+			t.Run("Error - Invalid pagination", func(t *testing.T) {
+
+				//arrange
+				audit := factory(t)
+
+				//act
+				_, err := audit.List(internal.AuditQuery{TaskID: 1, Offset: -1})
+				_, errSince := audit.ListSince(-1, 0)
+
+				//assert
+				require.ErrorIs(t, err, internal.ErrTaskInvalidField)
+				require.ErrorIs(t, errSince, internal.ErrTaskInvalidField)
+			})
+		})
+	}
+
+	//Test modificar una entrada devuelta no modifica la guardada
+	t.Run("Success - Map returns copies", func(t *testing.T) {
+
+		//arrange
+		audit := repository.NewAuditMap()
+		entry := internal.AuditEntry{TaskID: 1, Op: internal.AuditOpSave, Changes: []internal.FieldChange{
+			{Field: "tittle", Before: json.RawMessage("null"), After: json.RawMessage(`"task 1"`)},
+		}}
+		require.NoError(t, audit.Append(&entry))
+
+		//act
+		page, err := audit.List(internal.AuditQuery{TaskID: 1})
+		require.NoError(t, err)
+		page.Entries[0].Changes[0].After[1] = 'X'
+		entry.Changes[0].Field = "description"
+
+		//assert
+		page, err = audit.List(internal.AuditQuery{TaskID: 1})
+		require.NoError(t, err)
+		require.Equal(t, "tittle", page.Entries[0].Changes[0].Field)
+		require.Equal(t, `"task 1"`, string(page.Entries[0].Changes[0].After))
+	})
+}
+
+// Test de la auditoria en SQLite
+func TestAuditSQL(t *testing.T) {
+
+	//Test una tabla creada antes de guardar el estado de las tareas se actualiza y conserva sus entradas
+	t.Run("Success - Add task column to an existing table", func(t *testing.T) {
+
+		//arrange
+		db, err := repository.OpenSQLite(filepath.Join(t.TempDir(), "tasks.db"))
+		require.NoError(t, err)
+		defer db.Close()
+		_, err = db.Exec(`CREATE TABLE audit_entries (
+			id      INTEGER PRIMARY KEY AUTOINCREMENT,
+			task_id INTEGER NOT NULL,
+			actor   TEXT    NOT NULL,
+			at      TEXT    NOT NULL,
+			op      TEXT    NOT NULL,
+			changes TEXT    NOT NULL
+		)`)
+		require.NoError(t, err)
+		_, err = db.Exec(`INSERT INTO audit_entries (task_id, actor, at, op, changes) VALUES (1, 'ana', '2024-05-01T10:00:00Z', 'save', 'null')`)
+		require.NoError(t, err)
+
+		//act
+		audit, err := repository.NewAuditSQL(db)
+		require.NoError(t, err)
+		entry := internal.AuditEntry{TaskID: 1, Actor: "luis", At: time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC), Op: internal.AuditOpUpdate,
+			Task: &internal.Task{ID: 1, Tittle: "task 1", Version: 2}}
+		require.NoError(t, audit.Append(&entry))
+		_, errReopen := repository.NewAuditSQL(db)
+
+		//assert
+		require.NoError(t, errReopen)
+		require.Equal(t, 2, entry.Revision)
+		page, err := audit.List(internal.AuditQuery{TaskID: 1})
+		require.NoError(t, err)
+		require.Len(t, page.Entries, 2)
+		require.Nil(t, page.Entries[0].Task)
+		require.Equal(t, entry, page.Entries[1])
+	})
+}
+
+// Test de la auditoria persistida en un archivo
+func TestAuditFile(t *testing.T) {
+
+	//Test las entradas se conservan al reabrir el archivo y se descarta una ultima linea incompleta
+	t.Run("Success - Reopen and discard torn entry", func(t *testing.T) {
+
+		//arrange
+		file := filepath.Join(t.TempDir(), "audit.jsonl")
+		audit, err := repository.NewAuditFile(file)
+		require.NoError(t, err)
+		first := internal.AuditEntry{TaskID: 1, Actor: "ana", At: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC), Op: internal.AuditOpSave}
+		require.NoError(t, audit.Append(&first))
+		require.NoError(t, audit.Close())
+
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"ID":2,"TaskID":1,"Act`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		//act
+		reopened, err := repository.NewAuditFile(file)
+		require.NoError(t, err)
+		defer reopened.Close()
+		second := internal.AuditEntry{TaskID: 1, Actor: "luis", At: first.At.Add(time.Minute), Op: internal.AuditOpDelete}
+		require.NoError(t, reopened.Append(&second))
+
+		//assert
+		require.Equal(t, 2, second.ID)
+		page, err := reopened.List(internal.AuditQuery{TaskID: 1})
+		require.NoError(t, err)
+		require.Equal(t, []internal.AuditEntry{first, second}, page.Entries)
+
+		again, err := repository.NewAuditFile(file)
+		require.NoError(t, err)
+		defer again.Close()
+		page, err = again.List(internal.AuditQuery{TaskID: 1})
+		require.NoError(t, err)
+		require.Equal(t, []internal.AuditEntry{first, second}, page.Entries)
+	})
+}
@@ -0,0 +1,47 @@
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/Taks/internal/repository/repositorytest"
+	"github.com/stretchr/testify/require"
+)
+
+// Test del contrato de TaskRepository: todas las implementaciones deben comportarse igual
+func TestTaskRepositoryContract(t *testing.T) {
+	factories := map[string]repositorytest.Factory{
+		"map": func(t *testing.T) internal.TaskRepository {
+			return repository.NewTaskMap(nil, 0)
+		},
+		"file": func(t *testing.T) internal.TaskRepository {
+			rp, err := repository.NewTaskMapFile(filepath.Join(t.TempDir(), "tasks.json"), 0)
+			require.NoError(t, err)
+			t.Cleanup(func() { rp.Close() })
+			return rp
+		},
+		"wal": func(t *testing.T) internal.TaskRepository {
+			rp, err := repository.NewTaskMapWAL(filepath.Join(t.TempDir(), "tasks.json"), 0)
+			require.NoError(t, err)
+			t.Cleanup(func() { rp.Close() })
+			return rp
+		},
+		"events": func(t *testing.T) internal.TaskRepository {
+			rp, err := repository.NewTaskMapEvents(filepath.Join(t.TempDir(), "events.jsonl"))
+			require.NoError(t, err)
+			t.Cleanup(func() { rp.Close() })
+			return rp
+		},
+		"sqlite": func(t *testing.T) internal.TaskRepository {
+			return newTaskSQL(t, ":memory:")
+		},
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+			repositorytest.Run(t, factory)
+		})
+	}
+}
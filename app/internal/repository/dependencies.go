@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/Taks/internal"
+)
+
+// Funcion para validar las dependencias de una tarea antes de guardarla
+// La comparten las implementaciones de TaskRepository para que todas apliquen las mismas reglas
+func checkTaskDependencies(task internal.Task, reader taskTreeReader) (err error) {
+	//Las tareas de las que depende deben existir y no puede depender de si misma
+	for _, id := range task.BlockedBy {
+		if id == task.ID {
+			err = internal.ErrTaskDependencyCycle
+			return
+		}
+
+		if _, err = reader.get(id); errors.Is(err, internal.ErrTaskNotFound) {
+			err = internal.ErrTaskInvalidField
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	//Una tarea nueva (sin ID) todavia no bloquea a ninguna otra, no puede cerrar un ciclo
+	if task.ID == 0 {
+		return
+	}
+
+	//Recorrer las dependencias de las dependencias, si se llega a la misma tarea hay un ciclo
+	visited := make(map[int]bool)
+	stack := append([]int(nil), task.BlockedBy...)
+	for len(stack) > 0 {
+		id := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if id == task.ID {
+			err = internal.ErrTaskDependencyCycle
+			return
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		var dependency internal.Task
+		if dependency, err = reader.get(id); err != nil {
+			return
+		}
+		stack = append(stack, dependency.BlockedBy...)
+	}
+	return
+}
@@ -0,0 +1,104 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de las dependencias en todas las implementaciones de TaskRepository
+func TestDependencies(t *testing.T) {
+	factories := map[string]func(t *testing.T) internal.TaskRepository{
+		"map": func(t *testing.T) internal.TaskRepository {
+			return repository.NewTaskMap(nil, 0)
+		},
+		"sqlite": func(t *testing.T) internal.TaskRepository {
+			return newTaskSQL(t, ":memory:")
+		},
+	}
+
+	// Crea tres tareas donde 3 depende de 2 y 2 depende de 1
+	newChain := func(t *testing.T, rp internal.TaskRepository) {
+		first := internal.Task{Tittle: "task 1"}
+		require.NoError(t, rp.Save(&first))
+		second := internal.Task{Tittle: "task 2", BlockedBy: []int{first.ID}}
+		require.NoError(t, rp.Save(&second))
+		third := internal.Task{Tittle: "task 3"}
+		require.NoError(t, rp.Save(&third))
+		require.NoError(t, rp.AddDependency(third.ID, second.ID))
+		require.NoError(t, rp.AddDependency(third.ID, second.ID))
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+
+			//Test una tarea esta bloqueada mientras alguna dependencia no este terminada
+			t.Run("Success - Blocked until dependencies are done", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				newChain(t, rp)
+
+				//act
+				before, err := rp.GetByID(2)
+				require.NoError(t, err)
+				require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)}))
+				after, err := rp.GetByID(2)
+				require.NoError(t, err)
+				page, err := rp.List(internal.TaskQuery{})
+				require.NoError(t, err)
+
+				//assert
+				require.Equal(t, []int{1}, before.BlockedBy)
+				require.True(t, before.Blocked)
+				require.False(t, after.Blocked)
+
+				blocked := map[int]bool{}
+				for _, task := range page.Tasks {
+					blocked[task.ID] = task.Blocked
+				}
+				require.Equal(t, map[int]bool{1: false, 2: false, 3: true}, blocked)
+			})
+
+			//Test no se pueden crear ciclos ni depender de tareas que no existen
+			t.Run("Error - Cycles and missing tasks", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				newChain(t, rp)
+
+				//act & assert
+				require.ErrorIs(t, rp.AddDependency(1, 3), internal.ErrTaskDependencyCycle)
+				require.ErrorIs(t, rp.AddDependency(1, 1), internal.ErrTaskDependencyCycle)
+				require.ErrorIs(t, rp.UpdatePartial(1, 0, internal.TaskPatch{BlockedBy: internal.Set([]int{2})}), internal.ErrTaskDependencyCycle)
+				require.ErrorIs(t, rp.Update(internal.Task{ID: 1, Tittle: "task 1", BlockedBy: []int{3}}), internal.ErrTaskDependencyCycle)
+				require.ErrorIs(t, rp.AddDependency(1, 99), internal.ErrTaskInvalidField)
+				require.ErrorIs(t, rp.AddDependency(99, 1), internal.ErrTaskNotFound)
+
+				//Sin la dependencia 3 -> 2 ya no hay ciclo
+				require.NoError(t, rp.RemoveDependency(3, 2))
+				require.NoError(t, rp.AddDependency(1, 3))
+			})
+
+			//Test al eliminar una tarea las que dependian de ella dejan de depender
+			t.Run("Success - Delete removes dependencies", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				newChain(t, rp)
+
+				//act
+				err := rp.Delete(2, 0)
+
+				//assert
+				require.NoError(t, err)
+				task, err := rp.GetByID(3)
+				require.NoError(t, err)
+				require.Nil(t, task.BlockedBy)
+				require.False(t, task.Blocked)
+			})
+		})
+	}
+}
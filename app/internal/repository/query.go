@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Taks/internal"
+)
+
+/*
+Este archivo contiene la logica de consulta compartida por las implementaciones de TaskRepository:
+filtrar, ordenar y paginar un listado de tareas. Asi todas las implementaciones devuelven
+exactamente el mismo resultado para la misma consulta.
+*/
+
+// Contenido del cursor de paginacion, se envia al cliente codificado en base64
+type taskCursor struct {
+	SortBy   string        `json:"sort"`
+	SortDesc bool          `json:"desc"`
+	Last     internal.Task `json:"last"`
+}
+
+// Funcion para aplicar una consulta sobre un listado de tareas
+func queryTasks(tasks []internal.Task, query internal.TaskQuery) (page internal.TaskPage, err error) {
+	//Validar la consulta
+	query, cursor, err := prepareTaskQuery(query)
+	if err != nil {
+		return
+	}
+
+	//Filtrar las tareas
+	filtered := make([]internal.Task, 0, len(tasks))
+	for _, task := range tasks {
+		if matchTask(task, query) {
+			filtered = append(filtered, task)
+		}
+	}
+
+	//Ordenar las tareas, el ID desempata para que el orden sea siempre el mismo
+	sort.Slice(filtered, func(i, j int) bool {
+		return compareTasks(filtered[i], filtered[j], query.SortBy, query.SortDesc) < 0
+	})
+
+	//Buscar donde empieza la pagina
+	start := query.Offset
+	if cursor != nil {
+		//La pagina empieza en la primera tarea posterior a la ultima devuelta
+		start = sort.Search(len(filtered), func(i int) bool {
+			return compareTasks(filtered[i], cursor.Last, query.SortBy, query.SortDesc) > 0
+		})
+	}
+	if start > len(filtered) {
+		start = len(filtered)
+	}
+
+	end := len(filtered)
+	if query.Limit > 0 && start+query.Limit < end {
+		end = start + query.Limit
+	}
+
+	page.Tasks = filtered[start:end]
+	page.Total = len(filtered)
+
+	//Si quedan tareas se genera el cursor de la siguiente pagina
+	if end < len(filtered) && end > start {
+		page.NextCursor, err = encodeTaskCursor(filtered[end-1], query)
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// Funcion para validar una consulta, completa el orden por defecto y normaliza las etiquetas
+// Si la consulta tiene cursor tambien lo devuelve, el cursor solo es valido para el mismo orden con el que se genero
+func prepareTaskQuery(query internal.TaskQuery) (prepared internal.TaskQuery, cursor *taskCursor, err error) {
+	if query.SortBy == "" {
+		query.SortBy = internal.TaskSortID
+	}
+	if !validSortField(query.SortBy) || query.Limit < 0 || query.Offset < 0 {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+	if query.Cursor != "" && query.Offset != 0 {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+	if query.TagsAny, err = internal.NormalizeTags(query.TagsAny); err != nil {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+	if query.TagsAll, err = internal.NormalizeTags(query.TagsAll); err != nil {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+
+	if query.Cursor != "" {
+		var decoded taskCursor
+		if decoded, err = decodeTaskCursor(query.Cursor); err != nil {
+			return
+		}
+		if decoded.SortBy != query.SortBy || decoded.SortDesc != query.SortDesc {
+			err = internal.ErrTaskInvalidField
+			return
+		}
+		cursor = &decoded
+	}
+
+	prepared = query
+	return
+}
+
+// Funcion para saber si una tarea cumple con los filtros de la consulta
+func matchTask(task internal.Task, query internal.TaskQuery) bool {
+	if query.Done != nil && task.Done != *query.Done {
+		return false
+	}
+
+	if query.Search != "" {
+		search := strings.ToLower(query.Search)
+		if !strings.Contains(strings.ToLower(task.Tittle), search) &&
+			!strings.Contains(strings.ToLower(task.Description), search) {
+			return false
+		}
+	}
+
+	//Ventana de fechas limite
+	if query.DueFrom != nil && (task.DueAt == nil || task.DueAt.Before(*query.DueFrom)) {
+		return false
+	}
+	if query.DueTo != nil && (task.DueAt == nil || task.DueAt.After(*query.DueTo)) {
+		return false
+	}
+
+	//Tareas vencidas
+	if query.OverdueAt != nil && (task.Done || task.DueAt == nil || !task.DueAt.Before(*query.OverdueAt)) {
+		return false
+	}
+
+	//Subtareas de una tarea
+	if query.ParentID != nil && (task.ParentID == nil || *task.ParentID != *query.ParentID) {
+		return false
+	}
+
+	//Etiquetas, las de la tarea y las de la consulta ya estan normalizadas
+	if len(query.TagsAny) > 0 && !hasAnyTag(task.Tags, query.TagsAny) {
+		return false
+	}
+	for _, tag := range query.TagsAll {
+		if !hasAnyTag(task.Tags, []string{tag}) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Funcion para saber si una tarea tiene alguna de las etiquetas
+func hasAnyTag(taskTags []string, tags []string) bool {
+	for _, tag := range tags {
+		for _, taskTag := range taskTags {
+			if taskTag == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Funcion para validar el campo de ordenamiento
+func validSortField(field string) bool {
+	switch field {
+	case internal.TaskSortID, internal.TaskSortTittle, internal.TaskSortDescription, internal.TaskSortDone,
+		internal.TaskSortStartAt, internal.TaskSortDueAt, internal.TaskSortPriority:
+		return true
+	}
+	return false
+}
+
+// Funcion para comparar dos tareas segun el campo de ordenamiento
+// Devuelve un numero negativo si a va antes que b, positivo si va despues
+// Las tareas sin fecha van siempre al final, sin importar la direccion
+func compareTasks(a, b internal.Task, field string, desc bool) (result int) {
+	var nilLast bool
+	switch field {
+	case internal.TaskSortStartAt:
+		result, nilLast = compareTime(a.StartAt, b.StartAt)
+	case internal.TaskSortDueAt:
+		result, nilLast = compareTime(a.DueAt, b.DueAt)
+	case internal.TaskSortTittle:
+		result = strings.Compare(a.Tittle, b.Tittle)
+	case internal.TaskSortDescription:
+		result = strings.Compare(a.Description, b.Description)
+	case internal.TaskSortDone:
+		result = compareBool(a.Done, b.Done)
+	case internal.TaskSortPriority:
+		result = int(a.Priority - b.Priority)
+	}
+
+	//Una fecha nula va al final sin importar la direccion
+	if nilLast {
+		return
+	}
+
+	//Desempatar por ID
+	if result == 0 {
+		result = a.ID - b.ID
+	}
+
+	if desc {
+		result = -result
+	}
+	return
+}
+
+// Funcion para comparar fechas opcionales
+// nilLast indica que solo una de las dos es nula y el resultado la manda al final
+func compareTime(a, b *time.Time) (result int, nilLast bool) {
+	switch {
+	case a == nil && b == nil:
+		return 0, false
+	case a == nil:
+		return 1, true
+	case b == nil:
+		return -1, true
+	}
+	return a.Compare(*b), false
+}
+
+// Funcion para comparar booleanos, false va antes que true
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	default:
+		return 1
+	}
+}
+
+// Funcion para generar el cursor a partir de la ultima tarea de la pagina
+func encodeTaskCursor(last internal.Task, query internal.TaskQuery) (cursor string, err error) {
+	//Solo se guarda el ID y el campo de ordenamiento
+	key := internal.Task{ID: last.ID}
+	switch query.SortBy {
+	case internal.TaskSortTittle:
+		key.Tittle = last.Tittle
+	case internal.TaskSortDescription:
+		key.Description = last.Description
+	case internal.TaskSortDone:
+		key.Done = last.Done
+	case internal.TaskSortStartAt:
+		key.StartAt = last.StartAt
+	case internal.TaskSortDueAt:
+		key.DueAt = last.DueAt
+	case internal.TaskSortPriority:
+		key.Priority = last.Priority
+	}
+
+	bytes, err := json.Marshal(taskCursor{
+		SortBy:   query.SortBy,
+		SortDesc: query.SortDesc,
+		Last:     key,
+	})
+	if err != nil {
+		err = internal.ErrTaskInternal
+		return
+	}
+
+	cursor = base64.RawURLEncoding.EncodeToString(bytes)
+	return
+}
+
+// Funcion para leer un cursor enviado por el cliente
+func decodeTaskCursor(value string) (cursor taskCursor, err error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+
+	if err = json.Unmarshal(bytes, &cursor); err != nil {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+	return
+}
@@ -0,0 +1,54 @@
+package repository
+
+import "github.com/Taks/internal"
+
+// Funcion para crear la siguiente ocurrencia de una tarea recurrente que se acaba de terminar
+// La comparten las implementaciones de TaskRepository para que todas creen la misma ocurrencia
+//
+// prev es la tarea guardada y task la que se va a guardar. Devuelve false si la tarea no paso a estar
+// terminada, si no es recurrente o si la regla ya no tiene mas ocurrencias.
+// titleTaken indica si un titulo ya lo usa otra tarea, asi la ocurrencia nunca es ErrTaskDuplicated.
+func nextOccurrence(prev, task internal.Task, titleTaken func(title string) (bool, error)) (next internal.Task, ok bool, err error) {
+	if prev.Done || !task.Done || task.Recurrence == nil || task.DueAt == nil {
+		return
+	}
+
+	dueAt, ok := task.Recurrence.NextOccurrence(*task.DueAt)
+	if !ok {
+		return
+	}
+
+	//La ocurrencia es una copia de la tarea sin terminar, con las fechas corridas
+	next = task
+	next.ID = 0
+	next.Version = 1
+	next.CreatedAt = task.UpdatedAt
+	next.Done = false
+	next.DueAt = &dueAt
+	if task.StartAt != nil {
+		startAt := task.StartAt.Add(dueAt.Sub(*task.DueAt))
+		next.StartAt = &startAt
+	}
+	next.Tags = append([]string(nil), task.Tags...)
+	next.BlockedBy = append([]int(nil), task.BlockedBy...)
+
+	recurrence := *task.Recurrence
+	if recurrence.Count > 0 {
+		recurrence.Count--
+	}
+	next.Recurrence = &recurrence
+
+	//Buscar un titulo que no este en uso
+	for attempt := 1; ; attempt++ {
+		next.Tittle = internal.OccurrenceTitle(task.Tittle, dueAt, attempt)
+
+		var taken bool
+		if taken, err = titleTaken(next.Tittle); err != nil {
+			ok = false
+			return
+		}
+		if !taken {
+			return
+		}
+	}
+}
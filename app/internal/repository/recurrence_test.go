@@ -0,0 +1,135 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de las tareas recurrentes en todas las implementaciones de TaskRepository
+func TestRecurrence(t *testing.T) {
+	factories := map[string]func(t *testing.T) internal.TaskRepository{
+		"map": func(t *testing.T) internal.TaskRepository {
+			return repository.NewTaskMap(nil, 0)
+		},
+		"sqlite": func(t *testing.T) internal.TaskRepository {
+			return newTaskSQL(t, ":memory:")
+		},
+	}
+
+	start := time.Date(2026, 10, 7, 8, 0, 0, 0, time.UTC)
+	due := time.Date(2026, 10, 7, 9, 0, 0, 0, time.UTC)
+
+	// Crea una tarea que se repite todas las semanas tres veces
+	newRecurring := func(t *testing.T, rp internal.TaskRepository) {
+		recurrence, err := internal.ParseRecurrence("FREQ=WEEKLY;COUNT=3")
+		require.NoError(t, err)
+		task := internal.Task{Tittle: "water plants", StartAt: &start, DueAt: &due, Tags: []string{"home"}, Recurrence: recurrence}
+		require.NoError(t, rp.Save(&task))
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+
+			//Test al terminar una tarea recurrente se crea la siguiente ocurrencia
+			t.Run("Success - Done creates the next occurrence", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				newRecurring(t, rp)
+
+				//act
+				require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)}))
+				task, err := rp.GetByID(1)
+				require.NoError(t, err)
+				require.NoError(t, rp.Update(task))
+
+				//assert
+				page, err := rp.List(internal.TaskQuery{})
+				require.NoError(t, err)
+				require.Equal(t, 2, page.Total)
+
+				next := page.Tasks[1]
+				require.Equal(t, "water plants (2026-10-14)", next.Tittle)
+				require.False(t, next.Done)
+				require.True(t, due.AddDate(0, 0, 7).Equal(*next.DueAt))
+				require.True(t, start.AddDate(0, 0, 7).Equal(*next.StartAt))
+				require.Equal(t, []string{"home"}, next.Tags)
+				require.Equal(t, "FREQ=WEEKLY;COUNT=2", next.Recurrence.String())
+			})
+
+			//Test el titulo de la ocurrencia no choca con una tarea existente
+			t.Run("Success - Unique occurrence title", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				newRecurring(t, rp)
+				taken := internal.Task{Tittle: "water plants (2026-10-14)"}
+				require.NoError(t, rp.Save(&taken))
+
+				//act
+				err := rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)})
+
+				//assert
+				require.NoError(t, err)
+				next, err := rp.GetByID(3)
+				require.NoError(t, err)
+				require.Equal(t, "water plants (2026-10-14) #2", next.Tittle)
+			})
+
+			//Test la ultima ocurrencia no crea otra
+			t.Run("Success - Count ends the series", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				newRecurring(t, rp)
+
+				//act
+				require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)}))
+				require.NoError(t, rp.UpdatePartial(2, 0, internal.TaskPatch{Done: internal.Set(true)}))
+				require.NoError(t, rp.UpdatePartial(3, 0, internal.TaskPatch{Done: internal.Set(true)}))
+
+				//assert
+				page, err := rp.List(internal.TaskQuery{})
+				require.NoError(t, err)
+				require.Equal(t, 3, page.Total)
+				require.Equal(t, "water plants (2026-10-21)", page.Tasks[2].Tittle)
+			})
+
+			//Test una tarea recurrente necesita fecha limite
+			t.Run("Error - Recurrence without due date", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				recurrence, err := internal.ParseRecurrence("FREQ=DAILY")
+				require.NoError(t, err)
+
+				//act
+				task := internal.Task{Tittle: "task", Recurrence: recurrence}
+				err = rp.Save(&task)
+
+				//assert
+				require.ErrorIs(t, err, internal.ErrTaskInvalidField)
+			})
+
+			//Test la regla de una actualizacion parcial debe ser valida
+			t.Run("Error - Invalid recurrence in UpdatePartial", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				newRecurring(t, rp)
+
+				//act & assert
+				require.ErrorIs(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Recurrence: internal.Set(&internal.Recurrence{Frequency: "NEVER"})}), internal.ErrTaskInvalidField)
+				require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Recurrence: internal.Set[*internal.Recurrence](nil)}))
+
+				task, err := rp.GetByID(1)
+				require.NoError(t, err)
+				require.Nil(t, task.Recurrence)
+			})
+		})
+	}
+}
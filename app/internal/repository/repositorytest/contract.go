@@ -0,0 +1,757 @@
+/*
+Paquete con el contrato de comportamiento de internal.TaskRepository.
+
+Cada implementacion de TaskRepository (las actuales y las que se agreguen) se verifica contra la
+misma especificacion: desde su test llama a Run con una funcion que crea un repositorio vacio.
+
+	func TestTaskRepositoryContract(t *testing.T) {
+		repositorytest.Run(t, func(t *testing.T) internal.TaskRepository {
+			return repository.NewTaskMap(nil, 0)
+		})
+	}
+*/
+package repositorytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/stretchr/testify/require"
+)
+
+// Funcion que crea un repositorio vacio para un caso del contrato
+// Se llama una vez por caso, los recursos del repositorio se liberan con t.Cleanup
+type Factory func(t *testing.T) internal.TaskRepository
+
+// Funcion para ejecutar el contrato completo de TaskRepository, cada grupo de casos es un subtest
+func Run(t *testing.T, factory Factory) {
+	t.Run("Save", func(t *testing.T) { testSave(t, factory) })
+	t.Run("Duplicated", func(t *testing.T) { testDuplicated(t, factory) })
+	t.Run("Update", func(t *testing.T) { testUpdate(t, factory) })
+	t.Run("UpdatePartial", func(t *testing.T) { testUpdatePartial(t, factory) })
+	t.Run("NotFound", func(t *testing.T) { testNotFound(t, factory) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, factory) })
+	t.Run("TagsAndDependencies", func(t *testing.T) { testTagsAndDependencies(t, factory) })
+	t.Run("List", func(t *testing.T) { testList(t, factory) })
+}
+
+// Casos de Save: IDs, version y fechas
+func testSave(t *testing.T, factory Factory) {
+
+	//Test guardar asigna IDs consecutivos, la primera version y las fechas
+	t.Run("Success - Save and get", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		first := internal.Task{Tittle: "task 1", Description: "first", Tags: []string{"Home", "work"}}
+		second := internal.Task{Tittle: "task 2", Priority: internal.PriorityHigh}
+
+		//act
+		errFirst := rp.Save(&first)
+		errSecond := rp.Save(&second)
+		task, errGet := rp.GetByID(1)
+
+		//assert
+		require.NoError(t, errFirst)
+		require.NoError(t, errSecond)
+		require.Equal(t, 1, first.ID)
+		require.Equal(t, 2, second.ID)
+		require.Equal(t, 1, first.Version)
+		require.NotZero(t, first.CreatedAt)
+		require.Equal(t, first.CreatedAt, first.UpdatedAt)
+
+		require.NoError(t, errGet)
+		require.Equal(t, "task 1", task.Tittle)
+		require.Equal(t, "first", task.Description)
+		require.Equal(t, []string{"home", "work"}, task.Tags)
+		require.Equal(t, internal.PriorityNone, task.Priority)
+		require.Equal(t, 1, task.Version)
+		require.True(t, first.CreatedAt.Equal(task.CreatedAt))
+	})
+
+	//Test los IDs no se reutilizan aunque la tarea se elimine definitivamente
+	t.Run("Success - IDs are not reused", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 2"}))
+		require.NoError(t, rp.Delete(2, 0))
+		require.NoError(t, rp.Purge(2))
+
+		//act
+		task := internal.Task{Tittle: "task 3"}
+		err := rp.Save(&task)
+
+		//assert
+		require.NoError(t, err)
+		require.Equal(t, 3, task.ID)
+	})
+
+	//Test una tarea invalida no se guarda y no consume un ID
+	t.Run("Error - Invalid task", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		startAt := time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC)
+		dueAt := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+		//act
+		errDates := rp.Save(&internal.Task{Tittle: "task 1", StartAt: &startAt, DueAt: &dueAt})
+		errTags := rp.Save(&internal.Task{Tittle: "task 1", Tags: []string{" "}})
+		errParent := rp.Save(&internal.Task{Tittle: "task 1", ParentID: intPtr(7)})
+		task := internal.Task{Tittle: "task 1"}
+		errSave := rp.Save(&task)
+
+		//assert
+		require.ErrorIs(t, errDates, internal.ErrTaskInvalidField)
+		require.ErrorIs(t, errTags, internal.ErrTaskInvalidField)
+		require.Error(t, errParent)
+		require.NoError(t, errSave)
+		require.Equal(t, 1, task.ID)
+	})
+}
+
+// Casos de titulos duplicados en Save, Update y UpdatePartial
+func testDuplicated(t *testing.T, factory Factory) {
+
+	//Test un titulo en uso por otra tarea no se puede guardar ni asignar
+	t.Run("Error - Duplicated title", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 2"}))
+
+		//act
+		duplicated := internal.Task{Tittle: "task 1"}
+		errSave := rp.Save(&duplicated)
+		errUpdate := rp.Update(internal.Task{ID: 2, Tittle: "task 1"})
+		errPartial := rp.UpdatePartial(2, 0, internal.TaskPatch{Tittle: internal.Set("task 1")})
+		task, errGet := rp.GetByID(2)
+
+		//assert
+		require.ErrorIs(t, errSave, internal.ErrTaskDuplicated)
+		require.Zero(t, duplicated.ID)
+		require.ErrorIs(t, errUpdate, internal.ErrTaskDuplicated)
+		require.ErrorIs(t, errPartial, internal.ErrTaskDuplicated)
+		require.NoError(t, errGet)
+		require.Equal(t, "task 2", task.Tittle)
+		require.Equal(t, 1, task.Version)
+
+		page, err := rp.List(internal.TaskQuery{})
+		require.NoError(t, err)
+		require.Equal(t, 2, page.Total)
+	})
+
+	//Test una tarea puede conservar su propio titulo y los titulos distinguen mayusculas
+	t.Run("Success - Same task and different case", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+
+		//act
+		errUpdate := rp.Update(internal.Task{ID: 1, Tittle: "task 1", Description: "same title"})
+		errPartial := rp.UpdatePartial(1, 0, internal.TaskPatch{Tittle: internal.Set("task 1")})
+		errCase := rp.Save(&internal.Task{Tittle: "Task 1"})
+
+		//assert
+		require.NoError(t, errUpdate)
+		require.NoError(t, errPartial)
+		require.NoError(t, errCase)
+	})
+
+	//Test las tareas de la papelera no ocupan su titulo
+	t.Run("Success - Trashed tasks release their title", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 2"}))
+		require.NoError(t, rp.Delete(1, 0))
+
+		//act
+		errSave := rp.Save(&internal.Task{Tittle: "task 1"})
+		errPartial := rp.UpdatePartial(2, 0, internal.TaskPatch{Tittle: internal.Set("task 1")})
+		errRestore := rp.Restore(1)
+
+		//assert
+		require.NoError(t, errSave)
+		require.ErrorIs(t, errPartial, internal.ErrTaskDuplicated)
+		require.ErrorIs(t, errRestore, internal.ErrTaskDuplicated)
+	})
+
+	//Test buscar por titulo no distingue mayusculas, tampoco fuera de ASCII, y no incluye la papelera
+	t.Run("Success - Find by title", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "Ñandú"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "ñANDÚ"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "ñandú 2"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "ÑANDÚ"}))
+		require.NoError(t, rp.Delete(4, 0))
+
+		//act
+		ids, err := rp.FindByTittle("ñandú")
+		none, errNone := rp.FindByTittle("task")
+
+		//assert
+		require.NoError(t, err)
+		require.Equal(t, []int{1, 2}, ids)
+		require.NoError(t, errNone)
+		require.Empty(t, none)
+	})
+}
+
+// Casos de Update
+func testUpdate(t *testing.T, factory Factory) {
+
+	//Test actualizar reemplaza todos los campos, conserva la fecha de creacion y aumenta la version
+	t.Run("Success - Update", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		saved := internal.Task{Tittle: "task 1", Description: "old", Tags: []string{"home"}}
+		require.NoError(t, rp.Save(&saved))
+		dueAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+		//act
+		err := rp.Update(internal.Task{ID: 1, Tittle: "task 1 updated", Description: "new", Done: true, DueAt: &dueAt, Priority: internal.PriorityLow})
+		task, errGet := rp.GetByID(1)
+
+		//assert
+		require.NoError(t, err)
+		require.NoError(t, errGet)
+		require.Equal(t, "task 1 updated", task.Tittle)
+		require.Equal(t, "new", task.Description)
+		require.True(t, task.Done)
+		require.True(t, dueAt.Equal(*task.DueAt))
+		require.Equal(t, internal.PriorityLow, task.Priority)
+		require.Empty(t, task.Tags)
+		require.Equal(t, 2, task.Version)
+		require.True(t, saved.CreatedAt.Equal(task.CreatedAt))
+		require.False(t, task.UpdatedAt.Before(task.CreatedAt))
+	})
+
+	//Test una version distinta de la guardada no actualiza la tarea
+	t.Run("Error - Version conflict", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+		require.NoError(t, rp.Update(internal.Task{ID: 1, Tittle: "task 1", Version: 1}))
+
+		//act
+		err := rp.Update(internal.Task{ID: 1, Tittle: "stale", Version: 1})
+		task, errGet := rp.GetByID(1)
+
+		//assert
+		require.ErrorIs(t, err, internal.ErrTaskVersionConflict)
+		require.NoError(t, errGet)
+		require.Equal(t, "task 1", task.Tittle)
+		require.Equal(t, 2, task.Version)
+	})
+}
+
+// Casos de UpdatePartial, incluidos los valores que se validan al aplicar el patch
+func testUpdatePartial(t *testing.T, factory Factory) {
+
+	//Test actualizar parcialmente solo cambia los campos del patch que tienen Set
+	t.Run("Success - Only set fields", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		dueAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "parent"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 2", Description: "keep"}))
+
+		//act
+		err := rp.UpdatePartial(2, 1, internal.TaskPatch{
+			Done:      internal.Set(true),
+			DueAt:     internal.Set(&dueAt),
+			Priority:  internal.Set(internal.PriorityUrgent),
+			Tags:      internal.Set([]string{"Work", "home"}),
+			ParentID:  internal.Set(intPtr(1)),
+			BlockedBy: internal.Set([]int{1}),
+		})
+		task, errGet := rp.GetByID(2)
+
+		//assert
+		require.NoError(t, err)
+		require.NoError(t, errGet)
+		require.Equal(t, "task 2", task.Tittle)
+		require.Equal(t, "keep", task.Description)
+		require.True(t, task.Done)
+		require.True(t, time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC).Equal(*task.DueAt))
+		require.Equal(t, internal.PriorityUrgent, task.Priority)
+		require.Equal(t, []string{"home", "work"}, task.Tags)
+		require.Equal(t, intPtr(1), task.ParentID)
+		require.Equal(t, []int{1}, task.BlockedBy)
+		require.Equal(t, 2, task.Version)
+	})
+
+	//Test null borra los campos opcionales
+	t.Run("Success - Null clears optional fields", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		dueAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "parent"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 2", DueAt: &dueAt, Tags: []string{"home"}, ParentID: intPtr(1), BlockedBy: []int{1}}))
+
+		//act
+		err := rp.UpdatePartial(2, 0, internal.TaskPatch{DueAt: internal.Set[*time.Time](nil), Tags: internal.Set[[]string](nil), ParentID: internal.Set[*int](nil), BlockedBy: internal.Set[[]int](nil)})
+		task, errGet := rp.GetByID(2)
+
+		//assert
+		require.NoError(t, err)
+		require.NoError(t, errGet)
+		require.Nil(t, task.DueAt)
+		require.Empty(t, task.Tags)
+		require.Nil(t, task.ParentID)
+		require.Empty(t, task.BlockedBy)
+	})
+
+	//Test un valor invalido para la tarea no la actualiza
+	//Los nombres y tipos de los campos los valida internal.ParseTaskPatch antes de llegar al repositorio
+	t.Run("Error - Invalid field values", func(t *testing.T) {
+		early := time.Date(2024, 4, 1, 10, 0, 0, 0, time.UTC)
+		late := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+		cases := map[string]internal.TaskPatch{
+			"unknown priority":       {Priority: internal.Set(internal.Priority(9))},
+			"empty tag":              {Tags: internal.Set([]string{" "})},
+			"start after due":        {StartAt: internal.Set(&late), DueAt: internal.Set(&early)},
+			"recurrence without due": {Recurrence: internal.Set(&internal.Recurrence{Frequency: internal.FrequencyDaily})},
+			"missing parent":         {ParentID: internal.Set(intPtr(99))},
+			"missing blocking task":  {BlockedBy: internal.Set([]int{99})},
+		}
+
+		for name, patch := range cases {
+			t.Run(name, func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+
+				//act
+				err := rp.UpdatePartial(1, 0, patch)
+				task, errGet := rp.GetByID(1)
+
+				//assert
+				require.ErrorIs(t, err, internal.ErrTaskInvalidField)
+				require.NoError(t, errGet)
+				require.Equal(t, "task 1", task.Tittle)
+				require.Equal(t, 1, task.Version)
+			})
+		}
+	})
+
+	//Test una version distinta de la guardada no actualiza la tarea
+	t.Run("Error - Version conflict", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+
+		//act
+		err := rp.UpdatePartial(1, 2, internal.TaskPatch{Done: internal.Set(true)})
+		task, errGet := rp.GetByID(1)
+
+		//assert
+		require.ErrorIs(t, err, internal.ErrTaskVersionConflict)
+		require.NoError(t, errGet)
+		require.False(t, task.Done)
+	})
+}
+
+// Casos de tareas que no existen
+func testNotFound(t *testing.T, factory Factory) {
+
+	//Test las operaciones sobre una tarea que no existe devuelven ErrTaskNotFound
+	t.Run("Error - Not found", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+
+		//act
+		_, errGet := rp.GetByID(2)
+		errUpdate := rp.Update(internal.Task{ID: 2, Tittle: "task 2"})
+		errPartial := rp.UpdatePartial(2, 0, internal.TaskPatch{Done: internal.Set(true)})
+		errDelete := rp.Delete(2, 0)
+		errAddTag := rp.AddTag(2, "home")
+		errRemoveTag := rp.RemoveTag(2, "home")
+		errAddDependency := rp.AddDependency(2, 1)
+		errRemoveDependency := rp.RemoveDependency(2, 1)
+		errBlocker := rp.AddDependency(1, 2)
+		errRestore := rp.Restore(1)
+		errPurge := rp.Purge(1)
+
+		//assert
+		require.ErrorIs(t, errGet, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errUpdate, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errPartial, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errDelete, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errAddTag, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errRemoveTag, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errAddDependency, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errRemoveDependency, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errBlocker, internal.ErrTaskInvalidField, "a missing blocker is an invalid field of the task")
+		require.ErrorIs(t, errRestore, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errPurge, internal.ErrTaskNotFound)
+	})
+
+	//Test una tarea de la papelera no se encuentra hasta que se restaura
+	t.Run("Error - Trashed task", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+		require.NoError(t, rp.Delete(1, 0))
+
+		//act
+		_, errGet := rp.GetByID(1)
+		errUpdate := rp.Update(internal.Task{ID: 1, Tittle: "task 1"})
+		errPartial := rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)})
+		errDelete := rp.Delete(1, 0)
+		errTag := rp.AddTag(1, "home")
+
+		//assert
+		require.ErrorIs(t, errGet, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errUpdate, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errPartial, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errDelete, internal.ErrTaskNotFound)
+		require.ErrorIs(t, errTag, internal.ErrTaskNotFound)
+	})
+}
+
+// Casos de Delete y de la papelera
+func testDelete(t *testing.T, factory Factory) {
+
+	//Test eliminar mueve la tarea a la papelera y actualiza sus subtareas y las tareas que dependian de ella
+	t.Run("Success - Delete, restore and purge", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		parent := 1
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "parent"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task", ParentID: &parent}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "child", ParentID: intPtr(2)}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "dependent", BlockedBy: []int{2}}))
+
+		//act
+		errDelete := rp.Delete(2, 0)
+		child, errChild := rp.GetByID(3)
+		dependent, errDependent := rp.GetByID(4)
+		trash, errTrash := rp.ListTrash()
+		errRestore := rp.Restore(2)
+		restored, errRestored := rp.GetByID(2)
+		require.NoError(t, rp.Delete(4, 0))
+		errPurge := rp.Purge(4)
+		trashAfter, errTrashAfter := rp.ListTrash()
+
+		//assert
+		require.NoError(t, errDelete)
+		require.NoError(t, errChild)
+		require.Equal(t, &parent, child.ParentID)
+		require.Equal(t, 2, child.Version)
+		require.NoError(t, errDependent)
+		require.Empty(t, dependent.BlockedBy)
+		require.Equal(t, 2, dependent.Version)
+		require.NoError(t, errTrash)
+		require.Len(t, trash, 1)
+		require.Equal(t, 2, trash[0].ID)
+		require.NotNil(t, trash[0].DeletedAt)
+		require.Equal(t, 2, trash[0].Version)
+
+		require.NoError(t, errRestore)
+		require.NoError(t, errRestored)
+		require.Nil(t, restored.DeletedAt)
+		require.Equal(t, &parent, restored.ParentID)
+		require.Equal(t, 3, restored.Version)
+		require.NoError(t, errPurge)
+		require.NoError(t, errTrashAfter)
+		require.Empty(t, trashAfter)
+	})
+
+	//Test al restaurar se quitan el padre y las dependencias que ya no existen
+	t.Run("Success - Restore drops missing references", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "parent"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "blocker"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task", ParentID: intPtr(1), BlockedBy: []int{2}}))
+		require.NoError(t, rp.Delete(3, 0))
+		require.NoError(t, rp.Delete(1, 0))
+		require.NoError(t, rp.Delete(2, 0))
+		require.NoError(t, rp.Purge(2))
+
+		//act
+		err := rp.Restore(3)
+		task, errGet := rp.GetByID(3)
+
+		//assert
+		require.NoError(t, err)
+		require.NoError(t, errGet)
+		require.Nil(t, task.ParentID)
+		require.Empty(t, task.BlockedBy)
+	})
+
+	//Test una version distinta de la guardada no elimina la tarea
+	t.Run("Error - Version conflict", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+
+		//act
+		err := rp.Delete(1, 2)
+		_, errGet := rp.GetByID(1)
+
+		//assert
+		require.ErrorIs(t, err, internal.ErrTaskVersionConflict)
+		require.NoError(t, errGet)
+	})
+
+	//Test eliminar definitivamente las tareas de la papelera anteriores a un instante
+	t.Run("Success - Purge trash before", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 2"}))
+		require.NoError(t, rp.Delete(1, 0))
+		require.NoError(t, rp.Delete(2, 0))
+
+		//act
+		none, errNone := rp.PurgeTrash(time.Now().Add(-time.Hour))
+		all, errAll := rp.PurgeTrash(time.Now().Add(time.Hour))
+		trash, errTrash := rp.ListTrash()
+
+		//assert
+		require.NoError(t, errNone)
+		require.Equal(t, 0, none)
+		require.NoError(t, errAll)
+		require.Equal(t, 2, all)
+		require.NoError(t, errTrash)
+		require.Empty(t, trash)
+	})
+}
+
+// Casos de etiquetas y dependencias
+func testTagsAndDependencies(t *testing.T, factory Factory) {
+
+	//Test agregar y quitar etiquetas y dependencias
+	t.Run("Success - Tags and dependencies", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1", Tags: []string{"home"}}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 2"}))
+
+		//act
+		require.NoError(t, rp.AddTag(2, " Home "))
+		require.NoError(t, rp.AddTag(2, "work"))
+		require.NoError(t, rp.RemoveTag(1, "home"))
+		tags, errTags := rp.ListTags()
+		errTag := rp.AddTag(1, "a,b")
+
+		errDependency := rp.AddDependency(2, 1)
+		errCycle := rp.AddDependency(1, 2)
+		blocked, errBlocked := rp.GetByID(2)
+		require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)}))
+		unblocked, errUnblocked := rp.GetByID(2)
+		require.NoError(t, rp.RemoveDependency(2, 1))
+		removed, errRemoved := rp.GetByID(2)
+
+		//assert
+		require.NoError(t, errTags)
+		require.Equal(t, []internal.TagCount{{Tag: "home", Count: 1}, {Tag: "work", Count: 1}}, tags)
+		require.ErrorIs(t, errTag, internal.ErrTaskInvalidField)
+
+		require.NoError(t, errDependency)
+		require.ErrorIs(t, errCycle, internal.ErrTaskDependencyCycle)
+		require.NoError(t, errBlocked)
+		require.Equal(t, []int{1}, blocked.BlockedBy)
+		require.True(t, blocked.Blocked)
+		require.NoError(t, errUnblocked)
+		require.False(t, unblocked.Blocked)
+		//Terminar la tarea que la bloquea cambia Blocked, por eso tambien cambia su version
+		require.Equal(t, blocked.Version+1, unblocked.Version)
+		require.False(t, unblocked.UpdatedAt.Before(blocked.UpdatedAt))
+		require.NoError(t, errRemoved)
+		require.Empty(t, removed.BlockedBy)
+		require.Equal(t, []string{"home", "work"}, removed.Tags)
+		require.Equal(t, 6, removed.Version)
+	})
+
+	//Test solo cambia la version de las tareas que cambian de Blocked al terminar o reabrir la tarea que las bloquea
+	t.Run("Success - Blocked changes the version", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 2"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 3"}))
+		require.NoError(t, rp.AddDependency(3, 1))
+		require.NoError(t, rp.AddDependency(3, 2))
+		start, err := rp.GetByID(3)
+		require.NoError(t, err)
+
+		//act
+		require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)}))
+		stillBlocked, errStill := rp.GetByID(3)
+		require.NoError(t, rp.UpdatePartial(2, 0, internal.TaskPatch{Done: internal.Set(true)}))
+		unblocked, errUnblocked := rp.GetByID(3)
+		require.NoError(t, rp.UpdatePartial(2, 0, internal.TaskPatch{Done: internal.Set(false)}))
+		reblocked, errReblocked := rp.GetByID(3)
+
+		//assert
+		require.NoError(t, errStill)
+		require.True(t, stillBlocked.Blocked)
+		require.Equal(t, start.Version, stillBlocked.Version)
+		require.NoError(t, errUnblocked)
+		require.False(t, unblocked.Blocked)
+		require.Equal(t, start.Version+1, unblocked.Version)
+		require.NoError(t, errReblocked)
+		require.True(t, reblocked.Blocked)
+		require.Equal(t, start.Version+2, reblocked.Version)
+	})
+}
+
+// Casos de List
+func testList(t *testing.T, factory Factory) {
+
+	//Test listar con filtros, orden y paginacion
+	t.Run("Success - Filter, sort and paginate", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "buy milk", Priority: internal.PriorityLow}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "write report", Done: true}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "buy bread", Priority: internal.PriorityHigh}))
+		done := false
+
+		//act
+		page, err := rp.List(internal.TaskQuery{Done: &done, Search: "BUY", SortBy: internal.TaskSortPriority, SortDesc: true, Limit: 1})
+		next, errNext := rp.List(internal.TaskQuery{Done: &done, Search: "BUY", SortBy: internal.TaskSortPriority, SortDesc: true, Limit: 1, Offset: 1})
+
+		//assert
+		require.NoError(t, err)
+		require.Equal(t, 2, page.Total)
+		require.Len(t, page.Tasks, 1)
+		require.Equal(t, "buy bread", page.Tasks[0].Tittle)
+		require.NoError(t, errNext)
+		require.Len(t, next.Tasks, 1)
+		require.Equal(t, "buy milk", next.Tasks[0].Tittle)
+	})
+
+	//Test recorrer las paginas con el cursor en los dos sentidos, las fechas se comparan en orden cronologico
+	//aunque tengan distinta zona horaria y las tareas sin fecha van al final
+	t.Run("Success - Cursor pages by due date", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		zone := time.FixedZone("UTC+2", 2*60*60)
+		first := time.Date(2026, 1, 2, 10, 0, 0, 0, zone)
+		second := time.Date(2026, 1, 2, 8, 30, 0, 500, time.UTC)
+		third := time.Date(2026, 1, 2, 9, 0, 0, 0, time.UTC)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1", DueAt: &first}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 2", DueAt: &third}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 3"}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 4", DueAt: &second}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 5"}))
+
+		//Funcion para leer todas las paginas de a dos tareas
+		listIDs := func(desc bool) (ids []int) {
+			query := internal.TaskQuery{SortBy: internal.TaskSortDueAt, SortDesc: desc, Limit: 2}
+			for {
+				page, err := rp.List(query)
+				require.NoError(t, err)
+				require.Equal(t, 5, page.Total)
+				for _, task := range page.Tasks {
+					ids = append(ids, task.ID)
+				}
+				if page.NextCursor == "" {
+					return
+				}
+				query.Cursor = page.NextCursor
+			}
+		}
+
+		//act
+		asc := listIDs(false)
+		desc := listIDs(true)
+
+		//assert
+		require.Equal(t, []int{1, 4, 2, 3, 5}, asc)
+		require.Equal(t, []int{2, 4, 1, 5, 3}, desc)
+	})
+
+	//Test filtrar por texto sin distinguir mayusculas fuera de ASCII, por etiquetas y por fecha limite
+	t.Run("Success - Filter by search, tags and due date", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		dueAt := time.Date(2026, 1, 2, 10, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "Ärger", Tags: []string{"home"}, DueAt: &dueAt}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 2", Description: "äpfel kaufen", Tags: []string{"home", "work"}}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 3", Tags: []string{"work"}}))
+		from := time.Date(2026, 1, 2, 8, 0, 0, 0, time.UTC)
+		to := from.Add(time.Minute)
+
+		//act
+		search, errSearch := rp.List(internal.TaskQuery{Search: "ÄR"})
+		tagsAny, errAny := rp.List(internal.TaskQuery{TagsAny: []string{"HOME", "work"}, SortBy: internal.TaskSortTittle})
+		all, errAll := rp.List(internal.TaskQuery{TagsAll: []string{"home", "work"}})
+		due, errDue := rp.List(internal.TaskQuery{DueFrom: &from, DueTo: &from})
+		overdue, errOverdue := rp.List(internal.TaskQuery{OverdueAt: &to})
+
+		//assert
+		require.NoError(t, errSearch)
+		require.Len(t, search.Tasks, 1)
+		require.Equal(t, 1, search.Tasks[0].ID)
+		require.NoError(t, errAny)
+		require.Equal(t, 3, tagsAny.Total)
+		require.Equal(t, 2, tagsAny.Tasks[0].ID)
+		require.NoError(t, errAll)
+		require.Len(t, all.Tasks, 1)
+		require.Equal(t, 2, all.Tasks[0].ID)
+		require.NoError(t, errDue)
+		require.Len(t, due.Tasks, 1)
+		require.Equal(t, 1, due.Tasks[0].ID)
+		require.NoError(t, errOverdue)
+		require.Len(t, overdue.Tasks, 1)
+		require.Equal(t, 1, overdue.Tasks[0].ID)
+	})
+
+	//Test las tareas de la papelera no se listan
+	t.Run("Success - Trashed tasks are not listed", func(t *testing.T) {
+
+		//arrange
+		rp := factory(t)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1", Tags: []string{"home"}}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 2"}))
+		require.NoError(t, rp.Delete(1, 0))
+
+		//act
+		page, err := rp.List(internal.TaskQuery{})
+		tags, errTags := rp.ListTags()
+
+		//assert
+		require.NoError(t, err)
+		require.Equal(t, 1, page.Total)
+		require.Equal(t, 2, page.Tasks[0].ID)
+		require.NoError(t, errTags)
+		require.Empty(t, tags)
+	})
+}
+
+// Funcion para obtener un puntero a un entero
+func intPtr(value int) *int {
+	return &value
+}
@@ -0,0 +1,132 @@
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de las subtareas en todas las implementaciones de TaskRepository
+func TestSubtasks(t *testing.T) {
+	factories := map[string]func(t *testing.T) internal.TaskRepository{
+		"map": func(t *testing.T) internal.TaskRepository {
+			return repository.NewTaskMap(nil, 0)
+		},
+		"sqlite": func(t *testing.T) internal.TaskRepository {
+			return newTaskSQL(t, ":memory:")
+		},
+	}
+
+	// Crea la jerarquia 1 -> 2 -> 3
+	newTree := func(t *testing.T, rp internal.TaskRepository) {
+		parent := internal.Task{Tittle: "parent"}
+		require.NoError(t, rp.Save(&parent))
+		child := internal.Task{Tittle: "child", ParentID: &parent.ID}
+		require.NoError(t, rp.Save(&child))
+		grandchild := internal.Task{Tittle: "grandchild", ParentID: &child.ID}
+		require.NoError(t, rp.Save(&grandchild))
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+
+			//Test listar las subtareas de una tarea
+			t.Run("Success - List children", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				newTree(t, rp)
+				parentID := 1
+
+				//act
+				page, err := rp.List(internal.TaskQuery{ParentID: &parentID})
+
+				//assert
+				require.NoError(t, err)
+				require.Equal(t, 1, page.Total)
+				require.Equal(t, 2, page.Tasks[0].ID)
+				require.Equal(t, 1, *page.Tasks[0].ParentID)
+			})
+
+			//Test el padre debe existir y no puede formar un ciclo
+			t.Run("Error - Missing parent and cycles", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				newTree(t, rp)
+				missing, one, three := 99, 1, 3
+
+				//act & assert
+				task := internal.Task{Tittle: "orphan", ParentID: &missing}
+				require.ErrorIs(t, rp.Save(&task), internal.ErrTaskInvalidField)
+				require.ErrorIs(t, rp.UpdatePartial(1, 0, internal.TaskPatch{ParentID: internal.Set(&three)}), internal.ErrTaskParentCycle)
+				require.ErrorIs(t, rp.UpdatePartial(1, 0, internal.TaskPatch{ParentID: internal.Set(&one)}), internal.ErrTaskParentCycle)
+
+				require.NoError(t, rp.UpdatePartial(3, 0, internal.TaskPatch{ParentID: internal.Set[*int](nil)}))
+				task, err := rp.GetByID(3)
+				require.NoError(t, err)
+				require.Nil(t, task.ParentID)
+			})
+
+			//Test una tarea no puede terminarse con subtareas abiertas
+			t.Run("Error - Done with open children", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				newTree(t, rp)
+
+				//act & assert
+				require.ErrorIs(t, rp.UpdatePartial(2, 0, internal.TaskPatch{Done: internal.Set(true)}), internal.ErrTaskOpenChildren)
+				require.NoError(t, rp.UpdatePartial(3, 0, internal.TaskPatch{Done: internal.Set(true)}))
+				require.NoError(t, rp.UpdatePartial(2, 0, internal.TaskPatch{Done: internal.Set(true)}))
+
+				//Una subtarea abierta nueva vuelve a bloquear al padre
+				require.NoError(t, rp.UpdatePartial(2, 0, internal.TaskPatch{Done: internal.Set(false)}))
+				require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(false)}))
+				require.ErrorIs(t, rp.Update(internal.Task{ID: 1, Tittle: "parent", Done: true}), internal.ErrTaskOpenChildren)
+			})
+
+			//Test al eliminar una tarea sus subtareas pasan a su padre
+			t.Run("Success - Delete reparents children", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				newTree(t, rp)
+
+				//act
+				err := rp.Delete(2, 0)
+
+				//assert
+				require.NoError(t, err)
+				task, err := rp.GetByID(3)
+				require.NoError(t, err)
+				require.Equal(t, 1, *task.ParentID)
+			})
+		})
+	}
+
+	//Test el cambio de padre de las subtareas se recupera del WAL
+	t.Run("Success - Reparent is replayed from the WAL", func(t *testing.T) {
+
+		//arrange
+		file := filepath.Join(t.TempDir(), "tasks.json")
+		rp, err := repository.NewTaskMapWAL(file, 0)
+		require.NoError(t, err)
+		newTree(t, rp)
+
+		//act
+		require.NoError(t, rp.Delete(2, 0))
+		rp, err = repository.NewTaskMapWAL(file, 0)
+		require.NoError(t, err)
+
+		//assert
+		task, err := rp.GetByID(3)
+		require.NoError(t, err)
+		require.Equal(t, 1, *task.ParentID)
+		_, err = rp.GetByID(2)
+		require.ErrorIs(t, err, internal.ErrTaskNotFound)
+	})
+}
@@ -0,0 +1,101 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de las etiquetas en todas las implementaciones de TaskRepository
+func TestTags(t *testing.T) {
+	factories := map[string]func(t *testing.T) internal.TaskRepository{
+		"map": func(t *testing.T) internal.TaskRepository {
+			return repository.NewTaskMap(nil, 0)
+		},
+		"sqlite": func(t *testing.T) internal.TaskRepository {
+			return newTaskSQL(t, ":memory:")
+		},
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+
+			//Test las etiquetas se normalizan y se cuentan
+			t.Run("Success - Normalize, index and count tags", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				first := internal.Task{Tittle: "task 1", Tags: []string{" Home ", "urgent", "home"}}
+				second := internal.Task{Tittle: "task 2", Tags: []string{"work"}}
+				require.NoError(t, rp.Save(&first))
+				require.NoError(t, rp.Save(&second))
+
+				//act
+				require.NoError(t, rp.AddTag(2, "URGENT"))
+				require.NoError(t, rp.AddTag(2, "urgent"))
+				require.NoError(t, rp.RemoveTag(1, "home"))
+				require.NoError(t, rp.RemoveTag(1, "missing"))
+				require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Tags: internal.Set([]string{"urgent", "later"})}))
+				tags, err := rp.ListTags()
+
+				//assert
+				require.NoError(t, err)
+				require.Equal(t, []internal.TagCount{
+					{Tag: "later", Count: 1},
+					{Tag: "urgent", Count: 2},
+					{Tag: "work", Count: 1},
+				}, tags)
+
+				task, err := rp.GetByID(2)
+				require.NoError(t, err)
+				require.Equal(t, []string{"urgent", "work"}, task.Tags)
+			})
+
+			//Test filtrar por alguna o por todas las etiquetas
+			t.Run("Success - Filter by any and all tags", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				for _, task := range []internal.Task{
+					{Tittle: "task 1", Tags: []string{"a", "b"}},
+					{Tittle: "task 2", Tags: []string{"b"}},
+					{Tittle: "task 3", Tags: []string{"c"}},
+					{Tittle: "task 4"},
+				} {
+					require.NoError(t, rp.Save(&task))
+				}
+
+				//act
+				anyPage, err := rp.List(internal.TaskQuery{TagsAny: []string{"a", "C"}})
+				require.NoError(t, err)
+				allPage, err := rp.List(internal.TaskQuery{TagsAll: []string{"a", "b"}})
+				require.NoError(t, err)
+
+				//assert
+				require.Equal(t, 2, anyPage.Total)
+				require.Equal(t, 1, anyPage.Tasks[0].ID)
+				require.Equal(t, 3, anyPage.Tasks[1].ID)
+				require.Equal(t, 1, allPage.Total)
+				require.Equal(t, 1, allPage.Tasks[0].ID)
+			})
+
+			//Test errores de las etiquetas
+			t.Run("Error - Invalid tag and missing task", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				task := internal.Task{Tittle: "task 1"}
+				require.NoError(t, rp.Save(&task))
+
+				//act & assert
+				require.ErrorIs(t, rp.AddTag(1, "  "), internal.ErrTaskInvalidField)
+				require.ErrorIs(t, rp.AddTag(1, "a,b"), internal.ErrTaskInvalidField)
+				require.ErrorIs(t, rp.AddTag(99, "a"), internal.ErrTaskNotFound)
+				require.ErrorIs(t, rp.RemoveTag(99, "a"), internal.ErrTaskNotFound)
+				require.ErrorIs(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Tags: internal.Set([]string{"a,b"})}), internal.ErrTaskInvalidField)
+			})
+		})
+	}
+}
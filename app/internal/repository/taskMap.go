@@ -1,11 +1,31 @@
 package repository
 
-import "github.com/Taks/internal"
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Taks/internal"
+)
 
 // Esta es una implementacion de la interfaz TaskRepository basada en un mapa
+// Es segura para usarse desde varias goroutines: las lecturas se hacen en paralelo y las escrituras de a una
 type TaskMap struct {
+	mu     sync.RWMutex
 	db     map[int]internal.Task
 	lastId int
+
+	// Indice de etiquetas: para cada etiqueta los IDs de las tareas que la tienen
+	tags map[string]map[int]struct{}
+
+	// Tareas de la papelera, no estan en db ni en el indice de etiquetas
+	trash map[int]internal.Task
+
+	// Almacenamiento donde se persisten los cambios, si es nil las tareas solo viven en memoria
+	storage taskMapStorage
 }
 
 // Funcion para inicializar el repositorio de tareas
@@ -25,14 +45,56 @@ func NewTaskMap(mapa map[int]internal.Task, lastId int) *TaskMap {
 	}
 
 	//Retornar el repositorio
-	return &TaskMap{
-		defaultTasks,
-		defaultLastId,
+	return newTaskMap(defaultTasks, defaultLastId)
+}
+
+// Funcion para crear el repositorio a partir de un mapa ya cargado, arma el indice de etiquetas
+// Las tareas con DeletedAt se pasan a la papelera
+func newTaskMap(db map[int]internal.Task, lastId int) *TaskMap {
+	t := &TaskMap{
+		db:     db,
+		lastId: lastId,
+		tags:   make(map[string]map[int]struct{}),
+		trash:  make(map[int]internal.Task),
+	}
+
+	for id, task := range db {
+		// Las tareas guardadas antes de tener version empiezan en 1
+		if task.Version == 0 {
+			task.Version = 1
+			db[id] = task
+		}
+
+		if task.DeletedAt != nil {
+			delete(db, id)
+			t.trash[id] = task
+			continue
+		}
+		t.indexTags(task)
 	}
+	return t
 }
 
 // Funcion para crear una tareas
 func (t *TaskMap) Save(task *internal.Task) (err error) {
+	//Bloquear el mapa para escritura
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	//Se validan los campos de la tarea
+	if err = validateTask(task); err != nil {
+		return
+	}
+
+	//Se valida la jerarquia de la tarea
+	if err = checkTaskTree(*task, t); err != nil {
+		return
+	}
+
+	//Se validan las dependencias de la tarea
+	if err = checkTaskDependencies(*task, t); err != nil {
+		return
+	}
 
 	//Se valida que la tarea no este duplicada
 	for _, value := range (*t).db {
@@ -45,23 +107,60 @@ func (t *TaskMap) Save(task *internal.Task) (err error) {
 	//Se incrementa el ultimo ID
 	(*t).lastId++
 
-	//Se asigna a la tarea el ultimo ID
+	//Se asigna a la tarea el ultimo ID, la primera version y las fechas de creacion
 	(*task).ID = (*t).lastId
+	(*task).Version = 1
+	(*task).CreatedAt = changeTime()
+	(*task).UpdatedAt = (*task).CreatedAt
 
 	//Se guarda la tarea en el mapa
-	(*t).db[(*task).ID] = *task
+	t.put(*task)
 
+	//Se persiste el cambio, si falla se deshace
+	err = t.commit(taskChange{Op: taskOpSave, Task: *task, LastID: (*t).lastId}, func() {
+		t.remove((*task).ID)
+		(*t).lastId--
+		(*task).ID = 0
+		(*task).Version = 0
+		(*task).CreatedAt = time.Time{}
+		(*task).UpdatedAt = time.Time{}
+	})
 	return
 }
 
 // Funcion para actualizar una tarea
 func (t *TaskMap) Update(task internal.Task) (err error) {
+	//Bloquear el mapa para escritura
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
 	//Verificar que exista
-	if _, ok := (*t).db[(task).ID]; !ok {
+	prev, ok := (*t).db[(task).ID]
+	if !ok {
 		err = internal.ErrTaskNotFound
 		return
 	}
 
+	//Verificar que nadie la haya modificado desde que se leyo
+	if err = checkVersion(prev, task.Version); err != nil {
+		return
+	}
+
+	//Validar los campos de la tarea
+	if err = validateTask(&task); err != nil {
+		return
+	}
+
+	//Validar la jerarquia de la tarea
+	if err = checkTaskTree(task, t); err != nil {
+		return
+	}
+
+	//Validar las dependencias de la tarea
+	if err = checkTaskDependencies(task, t); err != nil {
+		return
+	}
+
 	//Verificar que no exista otra tarea con el mismo titulo
 	for _, t := range (*t).db {
 		if t.ID != task.ID && t.Tittle == task.Tittle {
@@ -70,81 +169,301 @@ func (t *TaskMap) Update(task internal.Task) (err error) {
 		}
 	}
 
-	//Actualizar la tarea
-	(*t).db[(task).ID] = task
+	//Actualizar la tarea, la fecha de creacion no se puede cambiar
+	task.Version = prev.Version + 1
+	task.CreatedAt = prev.CreatedAt
+	task.UpdatedAt = changeTime()
+	t.put(task)
+
+	//Si se termino una tarea recurrente se crea la siguiente ocurrencia, si falla se deshace el cambio
+	created, err := t.putNextOccurrence(prev, task)
+	if err != nil {
+		t.put(prev)
+		return
+	}
+
+	//Las tareas que bloquea pueden cambiar de Blocked
+	touched, prevTouched := t.touchDependents(prev, task)
+
+	//Persistir el cambio, si falla se deshace
+	change := taskChange{Op: taskOpUpdate, Task: task, Updated: append(created, touched...), LastID: (*t).lastId, Before: append([]internal.Task{prev}, prevTouched...)}
+	err = t.commit(change, func() {
+		t.removeCreated(created)
+		t.put(prev)
+		for _, dependent := range prevTouched {
+			t.put(dependent)
+		}
+	})
 	return
 }
 
 // Funcion para actualizar parcialmente una tarea
-func (t *TaskMap) UpdatePartial(id int, fields map[string]any) (err error) {
+func (t *TaskMap) UpdatePartial(id int, version int, patch internal.TaskPatch) (err error) {
+	//Bloquear el mapa para escritura
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
 	//Verificar que exista
-	task, ok := (*t).db[id]
+	prev, ok := (*t).db[id]
 	if !ok {
 		err = internal.ErrTaskNotFound
 		return
 	}
 
-	//Actualizar la tarea
-	for key, value := range fields {
+	//Verificar que nadie la haya modificado desde que se leyo
+	if err = checkVersion(prev, version); err != nil {
+		return
+	}
 
-		//Verificar que el campo sea valido
-		switch key {
-		case "tittle", "Tittle":
+	//Aplicar los campos a la tarea
+	task := patch.Apply(prev)
 
-			//Verificar que el valor sea un string
-			tittle, ok := value.(string)
-			if !ok {
-				err = internal.ErrTaskInvalidField
-				return
-			}
+	//Validar los campos de la tarea
+	if err = validateTask(&task); err != nil {
+		return
+	}
 
-			// Verificar que no exista otra tarea con el mismo titulo
-			for _, t := range (*t).db {
-				if t.ID != id && t.Tittle == tittle {
-					err = internal.ErrTaskDuplicated
-					return
-				}
-			}
+	//Validar la jerarquia de la tarea
+	if err = checkTaskTree(task, t); err != nil {
+		return
+	}
 
-			//Actualizar el titulo
-			task.Tittle = tittle
-		case "description", "Description":
-			task.Description, ok = value.(string)
-			if !ok {
-				err = internal.ErrTaskNotFound
-				return
-			}
-		case "done", "Done":
-			task.Done, ok = value.(bool)
-			if !ok {
-				err = internal.ErrTaskNotFound
-				return
-			}
-		default:
+	//Validar las dependencias de la tarea
+	if err = checkTaskDependencies(task, t); err != nil {
+		return
+	}
+
+	// Verificar que no exista otra tarea con el mismo titulo
+	for _, t := range (*t).db {
+		if t.ID != id && t.Tittle == task.Tittle {
+			err = internal.ErrTaskDuplicated
+			return
 		}
 	}
 
-	//Actualizar la tarea
-	(*t).db[id] = task
+	//Actualizar la tarea, la fecha de creacion no se puede cambiar
+	task.Version = prev.Version + 1
+	task.CreatedAt = prev.CreatedAt
+	task.UpdatedAt = changeTime()
+	t.put(task)
+
+	//Si se termino una tarea recurrente se crea la siguiente ocurrencia, si falla se deshace el cambio
+	created, err := t.putNextOccurrence(prev, task)
+	if err != nil {
+		t.put(prev)
+		return
+	}
+
+	//Las tareas que bloquea pueden cambiar de Blocked
+	touched, prevTouched := t.touchDependents(prev, task)
+
+	//Persistir el cambio, si falla se deshace
+	change := taskChange{Op: taskOpUpdatePartial, Task: task, Updated: append(created, touched...), LastID: (*t).lastId, Before: append([]internal.Task{prev}, prevTouched...)}
+	err = t.commit(change, func() {
+		t.removeCreated(created)
+		t.put(prev)
+		for _, dependent := range prevTouched {
+			t.put(dependent)
+		}
+	})
 	return
 }
 
 // Funcion para eliminar una tarea
-func (t *TaskMap) Delete(id int) (err error) {
+func (t *TaskMap) Delete(id int, version int) (err error) {
+	//Bloquear el mapa para escritura
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
 	// Validar que exista
-	_, ok := (*t).db[id]
+	prev, ok := (*t).db[id]
 	if !ok {
 		err = internal.ErrTaskNotFound
 		return
 	}
 
-	// Eliminar la tarea
-	delete((*t).db, id)
+	// Verificar que nadie la haya modificado desde que se leyo
+	if err = checkVersion(prev, version); err != nil {
+		return
+	}
+
+	// Las subtareas pasan a ser hijas del padre de la tarea eliminada
+	// y las tareas que dependian de ella dejan de depender
+	prevRelated := []internal.Task{}
+	related := []internal.Task{}
+	now := changeTime()
+	for _, task := range (*t).db {
+		updated := task
+		changed := false
+		if task.ParentID != nil && *task.ParentID == id {
+			updated.ParentID = prev.ParentID
+			changed = true
+		}
+		if blockedBy := removeID(task.BlockedBy, id); len(blockedBy) != len(task.BlockedBy) {
+			updated.BlockedBy = blockedBy
+			changed = true
+		}
+
+		if changed {
+			updated.Version++
+			updated.UpdatedAt = now
+			prevRelated = append(prevRelated, task)
+			related = append(related, updated)
+		}
+	}
+
+	// Mover la tarea a la papelera y actualizar las relacionadas
+	trashed := prev
+	trashed.Version++
+	trashed.UpdatedAt = now
+	trashed.DeletedAt = &now
+
+	t.remove(id)
+	(*t).trash[id] = trashed
+	for _, task := range related {
+		t.put(task)
+	}
+
+	// Persistir el cambio, si falla se deshace
+	change := taskChange{Op: taskOpDelete, Task: trashed, Updated: related, LastID: (*t).lastId, Before: append([]internal.Task{prev}, prevRelated...)}
+	err = t.commit(change, func() {
+		delete((*t).trash, id)
+		t.put(prev)
+		for _, task := range prevRelated {
+			t.put(task)
+		}
+	})
+	return
+}
+
+// Funcion para listar las tareas de la papelera
+func (t *TaskMap) ListTrash() (tasks []internal.Task, err error) {
+	//Bloquear el mapa para lectura
+	(*t).mu.RLock()
+	defer (*t).mu.RUnlock()
+
+	tasks = make([]internal.Task, 0, len((*t).trash))
+	for _, task := range (*t).trash {
+		tasks = append(tasks, t.withBlocked(task))
+	}
+
+	sortTrash(tasks)
+	return
+}
+
+// Funcion para sacar una tarea de la papelera
+func (t *TaskMap) Restore(id int) (err error) {
+	//Bloquear el mapa para escritura
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	// Validar que este en la papelera
+	prev, ok := (*t).trash[id]
+	if !ok {
+		err = internal.ErrTaskNotFound
+		return
+	}
+
+	// Quitar el padre y las dependencias que ya no existen
+	task, err := restoreTask(prev, t)
+	if err != nil {
+		return
+	}
+
+	// Validar la jerarquia y las dependencias contra las tareas actuales
+	if err = checkTaskTree(task, t); err != nil {
+		return
+	}
+	if err = checkTaskDependencies(task, t); err != nil {
+		return
+	}
+
+	// Mientras estuvo en la papelera otra tarea pudo tomar su titulo
+	for _, value := range (*t).db {
+		if value.Tittle == task.Tittle {
+			err = internal.ErrTaskDuplicated
+			return
+		}
+	}
+
+	task.Version++
+	task.UpdatedAt = changeTime()
+
+	delete((*t).trash, id)
+	t.put(task)
+
+	// Persistir el cambio, si falla se deshace
+	err = t.commit(taskChange{Op: taskOpRestore, Task: task, LastID: (*t).lastId, Before: []internal.Task{prev}}, func() {
+		t.remove(id)
+		(*t).trash[id] = prev
+	})
+	return
+}
+
+// Funcion para eliminar definitivamente una tarea de la papelera
+func (t *TaskMap) Purge(id int) (err error) {
+	//Bloquear el mapa para escritura
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	if _, ok := (*t).trash[id]; !ok {
+		err = internal.ErrTaskNotFound
+		return
+	}
+
+	err = t.purge([]int{id})
+	return
+}
+
+// Funcion para eliminar definitivamente las tareas que se movieron a la papelera antes de un instante
+func (t *TaskMap) PurgeTrash(before time.Time) (purged int, err error) {
+	//Bloquear el mapa para escritura
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	ids := []int{}
+	for id, task := range (*t).trash {
+		if task.DeletedAt.Before(before) {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	if err = t.purge(ids); err != nil {
+		return
+	}
+	purged = len(ids)
+	return
+}
+
+// Funcion para quitar tareas de la papelera y persistir el cambio
+// Se llama con el mapa bloqueado para escritura
+func (t *TaskMap) purge(ids []int) (err error) {
+	sort.Ints(ids)
+
+	prev := make([]internal.Task, 0, len(ids))
+	for _, id := range ids {
+		prev = append(prev, (*t).trash[id])
+		delete((*t).trash, id)
+	}
+
+	// Persistir el cambio, si falla se deshace
+	err = t.commit(taskChange{Op: taskOpPurge, Purged: ids, LastID: (*t).lastId}, func() {
+		for _, task := range prev {
+			(*t).trash[task.ID] = task
+		}
+	})
 	return
 }
 
 // Funcion para obtener una tarea por id
 func (t *TaskMap) GetByID(id int) (task internal.Task, err error) {
+	//Bloquear el mapa para lectura
+	(*t).mu.RLock()
+	defer (*t).mu.RUnlock()
+
 	// Validar que exista
 	task, ok := (*t).db[id]
 	if !ok {
@@ -152,5 +471,427 @@ func (t *TaskMap) GetByID(id int) (task internal.Task, err error) {
 		return
 	}
 
+	task = t.withBlocked(task)
 	return
 }
+
+// Funcion para buscar las tareas con un titulo sin distinguir mayusculas
+func (t *TaskMap) FindByTittle(tittle string) (ids []int, err error) {
+	//Bloquear el mapa para lectura
+	(*t).mu.RLock()
+	defer (*t).mu.RUnlock()
+
+	for id, task := range (*t).db {
+		if strings.EqualFold(task.Tittle, tittle) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Ints(ids)
+	return
+}
+
+// Funcion para listar las tareas que cumplan con la consulta
+func (t *TaskMap) List(query internal.TaskQuery) (page internal.TaskPage, err error) {
+	//Bloquear el mapa para lectura
+	(*t).mu.RLock()
+	defer (*t).mu.RUnlock()
+
+	// Si se filtra por etiquetas solo se revisan las tareas del indice
+	ids, indexed := t.tagCandidates(query)
+	if indexed {
+		tasks := make([]internal.Task, 0, len(ids))
+		for id := range ids {
+			tasks = append(tasks, t.withBlocked((*t).db[id]))
+		}
+
+		page, err = queryTasks(tasks, query)
+		return
+	}
+
+	// Copiar las tareas del mapa, el orden lo define la consulta
+	tasks := make([]internal.Task, 0, len((*t).db))
+	for _, task := range (*t).db {
+		tasks = append(tasks, t.withBlocked(task))
+	}
+
+	page, err = queryTasks(tasks, query)
+	return
+}
+
+// Funcion para agregar una etiqueta a una tarea
+func (t *TaskMap) AddTag(id int, tag string) (err error) {
+	tag, err = internal.NormalizeTag(tag)
+	if err != nil {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+
+	err = t.updateTags(id, func(tags []string) []string {
+		return append(tags, tag)
+	})
+	return
+}
+
+// Funcion para quitar una etiqueta de una tarea
+func (t *TaskMap) RemoveTag(id int, tag string) (err error) {
+	tag, err = internal.NormalizeTag(tag)
+	if err != nil {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+
+	err = t.updateTags(id, func(tags []string) []string {
+		kept := make([]string, 0, len(tags))
+		for _, value := range tags {
+			if value != tag {
+				kept = append(kept, value)
+			}
+		}
+		return kept
+	})
+	return
+}
+
+// Funcion para listar las etiquetas en uso, se obtienen directamente del indice
+func (t *TaskMap) ListTags() (tags []internal.TagCount, err error) {
+	//Bloquear el mapa para lectura
+	(*t).mu.RLock()
+	defer (*t).mu.RUnlock()
+
+	tags = make([]internal.TagCount, 0, len((*t).tags))
+	for tag, ids := range (*t).tags {
+		tags = append(tags, internal.TagCount{Tag: tag, Count: len(ids)})
+	}
+
+	sort.Slice(tags, func(i, j int) bool {
+		return tags[i].Tag < tags[j].Tag
+	})
+	return
+}
+
+// Funcion para agregar una dependencia a una tarea
+func (t *TaskMap) AddDependency(id int, blockedBy int) (err error) {
+	err = t.updateDependencies(id, func(ids []int) []int {
+		return append(ids, blockedBy)
+	})
+	return
+}
+
+// Funcion para quitar una dependencia de una tarea
+func (t *TaskMap) RemoveDependency(id int, blockedBy int) (err error) {
+	err = t.updateDependencies(id, func(ids []int) []int {
+		return removeID(ids, blockedBy)
+	})
+	return
+}
+
+// Funcion para cambiar las dependencias de una tarea y persistir el cambio
+func (t *TaskMap) updateDependencies(id int, change func(ids []int) []int) (err error) {
+	//Bloquear el mapa para escritura
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	// Validar que exista
+	prev, ok := (*t).db[id]
+	if !ok {
+		err = internal.ErrTaskNotFound
+		return
+	}
+
+	// Copiar las dependencias para no modificar las de la tarea guardada
+	task := prev
+	task.BlockedBy = internal.NormalizeDependencies(change(append([]int(nil), prev.BlockedBy...)))
+	if err = checkTaskDependencies(task, t); err != nil {
+		return
+	}
+
+	task.Version++
+	task.UpdatedAt = changeTime()
+
+	t.put(task)
+
+	// Persistir el cambio, si falla se deshace
+	err = t.commit(taskChange{Op: taskOpUpdate, Task: task, LastID: (*t).lastId, Before: []internal.Task{prev}}, func() {
+		t.put(prev)
+	})
+	return
+}
+
+// Funcion para cambiar las etiquetas de una tarea y persistir el cambio
+func (t *TaskMap) updateTags(id int, change func(tags []string) []string) (err error) {
+	//Bloquear el mapa para escritura
+	(*t).mu.Lock()
+	defer (*t).mu.Unlock()
+
+	// Validar que exista
+	prev, ok := (*t).db[id]
+	if !ok {
+		err = internal.ErrTaskNotFound
+		return
+	}
+
+	// Copiar las etiquetas para no modificar las de la tarea guardada
+	task := prev
+	task.Tags, err = internal.NormalizeTags(change(append([]string(nil), prev.Tags...)))
+	if err != nil {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+
+	task.Version++
+	task.UpdatedAt = changeTime()
+
+	t.put(task)
+
+	// Persistir el cambio, si falla se deshace
+	err = t.commit(taskChange{Op: taskOpUpdate, Task: task, LastID: (*t).lastId, Before: []internal.Task{prev}}, func() {
+		t.put(prev)
+	})
+	return
+}
+
+// Funcion para obtener del indice los IDs de las tareas que pueden cumplir el filtro de etiquetas
+// Devuelve false si la consulta no filtra por etiquetas y hay que revisar todas las tareas
+func (t *TaskMap) tagCandidates(query internal.TaskQuery) (ids map[int]struct{}, ok bool) {
+	tagsAny, errAny := internal.NormalizeTags(query.TagsAny)
+	tagsAll, errAll := internal.NormalizeTags(query.TagsAll)
+	if errAny != nil || errAll != nil || (len(tagsAny) == 0 && len(tagsAll) == 0) {
+		// Si las etiquetas son invalidas queryTasks devuelve el error
+		return
+	}
+	ok = true
+
+	// Con todas las etiquetas alcanza con revisar la etiqueta con menos tareas
+	if len(tagsAll) > 0 {
+		ids = (*t).tags[tagsAll[0]]
+		for _, tag := range tagsAll[1:] {
+			if len((*t).tags[tag]) < len(ids) {
+				ids = (*t).tags[tag]
+			}
+		}
+		return
+	}
+
+	// Con alguna de las etiquetas se revisa la union
+	ids = make(map[int]struct{})
+	for _, tag := range tagsAny {
+		for id := range (*t).tags[tag] {
+			ids[id] = struct{}{}
+		}
+	}
+	return
+}
+
+// Funcion para obtener una tarea guardada, la usa la validacion de la jerarquia
+// Se llama con el mapa bloqueado
+func (t *TaskMap) get(id int) (task internal.Task, err error) {
+	task, ok := (*t).db[id]
+	if !ok {
+		err = internal.ErrTaskNotFound
+	}
+	return
+}
+
+// Funcion para saber si una tarea tiene subtareas sin terminar, la usa la validacion de la jerarquia
+// Se llama con el mapa bloqueado
+func (t *TaskMap) hasOpenChildren(id int) (open bool, err error) {
+	for _, task := range (*t).db {
+		if task.ParentID != nil && *task.ParentID == id && !task.Done {
+			open = true
+			return
+		}
+	}
+	return
+}
+
+// Funcion para guardar la siguiente ocurrencia de una tarea recurrente que se acaba de terminar
+// Devuelve las tareas creadas para incluirlas en el cambio, ninguna si no corresponde crear otra ocurrencia
+// Se llama con el mapa bloqueado para escritura
+func (t *TaskMap) putNextOccurrence(prev, task internal.Task) (created []internal.Task, err error) {
+	next, ok, err := nextOccurrence(prev, task, func(title string) (bool, error) {
+		for _, value := range (*t).db {
+			if value.Tittle == title {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil || !ok {
+		return
+	}
+
+	(*t).lastId++
+	next.ID = (*t).lastId
+	t.put(next)
+
+	created = append(created, next)
+	return
+}
+
+// Funcion para deshacer las tareas creadas por putNextOccurrence
+// Se llama con el mapa bloqueado para escritura
+func (t *TaskMap) removeCreated(created []internal.Task) {
+	for _, task := range created {
+		t.remove(task.ID)
+		(*t).lastId--
+	}
+}
+
+// Funcion para cambiar la version y la fecha de modificacion de las tareas que dejan de estar bloqueadas, o que
+// vuelven a estarlo, porque se termino o se reabrio la tarea que las bloquea
+// Blocked es un campo calculado, asi el ETag y la fecha de modificacion de esas tareas cambian junto con el
+// Devuelve las tareas actualizadas y su estado anterior, ordenadas por ID
+// Se llama con el mapa bloqueado para escritura, despues de guardar la tarea
+func (t *TaskMap) touchDependents(prev, task internal.Task) (touched, before []internal.Task) {
+	if prev.Done == task.Done {
+		return
+	}
+
+	for _, dependent := range (*t).db {
+		if !slices.Contains(dependent.BlockedBy, task.ID) || t.blockedByOther(dependent, task.ID) {
+			continue
+		}
+
+		before = append(before, dependent)
+		dependent.Version++
+		dependent.UpdatedAt = task.UpdatedAt
+		touched = append(touched, dependent)
+	}
+
+	sort.Slice(before, func(i, j int) bool { return before[i].ID < before[j].ID })
+	sort.Slice(touched, func(i, j int) bool { return touched[i].ID < touched[j].ID })
+	for _, dependent := range touched {
+		t.put(dependent)
+	}
+	return
+}
+
+// Funcion para saber si una tarea esta bloqueada por alguna tarea sin terminar distinta de id
+// Se llama con el mapa bloqueado
+func (t *TaskMap) blockedByOther(task internal.Task, id int) bool {
+	for _, blockedBy := range task.BlockedBy {
+		if dependency, ok := (*t).db[blockedBy]; ok && blockedBy != id && !dependency.Done {
+			return true
+		}
+	}
+	return false
+}
+
+// Funcion para calcular si una tarea esta bloqueada por alguna tarea sin terminar
+// Se llama con el mapa bloqueado
+func (t *TaskMap) withBlocked(task internal.Task) internal.Task {
+	for _, id := range task.BlockedBy {
+		if dependency, ok := (*t).db[id]; ok && !dependency.Done {
+			task.Blocked = true
+			break
+		}
+	}
+	return task
+}
+
+// Funcion para guardar una tarea en el mapa manteniendo el indice de etiquetas
+// Blocked es un campo calculado, no se guarda
+// Se llama con el mapa bloqueado para escritura
+func (t *TaskMap) put(task internal.Task) {
+	task.Blocked = false
+
+	if prev, ok := (*t).db[task.ID]; ok {
+		t.unindexTags(prev)
+	}
+
+	(*t).db[task.ID] = task
+	t.indexTags(task)
+}
+
+// Funcion para quitar una tarea del mapa manteniendo el indice de etiquetas
+// Se llama con el mapa bloqueado para escritura
+func (t *TaskMap) remove(id int) {
+	if prev, ok := (*t).db[id]; ok {
+		t.unindexTags(prev)
+	}
+
+	delete((*t).db, id)
+}
+
+// Funcion para agregar las etiquetas de una tarea al indice
+func (t *TaskMap) indexTags(task internal.Task) {
+	for _, tag := range task.Tags {
+		ids, ok := (*t).tags[tag]
+		if !ok {
+			ids = make(map[int]struct{})
+			(*t).tags[tag] = ids
+		}
+		ids[task.ID] = struct{}{}
+	}
+}
+
+// Funcion para quitar las etiquetas de una tarea del indice
+func (t *TaskMap) unindexTags(task internal.Task) {
+	for _, tag := range task.Tags {
+		delete((*t).tags[tag], task.ID)
+		if len((*t).tags[tag]) == 0 {
+			delete((*t).tags, tag)
+		}
+	}
+}
+
+// Funcion para persistir un cambio que ya se aplico en memoria
+// Se llama con el mapa bloqueado para escritura, si falla se ejecuta undo para deshacer el cambio
+func (t *TaskMap) commit(change taskChange, undo func()) (err error) {
+	if (*t).storage == nil {
+		return
+	}
+
+	if err = (*t).storage.changed(t, change); err != nil {
+		undo()
+		err = fmt.Errorf("%w: %v", internal.ErrTaskInternal, err)
+	}
+	return
+}
+
+// Funcion para escribir los cambios pendientes y liberar el almacenamiento
+// Si las tareas solo viven en memoria no hace nada
+func (t *TaskMap) Close() (err error) {
+	if (*t).storage == nil {
+		return
+	}
+
+	err = (*t).storage.close(t)
+	return
+}
+
+// Funcion para copiar el estado del mapa, se llama con el mapa bloqueado
+func (t *TaskMap) snapshot() (snap taskSnapshot) {
+	snap.LastID = (*t).lastId
+	snap.Tasks = make([]internal.Task, 0, len((*t).db)+len((*t).trash))
+	for _, task := range (*t).db {
+		snap.Tasks = append(snap.Tasks, task)
+	}
+
+	// Las tareas de la papelera se guardan junto con las demas, se reconocen por DeletedAt
+	for _, task := range (*t).trash {
+		snap.Tasks = append(snap.Tasks, task)
+	}
+
+	// Ordenar por ID para que el archivo sea siempre el mismo para el mismo estado
+	sort.Slice(snap.Tasks, func(i, j int) bool {
+		return snap.Tasks[i].ID < snap.Tasks[j].ID
+	})
+	return
+}
+
+// Funcion para quitar un ID de una lista sin modificar la original
+func removeID(ids []int, id int) []int {
+	kept := make([]int, 0, len(ids))
+	for _, value := range ids {
+		if value != id {
+			kept = append(kept, value)
+		}
+	}
+
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
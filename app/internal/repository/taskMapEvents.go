@@ -0,0 +1,414 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/Taks/internal"
+)
+
+/*
+Este archivo contiene la persistencia del TaskMap con event sourcing.
+
+La fuente de verdad es un flujo de eventos de dominio (TaskCreated, TaskRetitled, TaskCompleted, ...)
+que solo crece. El estado de las tareas es una proyeccion: al iniciar se vuelve a armar aplicando
+todos los eventos en orden, nunca se guarda un snapshot.
+
+El TaskMap decide cada cambio con las mismas validaciones de siempre y el almacenamiento lo traduce
+en eventos comparando las tareas con su estado antes del cambio. Los eventos de un mismo cambio se
+escriben juntos en una linea JSON, asi un cambio queda completo o no queda. Si el proceso se corta a
+mitad de una escritura la ultima linea queda incompleta y al iniciar se descarta.
+
+La proyeccion son los mapas del mismo TaskMap: una vez escritos, los eventos se aplican sobre las
+tareas en su estado anterior, asi lo que leen las consultas es siempre el resultado de los eventos.
+*/
+
+// Tipos de eventos de dominio
+const (
+	taskEventCreated            = "TaskCreated"
+	taskEventRetitled           = "TaskRetitled"
+	taskEventDescriptionChanged = "TaskDescriptionChanged"
+	taskEventCompleted          = "TaskCompleted"
+	taskEventReopened           = "TaskReopened"
+	taskEventRescheduled        = "TaskRescheduled"
+	taskEventReprioritized      = "TaskReprioritized"
+	taskEventTagged             = "TaskTagged"
+	taskEventUntagged           = "TaskUntagged"
+	taskEventReparented         = "TaskReparented"
+	taskEventBlockersChanged    = "TaskBlockersChanged"
+	taskEventRecurrenceChanged  = "TaskRecurrenceChanged"
+	taskEventTouched            = "TaskTouched"
+	taskEventDeleted            = "TaskDeleted"
+	taskEventRestored           = "TaskRestored"
+	taskEventPurged             = "TaskPurged"
+)
+
+// Evento de dominio sobre una tarea
+// Segun el tipo se usan distintos campos, los que no corresponden al tipo quedan vacios
+type taskEvent struct {
+	// Numero de secuencia del evento, crece de a uno en todo el flujo
+	Seq uint64 `json:"seq"`
+
+	Type   string `json:"type"`
+	TaskID int    `json:"task_id"`
+
+	// Version y fecha de modificacion de la tarea despues del evento, no se usan en TaskCreated ni en TaskPurged
+	Version int       `json:"version,omitempty"`
+	At      time.Time `json:"at"`
+
+	// TaskCreated: la tarea completa
+	Task *internal.Task `json:"task,omitempty"`
+
+	// TaskRetitled y TaskDescriptionChanged
+	Tittle      string `json:"tittle,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// TaskRescheduled: las dos fechas, nil indica que la tarea no tiene esa fecha
+	StartAt *time.Time `json:"start_at,omitempty"`
+	DueAt   *time.Time `json:"due_at,omitempty"`
+
+	// TaskReprioritized
+	Priority internal.Priority `json:"priority,omitempty"`
+
+	// TaskTagged y TaskUntagged: las etiquetas agregadas o quitadas
+	Tags []string `json:"tags,omitempty"`
+
+	// TaskReparented: el nuevo padre, nil indica que la tarea deja de ser una subtarea
+	ParentID *int `json:"parent_id,omitempty"`
+
+	// TaskBlockersChanged: todas las dependencias de la tarea
+	BlockedBy []int `json:"blocked_by,omitempty"`
+
+	// TaskRecurrenceChanged: la nueva regla, nil indica que la tarea deja de repetirse
+	Recurrence *internal.Recurrence `json:"recurrence,omitempty"`
+
+	// TaskDeleted: la fecha en que la tarea se movio a la papelera
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// Linea del flujo de eventos: los eventos de un mismo cambio
+type taskEventCommit struct {
+	Events []taskEvent `json:"events"`
+}
+
+// Funcion para inicializar un repositorio de tareas que se persiste como un flujo de eventos
+//
+// El estado se arma aplicando todos los eventos del archivo, si no existe se crea vacio.
+func NewTaskMapEvents(file string) (t *TaskMap, err error) {
+	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return
+	}
+
+	// Armar la proyeccion a partir de los eventos, sobre un TaskMap vacio
+	storage := &taskEventStorage{file: f}
+	t = newTaskMap(make(map[int]internal.Task), 0)
+	if err = storage.replay(t); err != nil {
+		f.Close()
+		t = nil
+		return
+	}
+
+	t.storage = storage
+	return
+}
+
+// Almacenamiento del TaskMap como un flujo de eventos
+// Todos sus metodos se llaman con el mapa bloqueado para escritura, por eso no necesita su propio bloqueo
+type taskEventStorage struct {
+	file *os.File
+
+	// Tamaño del archivo hasta el ultimo cambio valido
+	size int64
+
+	// Secuencia del ultimo evento escrito
+	seq uint64
+}
+
+// Metodo para aplicar los eventos del archivo sobre el TaskMap y truncar un final incompleto
+func (s *taskEventStorage) replay(t *TaskMap) (err error) {
+	reader := bufio.NewReader(s.file)
+	for {
+		var line []byte
+		line, err = reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Una linea sin salto final es un cambio incompleto, se descarta
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+
+		var commit taskEventCommit
+		if errJSON := json.Unmarshal(bytes.TrimSpace(line), &commit); errJSON != nil {
+			err = fmt.Errorf("invalid event file %s: %w", s.file.Name(), errJSON)
+			return
+		}
+
+		for _, event := range commit.Events {
+			s.apply(t, event)
+		}
+		s.size += int64(len(line))
+	}
+
+	// Truncar el archivo en el ultimo cambio valido y seguir escribiendo desde ahi
+	if err = s.file.Truncate(s.size); err != nil {
+		return
+	}
+	_, err = s.file.Seek(s.size, io.SeekStart)
+	return
+}
+
+// Metodo para registrar un cambio como eventos
+func (s *taskEventStorage) changed(t *TaskMap, change taskChange) (err error) {
+	events := s.events(change)
+	if len(events) == 0 {
+		return
+	}
+
+	for i := range events {
+		events[i].Seq = s.seq + uint64(i) + 1
+	}
+
+	line, err := json.Marshal(taskEventCommit{Events: events})
+	if err != nil {
+		return
+	}
+	line = append(line, '\n')
+
+	// Escribir los eventos y asegurar que esten en disco
+	if _, err = s.file.Write(line); err == nil {
+		err = s.file.Sync()
+	}
+	if err != nil {
+		// Quitar lo que se haya escrito para no dejar un cambio a medias antes de los siguientes
+		s.file.Truncate(s.size)
+		s.file.Seek(s.size, io.SeekStart)
+		return
+	}
+	s.size += int64(len(line))
+
+	// Los eventos ya son parte del flujo: las tareas vuelven a su estado anterior y se les aplican los eventos
+	before := make(map[int]bool, len(change.Before))
+	for _, task := range change.Before {
+		before[task.ID] = true
+		placeTask(t, task)
+	}
+	for _, task := range append([]internal.Task{change.Task}, change.Updated...) {
+		if !before[task.ID] {
+			t.remove(task.ID)
+		}
+	}
+	for _, event := range events {
+		s.apply(t, event)
+	}
+	return
+}
+
+// Metodo para liberar el archivo, los eventos ya estan escritos
+func (s *taskEventStorage) close(t *TaskMap) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err = s.file.Close()
+	return
+}
+
+// Metodo para traducir un cambio del TaskMap en eventos, comparando cada tarea con su estado antes del cambio
+func (s *taskEventStorage) events(change taskChange) (events []taskEvent) {
+	if change.Op == taskOpPurge {
+		now := changeTime()
+		for _, id := range change.Purged {
+			events = append(events, taskEvent{Type: taskEventPurged, TaskID: id, At: now})
+		}
+		return
+	}
+
+	before := make(map[int]internal.Task, len(change.Before))
+	for _, task := range change.Before {
+		before[task.ID] = task
+	}
+
+	for _, task := range append([]internal.Task{change.Task}, change.Updated...) {
+		prev, ok := before[task.ID]
+		if !ok {
+			created := task
+			created.Blocked = false
+			events = append(events, taskEvent{Type: taskEventCreated, TaskID: task.ID, At: task.CreatedAt, Task: &created})
+			continue
+		}
+		events = append(events, diffTaskEvents(prev, task)...)
+	}
+	return
+}
+
+// Funcion para obtener los eventos que llevan una tarea de prev a next
+// Si la version cambio sin cambiar ningun campo se registra TaskTouched
+func diffTaskEvents(prev, next internal.Task) (events []taskEvent) {
+	add := func(event taskEvent) {
+		event.TaskID = next.ID
+		event.Version = next.Version
+		event.At = next.UpdatedAt
+		events = append(events, event)
+	}
+
+	if prev.DeletedAt == nil && next.DeletedAt != nil {
+		add(taskEvent{Type: taskEventDeleted, DeletedAt: next.DeletedAt})
+	}
+	if prev.DeletedAt != nil && next.DeletedAt == nil {
+		add(taskEvent{Type: taskEventRestored})
+	}
+	if prev.Tittle != next.Tittle {
+		add(taskEvent{Type: taskEventRetitled, Tittle: next.Tittle})
+	}
+	if prev.Description != next.Description {
+		add(taskEvent{Type: taskEventDescriptionChanged, Description: next.Description})
+	}
+	if !prev.Done && next.Done {
+		add(taskEvent{Type: taskEventCompleted})
+	}
+	if prev.Done && !next.Done {
+		add(taskEvent{Type: taskEventReopened})
+	}
+	if !sameTime(prev.StartAt, next.StartAt) || !sameTime(prev.DueAt, next.DueAt) {
+		add(taskEvent{Type: taskEventRescheduled, StartAt: next.StartAt, DueAt: next.DueAt})
+	}
+	if prev.Priority != next.Priority {
+		add(taskEvent{Type: taskEventReprioritized, Priority: next.Priority})
+	}
+	if added := missingTags(next.Tags, prev.Tags); len(added) > 0 {
+		add(taskEvent{Type: taskEventTagged, Tags: added})
+	}
+	if removed := missingTags(prev.Tags, next.Tags); len(removed) > 0 {
+		add(taskEvent{Type: taskEventUntagged, Tags: removed})
+	}
+	if !sameID(prev.ParentID, next.ParentID) {
+		add(taskEvent{Type: taskEventReparented, ParentID: next.ParentID})
+	}
+	if !slices.Equal(prev.BlockedBy, next.BlockedBy) {
+		add(taskEvent{Type: taskEventBlockersChanged, BlockedBy: next.BlockedBy})
+	}
+	if !reflect.DeepEqual(prev.Recurrence, next.Recurrence) {
+		add(taskEvent{Type: taskEventRecurrenceChanged, Recurrence: next.Recurrence})
+	}
+
+	if len(events) == 0 && (prev.Version != next.Version || !prev.UpdatedAt.Equal(next.UpdatedAt)) {
+		add(taskEvent{Type: taskEventTouched})
+	}
+	return
+}
+
+// Metodo para aplicar un evento sobre la proyeccion, que son los mapas del TaskMap
+func (s *taskEventStorage) apply(t *TaskMap, event taskEvent) {
+	s.seq = event.Seq
+
+	switch event.Type {
+	case taskEventCreated:
+		placeTask(t, *event.Task)
+		if event.TaskID > (*t).lastId {
+			(*t).lastId = event.TaskID
+		}
+		return
+	case taskEventPurged:
+		t.remove(event.TaskID)
+		delete((*t).trash, event.TaskID)
+		return
+	}
+
+	task, ok := (*t).db[event.TaskID]
+	if !ok {
+		task = (*t).trash[event.TaskID]
+	}
+
+	switch event.Type {
+	case taskEventRetitled:
+		task.Tittle = event.Tittle
+	case taskEventDescriptionChanged:
+		task.Description = event.Description
+	case taskEventCompleted:
+		task.Done = true
+	case taskEventReopened:
+		task.Done = false
+	case taskEventRescheduled:
+		task.StartAt = event.StartAt
+		task.DueAt = event.DueAt
+	case taskEventReprioritized:
+		task.Priority = event.Priority
+	case taskEventTagged:
+		task.Tags = append(append([]string(nil), task.Tags...), event.Tags...)
+		sort.Strings(task.Tags)
+	case taskEventUntagged:
+		task.Tags = missingTags(task.Tags, event.Tags)
+	case taskEventReparented:
+		task.ParentID = event.ParentID
+	case taskEventBlockersChanged:
+		task.BlockedBy = event.BlockedBy
+	case taskEventRecurrenceChanged:
+		task.Recurrence = event.Recurrence
+	case taskEventDeleted:
+		task.DeletedAt = event.DeletedAt
+	case taskEventRestored:
+		task.DeletedAt = nil
+	}
+
+	task.Version = event.Version
+	task.UpdatedAt = event.At
+	placeTask(t, task)
+}
+
+// Funcion para guardar una tarea en el TaskMap, en la papelera si tiene DeletedAt
+// Se llama con el mapa bloqueado para escritura
+func placeTask(t *TaskMap, task internal.Task) {
+	t.remove(task.ID)
+	delete((*t).trash, task.ID)
+
+	if task.DeletedAt != nil {
+		task.Blocked = false
+		(*t).trash[task.ID] = task
+		return
+	}
+	t.put(task)
+}
+
+// Funcion para obtener las etiquetas de tags que no estan en other, si no hay ninguna devuelve nil
+func missingTags(tags, other []string) (missing []string) {
+	for _, tag := range tags {
+		found := false
+		for _, value := range other {
+			if value == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, tag)
+		}
+	}
+	return
+}
+
+// Funcion para comparar dos fechas opcionales
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// Funcion para comparar dos IDs opcionales
+func sameID(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
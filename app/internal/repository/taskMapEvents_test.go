@@ -0,0 +1,156 @@
+package repository_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de la persistencia del TaskMap como un flujo de eventos
+func TestTaskMapEvents(t *testing.T) {
+
+	//Funcion para leer los tipos de eventos del archivo, en orden
+	readEventTypes := func(t *testing.T, file string) (types []string) {
+		f, err := os.Open(file)
+		require.NoError(t, err)
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var commit struct {
+				Events []struct {
+					Type string `json:"type"`
+				} `json:"events"`
+			}
+			require.NoError(t, json.Unmarshal(scanner.Bytes(), &commit))
+			for _, event := range commit.Events {
+				types = append(types, event.Type)
+			}
+		}
+		require.NoError(t, scanner.Err())
+		return
+	}
+
+	//Test cada cambio se registra como eventos de dominio
+	t.Run("Success - Changes are recorded as domain events", func(t *testing.T) {
+
+		//arrange
+		file := filepath.Join(t.TempDir(), "events.jsonl")
+		rp, err := repository.NewTaskMapEvents(file)
+		require.NoError(t, err)
+		defer rp.Close()
+
+		//act
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+		require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Tittle: internal.Set("task 1 renamed"), Description: internal.Set("new")}))
+		require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)}))
+		require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(false)}))
+		require.NoError(t, rp.AddTag(1, "home"))
+		require.NoError(t, rp.AddTag(1, "home"))
+		require.NoError(t, rp.Delete(1, 0))
+		require.NoError(t, rp.Restore(1))
+		require.NoError(t, rp.Delete(1, 0))
+		require.NoError(t, rp.Purge(1))
+
+		//assert
+		require.Equal(t, []string{
+			"TaskCreated",
+			"TaskRetitled", "TaskDescriptionChanged",
+			"TaskCompleted",
+			"TaskReopened",
+			"TaskTagged",
+			"TaskTouched",
+			"TaskDeleted",
+			"TaskRestored",
+			"TaskDeleted",
+			"TaskPurged",
+		}, readEventTypes(t, file))
+	})
+
+	//Test al reabrir el archivo la proyeccion de los eventos es igual al estado antes de cerrar
+	t.Run("Success - Replay rebuilds the same state", func(t *testing.T) {
+
+		//arrange
+		file := filepath.Join(t.TempDir(), "events.jsonl")
+		rp, err := repository.NewTaskMapEvents(file)
+		require.NoError(t, err)
+
+		startAt := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+		dueAt := time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC)
+		parent := 1
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "parent", Tags: []string{"home"}}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "child", ParentID: &parent, StartAt: &startAt, DueAt: &dueAt}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "blocked", BlockedBy: []int{2}, Priority: internal.PriorityUrgent}))
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "daily", DueAt: &dueAt, Recurrence: &internal.Recurrence{Frequency: internal.FrequencyDaily}}))
+		require.NoError(t, rp.UpdatePartial(4, 0, internal.TaskPatch{Done: internal.Set(true)}))
+		require.NoError(t, rp.Update(internal.Task{ID: 2, Tittle: "child", ParentID: &parent, Priority: internal.PriorityLow, Tags: []string{"work", "home"}}))
+		require.NoError(t, rp.RemoveTag(1, "home"))
+		require.NoError(t, rp.AddDependency(1, 3))
+		require.NoError(t, rp.Delete(2, 0))
+
+		page, err := rp.List(internal.TaskQuery{})
+		require.NoError(t, err)
+		trash, err := rp.ListTrash()
+		require.NoError(t, err)
+		require.NoError(t, rp.Close())
+
+		//act
+		reopened, err := repository.NewTaskMapEvents(file)
+		require.NoError(t, err)
+		defer reopened.Close()
+		pageReopened, errList := reopened.List(internal.TaskQuery{})
+		trashReopened, errTrash := reopened.ListTrash()
+		next := internal.Task{Tittle: "next"}
+		errSave := reopened.Save(&next)
+
+		//assert
+		require.NoError(t, errList)
+		require.Equal(t, 4, page.Total)
+		require.Equal(t, page, pageReopened)
+		require.NoError(t, errTrash)
+		require.Equal(t, trash, trashReopened)
+		require.NoError(t, errSave)
+		require.Equal(t, 6, next.ID, "ids must not be reused")
+	})
+
+	//Test un cambio incompleto al final se descarta completo
+	t.Run("Success - Torn tail is truncated", func(t *testing.T) {
+
+		//arrange
+		file := filepath.Join(t.TempDir(), "events.jsonl")
+		rp, err := repository.NewTaskMapEvents(file)
+		require.NoError(t, err)
+		require.NoError(t, rp.Save(&internal.Task{Tittle: "task 1"}))
+		require.NoError(t, rp.Close())
+
+		f, err := os.OpenFile(file, os.O_APPEND|os.O_WRONLY, 0o644)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"events":[{"seq":2,"type":"TaskRetitled","task_id":1,"version":2,"tittle":"tor`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		//act
+		reopened, err := repository.NewTaskMapEvents(file)
+		require.NoError(t, err)
+		require.NoError(t, reopened.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)}))
+		require.NoError(t, reopened.Close())
+		reopened, err = repository.NewTaskMapEvents(file)
+		require.NoError(t, err)
+		defer reopened.Close()
+
+		//assert
+		task, err := reopened.GetByID(1)
+		require.NoError(t, err)
+		require.Equal(t, "task 1", task.Tittle)
+		require.True(t, task.Done)
+		require.Equal(t, 2, task.Version)
+		require.Equal(t, []string{"TaskCreated", "TaskCompleted"}, readEventTypes(t, file))
+	})
+}
@@ -0,0 +1,292 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Taks/internal"
+)
+
+/*
+Este archivo contiene la persistencia opcional del TaskMap en un archivo JSON.
+
+El archivo se escribe de forma atomica: primero se escribe un archivo temporal en el mismo
+directorio y despues se renombra sobre el original. Si el proceso se corta a mitad de la
+escritura el archivo original queda intacto.
+*/
+
+// Operaciones que modifican el TaskMap
+const (
+	taskOpSave          = "save"
+	taskOpUpdate        = "update"
+	taskOpUpdatePartial = "update_partial"
+	taskOpDelete        = "delete"
+	taskOpRestore       = "restore"
+	taskOpPurge         = "purge"
+)
+
+// Cambio aplicado sobre el TaskMap, es lo que recibe el almacenamiento para persistirlo
+type taskChange struct {
+	// Operacion que produjo el cambio
+	Op string `json:"op"`
+
+	// Estado de la tarea despues del cambio, en un delete es la tarea ya movida a la papelera
+	Task internal.Task `json:"task"`
+
+	// Otras tareas modificadas por el mismo cambio, por ejemplo las subtareas de una tarea eliminada
+	Updated []internal.Task `json:"updated,omitempty"`
+
+	// IDs de las tareas eliminadas definitivamente de la papelera, solo en un purge
+	Purged []int `json:"purged,omitempty"`
+
+	// Ultimo ID asignado despues del cambio
+	LastID int `json:"last_id"`
+
+	// Estado antes del cambio de las tareas que ya existian, no se persiste
+	// Las tareas de Task y Updated que no estan son tareas creadas por el cambio
+	Before []internal.Task `json:"-"`
+}
+
+// Almacenamiento donde el TaskMap persiste sus cambios
+type taskMapStorage interface {
+	// Se llama con el mapa bloqueado para escritura y el cambio ya aplicado en memoria
+	changed(t *TaskMap, change taskChange) (err error)
+
+	// Escribe los cambios pendientes y libera los recursos
+	close(t *TaskMap) (err error)
+}
+
+// Contenido del archivo JSON
+type taskSnapshot struct {
+	LastID int             `json:"last_id"`
+	Tasks  []internal.Task `json:"tasks"`
+
+	// Secuencia del ultimo registro del WAL incluido en el snapshot, solo se usa con WAL
+	Seq uint64 `json:"seq,omitempty"`
+}
+
+// Funcion para inicializar un repositorio de tareas que se persiste en un archivo JSON
+//
+// Si el archivo existe se cargan las tareas y el ultimo ID, asi los IDs nunca se repiten despues de reiniciar.
+// Si interval es 0 el archivo se escribe despues de cada cambio; si no, se escribe como mucho una vez
+// por intervalo y los ultimos cambios se escriben al llamar a Close.
+func NewTaskMapFile(file string, interval time.Duration) (t *TaskMap, err error) {
+	snap, err := readSnapshot(file)
+	if err != nil {
+		return
+	}
+
+	t = newTaskMapFromSnapshot(snap)
+
+	storage := &taskFileStorage{
+		file:     file,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if interval > 0 {
+		go storage.run(t)
+	} else {
+		close(storage.done)
+	}
+	t.storage = storage
+	return
+}
+
+// Funcion para crear el TaskMap a partir del estado guardado
+// Las tareas de la papelera estan en la misma lista, newTaskMap las separa por DeletedAt
+func newTaskMapFromSnapshot(snap taskSnapshot) *TaskMap {
+	db := make(map[int]internal.Task, len(snap.Tasks))
+	lastId := snap.LastID
+	for _, task := range snap.Tasks {
+		db[task.ID] = task
+
+		// El ultimo ID nunca puede ser menor que el de una tarea guardada
+		if task.ID > lastId {
+			lastId = task.ID
+		}
+	}
+
+	return newTaskMap(db, lastId)
+}
+
+// Almacenamiento del TaskMap en un archivo JSON
+type taskFileStorage struct {
+	// Serializa las escrituras en segundo plano
+	// Las escrituras inmediatas ya estan serializadas por el bloqueo de escritura del mapa
+	mu sync.Mutex
+
+	file     string
+	interval time.Duration
+
+	// Indica si hay cambios sin escribir, solo se usa con intervalo
+	dirty atomic.Bool
+
+	// Ultimo error al escribir en segundo plano
+	err error
+
+	// Canales para detener la escritura en segundo plano
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Metodo para registrar un cambio
+func (s *taskFileStorage) changed(t *TaskMap, change taskChange) (err error) {
+	// Con intervalo solo se marca que hay cambios pendientes
+	if s.interval > 0 {
+		s.dirty.Store(true)
+		return
+	}
+
+	err = writeSnapshot(s.file, t.snapshot())
+	return
+}
+
+// Metodo para escribir los cambios pendientes y detener la escritura en segundo plano
+func (s *taskFileStorage) close(t *TaskMap) (err error) {
+	if s.interval > 0 {
+		select {
+		case <-s.stop:
+		default:
+			close(s.stop)
+		}
+	}
+	<-s.done
+
+	err = s.flush(t)
+	return
+}
+
+// Metodo que escribe el archivo en cada intervalo si hay cambios pendientes
+func (s *taskFileStorage) run(t *TaskMap) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+			// Si falla se guarda el error y se vuelve a intentar en el siguiente intervalo
+			s.flush(t)
+		}
+	}
+}
+
+// Metodo para escribir el archivo si hay cambios pendientes
+func (s *taskFileStorage) flush(t *TaskMap) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty.Swap(false) {
+		err = s.err
+		return
+	}
+
+	// Copiar el estado con el mapa bloqueado para lectura y escribir el archivo sin bloquearlo
+	t.mu.RLock()
+	snap := t.snapshot()
+	t.mu.RUnlock()
+
+	// Si falla los cambios siguen pendientes
+	s.err = writeSnapshot(s.file, snap)
+	if s.err != nil {
+		s.dirty.Store(true)
+	}
+	err = s.err
+	return
+}
+
+// Funcion para leer el archivo JSON, si no existe se devuelve un estado vacio
+func readSnapshot(file string) (snap taskSnapshot, err error) {
+	// Eliminar los temporales que hayan quedado de una escritura interrumpida
+	removeTempFiles(file)
+
+	bytes, err := os.ReadFile(file)
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+		return
+	}
+	if err != nil {
+		return
+	}
+
+	if err = json.Unmarshal(bytes, &snap); err != nil {
+		err = fmt.Errorf("invalid task file %s: %w", file, err)
+		return
+	}
+	return
+}
+
+// Funcion para escribir el archivo JSON de forma atomica
+func writeSnapshot(file string, snap taskSnapshot) (err error) {
+	bytes, err := json.Marshal(snap)
+	if err != nil {
+		return
+	}
+
+	// Paso 1: Escribir un archivo temporal en el mismo directorio, para que el rename sea atomico
+	dir := filepath.Dir(file)
+	tmp, err := os.CreateTemp(dir, filepath.Base(file)+".tmp-*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		// Si algo falla se elimina el temporal
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(bytes); err != nil {
+		tmp.Close()
+		return
+	}
+
+	// Paso 2: Asegurar que el contenido este en disco antes de renombrar
+	if err = tmp.Sync(); err != nil {
+		tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+
+	// Paso 3: Reemplazar el archivo original
+	if err = os.Rename(tmp.Name(), file); err != nil {
+		return
+	}
+
+	// Paso 4: Asegurar que el rename este en disco
+	err = syncDir(dir)
+	return
+}
+
+// Funcion para sincronizar un directorio con el disco
+func syncDir(dir string) (err error) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+
+	err = d.Sync()
+	return
+}
+
+// Funcion para eliminar los archivos temporales de escrituras interrumpidas
+func removeTempFiles(file string) {
+	matches, _ := filepath.Glob(file + ".tmp-*")
+	for _, match := range matches {
+		os.Remove(match)
+	}
+}
@@ -0,0 +1,119 @@
+package repository_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de la persistencia del TaskMap en un archivo JSON
+func TestTaskMapFile(t *testing.T) {
+
+	//Test las tareas y el ultimo ID se recuperan al reiniciar
+	t.Run("Success - Reload tasks and last id", func(t *testing.T) {
+
+		//arrange
+		file := filepath.Join(t.TempDir(), "tasks.json")
+		rp, err := repository.NewTaskMapFile(file, 0)
+		require.NoError(t, err)
+
+		first := internal.Task{Tittle: "task 1"}
+		second := internal.Task{Tittle: "task 2"}
+		require.NoError(t, rp.Save(&first))
+		require.NoError(t, rp.Save(&second))
+		require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)}))
+		require.NoError(t, rp.Delete(2, 0))
+		require.NoError(t, rp.Close())
+
+		//act
+		rp, err = repository.NewTaskMapFile(file, 0)
+		require.NoError(t, err)
+		third := internal.Task{Tittle: "task 3"}
+		require.NoError(t, rp.Save(&third))
+
+		//assert
+		task, err := rp.GetByID(1)
+		require.NoError(t, err)
+		require.NotZero(t, task.CreatedAt)
+		require.False(t, task.UpdatedAt.Before(task.CreatedAt))
+		require.Equal(t, internal.Task{ID: 1, Tittle: "task 1", Done: true, Version: 2, CreatedAt: task.CreatedAt, UpdatedAt: task.UpdatedAt}, task)
+		_, err = rp.GetByID(2)
+		require.ErrorIs(t, err, internal.ErrTaskNotFound)
+		require.Equal(t, 3, third.ID, "ids must not be reused")
+	})
+
+	//Test un temporal de una escritura interrumpida no afecta al archivo
+	t.Run("Success - Interrupted write keeps the previous file", func(t *testing.T) {
+
+		//arrange
+		file := filepath.Join(t.TempDir(), "tasks.json")
+		rp, err := repository.NewTaskMapFile(file, 0)
+		require.NoError(t, err)
+		task := internal.Task{Tittle: "task 1"}
+		require.NoError(t, rp.Save(&task))
+
+		//Simular un corte a mitad de escritura dejando un temporal incompleto
+		require.NoError(t, os.WriteFile(file+".tmp-123", []byte(`{"last_id": 9, "tas`), 0o644))
+
+		//act
+		rp, err = repository.NewTaskMapFile(file, 0)
+
+		//assert
+		require.NoError(t, err)
+		_, err = rp.GetByID(1)
+		require.NoError(t, err)
+		_, err = os.Stat(file + ".tmp-123")
+		require.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	//Test con intervalo los cambios se escriben al cerrar
+	t.Run("Success - Debounced writes are flushed on Close", func(t *testing.T) {
+
+		//arrange
+		file := filepath.Join(t.TempDir(), "tasks.json")
+		rp, err := repository.NewTaskMapFile(file, time.Hour)
+		require.NoError(t, err)
+		task := internal.Task{Tittle: "task 1"}
+		require.NoError(t, rp.Save(&task))
+
+		//Antes de cerrar el archivo todavia no existe
+		_, err = os.Stat(file)
+		require.ErrorIs(t, err, os.ErrNotExist)
+
+		//act
+		require.NoError(t, rp.Close())
+		rp, err = repository.NewTaskMapFile(file, 0)
+
+		//assert
+		require.NoError(t, err)
+		_, err = rp.GetByID(1)
+		require.NoError(t, err)
+	})
+
+	//Test si no se puede escribir el archivo el cambio se deshace
+	t.Run("Error - Failed write rolls back the change", func(t *testing.T) {
+
+		//arrange
+		dir := t.TempDir()
+		file := filepath.Join(dir, "tasks.json")
+		rp, err := repository.NewTaskMapFile(file, 0)
+		require.NoError(t, err)
+		require.NoError(t, os.RemoveAll(dir))
+
+		//act
+		task := internal.Task{Tittle: "task 1"}
+		err = rp.Save(&task)
+
+		//assert
+		require.ErrorIs(t, err, internal.ErrTaskInternal)
+		require.Equal(t, 0, task.ID)
+		page, err := rp.List(internal.TaskQuery{})
+		require.NoError(t, err)
+		require.Equal(t, 0, page.Total)
+	})
+}
@@ -0,0 +1,269 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+)
+
+/*
+Este archivo contiene la persistencia del TaskMap con un log de escritura anticipada (WAL).
+
+Cada cambio se agrega al final del archivo .wal como un registro de una linea:
+
+	<crc32 en hexadecimal> <cambio en JSON>\n
+
+Al iniciar se carga el ultimo snapshot JSON y se aplican los registros posteriores. Cada cierto
+numero de registros el estado completo se escribe en el snapshot y el WAL se vacia.
+
+Si el proceso se corta a mitad de una escritura, el ultimo registro queda incompleto o con un
+checksum que no coincide: se detecta al iniciar y el archivo se trunca en el ultimo registro valido.
+*/
+
+// Cantidad de registros por defecto antes de compactar el WAL en un snapshot
+const DefaultWALCompactEvery = 1000
+
+// Tabla para calcular los checksums de los registros
+var walTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Registro del WAL
+type walRecord struct {
+	// Numero de secuencia del registro, crece de a uno
+	Seq uint64 `json:"seq"`
+
+	// Cambio aplicado sobre el TaskMap
+	Change taskChange `json:"change"`
+}
+
+// Funcion para inicializar un repositorio de tareas que se persiste con un WAL
+//
+// file es el archivo del snapshot JSON, el WAL se guarda al lado con la extension .wal.
+// compactEvery es la cantidad de registros antes de compactar, si es 0 se usa DefaultWALCompactEvery.
+func NewTaskMapWAL(file string, compactEvery int) (t *TaskMap, err error) {
+	if compactEvery <= 0 {
+		compactEvery = DefaultWALCompactEvery
+	}
+
+	// Paso 1: Cargar el ultimo snapshot
+	snap, err := readSnapshot(file)
+	if err != nil {
+		return
+	}
+	t = newTaskMapFromSnapshot(snap)
+
+	// Paso 2: Abrir el WAL
+	wal, err := os.OpenFile(file+".wal", os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return
+	}
+
+	storage := &taskWALStorage{
+		file:         file,
+		wal:          wal,
+		seq:          snap.Seq,
+		compactEvery: compactEvery,
+	}
+
+	// Paso 3: Aplicar los registros posteriores al snapshot
+	if err = storage.replay(t); err != nil {
+		wal.Close()
+		t = nil
+		return
+	}
+
+	t.storage = storage
+	return
+}
+
+// Almacenamiento del TaskMap con un WAL
+// Todos sus metodos se llaman con el mapa bloqueado para escritura, por eso no necesita su propio bloqueo
+type taskWALStorage struct {
+	// Archivo del snapshot y archivo del WAL
+	file string
+	wal  *os.File
+
+	// Tamaño del WAL hasta el ultimo registro valido
+	size int64
+
+	// Secuencia del ultimo registro escrito
+	seq uint64
+
+	// Registros escritos desde la ultima compactacion
+	records      int
+	compactEvery int
+}
+
+// Metodo para aplicar los registros del WAL y truncar un final incompleto
+func (s *taskWALStorage) replay(t *TaskMap) (err error) {
+	reader := bufio.NewReader(s.wal)
+	for {
+		var line []byte
+		line, err = reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// Una linea sin salto final es un registro incompleto, se descarta
+			err = nil
+			break
+		}
+		if err != nil {
+			return
+		}
+
+		record, ok := decodeWALRecord(line)
+		if !ok {
+			// Registro corrupto, se descarta desde aca hasta el final
+			break
+		}
+
+		// Los registros que ya estan en el snapshot se saltean
+		if record.Seq > s.seq {
+			applyTaskChange(t, record.Change)
+			s.seq = record.Seq
+			s.records++
+		}
+		s.size += int64(len(line))
+	}
+
+	// Truncar el WAL en el ultimo registro valido y seguir escribiendo desde ahi
+	if err = s.wal.Truncate(s.size); err != nil {
+		return
+	}
+	_, err = s.wal.Seek(s.size, io.SeekStart)
+	return
+}
+
+// Metodo para registrar un cambio
+func (s *taskWALStorage) changed(t *TaskMap, change taskChange) (err error) {
+	line, err := encodeWALRecord(walRecord{Seq: s.seq + 1, Change: change})
+	if err != nil {
+		return
+	}
+
+	// Escribir el registro y asegurar que este en disco
+	if _, err = s.wal.Write(line); err == nil {
+		err = s.wal.Sync()
+	}
+	if err != nil {
+		// Quitar lo que se haya escrito para no dejar un registro a medias antes de los siguientes
+		s.wal.Truncate(s.size)
+		s.wal.Seek(s.size, io.SeekStart)
+		return
+	}
+
+	s.seq++
+	s.size += int64(len(line))
+	s.records++
+
+	// Compactar cada cierta cantidad de registros, un error aca no invalida el cambio que ya esta en el WAL
+	if s.records >= s.compactEvery {
+		s.compact(t)
+	}
+	return
+}
+
+// Metodo para compactar el WAL y liberar el archivo
+func (s *taskWALStorage) close(t *TaskMap) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err = s.compact(t)
+	if errClose := s.wal.Close(); err == nil {
+		err = errClose
+	}
+	return
+}
+
+// Metodo para escribir el estado completo en el snapshot y vaciar el WAL
+func (s *taskWALStorage) compact(t *TaskMap) (err error) {
+	if s.records == 0 {
+		return
+	}
+
+	// Si el proceso se corta despues de escribir el snapshot y antes de vaciar el WAL,
+	// al iniciar los registros repetidos se saltean por su secuencia
+	snap := t.snapshot()
+	snap.Seq = s.seq
+	if err = writeSnapshot(s.file, snap); err != nil {
+		return
+	}
+
+	if err = s.wal.Truncate(0); err != nil {
+		return
+	}
+	if _, err = s.wal.Seek(0, io.SeekStart); err != nil {
+		return
+	}
+	s.size = 0
+	s.records = 0
+	return
+}
+
+// Funcion para aplicar un cambio del WAL sobre el mapa
+func applyTaskChange(t *TaskMap, change taskChange) {
+	switch change.Op {
+	case taskOpDelete:
+		// Los registros anteriores a la papelera solo tienen el ID, en ese caso se elimina definitivamente
+		t.remove(change.Task.ID)
+		if change.Task.DeletedAt != nil {
+			t.trash[change.Task.ID] = change.Task
+		}
+	case taskOpRestore:
+		delete(t.trash, change.Task.ID)
+		t.put(change.Task)
+	case taskOpPurge:
+		for _, id := range change.Purged {
+			delete(t.trash, id)
+		}
+	default:
+		t.put(change.Task)
+	}
+
+	for _, task := range change.Updated {
+		t.put(task)
+	}
+
+	if change.LastID > t.lastId {
+		t.lastId = change.LastID
+	}
+}
+
+// Funcion para codificar un registro como una linea del WAL
+func encodeWALRecord(record walRecord) (line []byte, err error) {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return
+	}
+
+	line = make([]byte, 0, len(payload)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.Checksum(payload, walTable))
+	line = append(line, payload...)
+	line = append(line, '\n')
+	return
+}
+
+// Funcion para decodificar una linea del WAL, devuelve false si esta corrupta
+func decodeWALRecord(line []byte) (record walRecord, ok bool) {
+	line = bytes.TrimSuffix(line, []byte("\n"))
+
+	checksum, payload, found := bytes.Cut(line, []byte(" "))
+	if !found || len(checksum) != 8 {
+		return
+	}
+
+	expected, err := strconv.ParseUint(string(checksum), 16, 32)
+	if err != nil || crc32.Checksum(payload, walTable) != uint32(expected) {
+		return
+	}
+
+	if err := json.Unmarshal(payload, &record); err != nil {
+		return
+	}
+
+	ok = true
+	return
+}
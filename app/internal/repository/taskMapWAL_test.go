@@ -0,0 +1,123 @@
+package repository_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de la persistencia del TaskMap con un WAL
+func TestTaskMapWAL(t *testing.T) {
+
+	//Test los cambios se recuperan del WAL aunque no se haya cerrado el repositorio
+	t.Run("Success - Replay changes after a crash", func(t *testing.T) {
+
+		//arrange
+		file := filepath.Join(t.TempDir(), "tasks.json")
+		rp, err := repository.NewTaskMapWAL(file, 0)
+		require.NoError(t, err)
+
+		first := internal.Task{Tittle: "task 1"}
+		second := internal.Task{Tittle: "task 2"}
+		require.NoError(t, rp.Save(&first))
+		require.NoError(t, rp.Save(&second))
+		require.NoError(t, rp.Update(internal.Task{ID: 1, Tittle: "task 1", Description: "updated"}))
+		require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)}))
+		require.NoError(t, rp.Delete(2, 0))
+
+		//act
+		rp, err = repository.NewTaskMapWAL(file, 0)
+		require.NoError(t, err)
+		third := internal.Task{Tittle: "task 3"}
+		require.NoError(t, rp.Save(&third))
+
+		//assert
+		task, err := rp.GetByID(1)
+		require.NoError(t, err)
+		require.NotZero(t, task.CreatedAt)
+		require.False(t, task.UpdatedAt.Before(task.CreatedAt))
+		require.Equal(t, internal.Task{ID: 1, Tittle: "task 1", Description: "updated", Done: true, Version: 3, CreatedAt: task.CreatedAt, UpdatedAt: task.UpdatedAt}, task)
+		_, err = rp.GetByID(2)
+		require.ErrorIs(t, err, internal.ErrTaskNotFound)
+		require.Equal(t, 3, third.ID, "ids must not be reused")
+	})
+
+	//Test un registro incompleto o corrupto al final se descarta
+	t.Run("Success - Torn tail is truncated", func(t *testing.T) {
+		for name, tail := range map[string]string{
+			"incomplete record": `1a2b3c4d {"seq":3,"change":{"op":"sa`,
+			"bad checksum":      "00000000 {\"seq\":3,\"change\":{\"op\":\"delete\",\"task\":{\"ID\":1}}}\n",
+		} {
+			t.Run(name, func(t *testing.T) {
+
+				//arrange
+				file := filepath.Join(t.TempDir(), "tasks.json")
+				rp, err := repository.NewTaskMapWAL(file, 0)
+				require.NoError(t, err)
+				first := internal.Task{Tittle: "task 1"}
+				second := internal.Task{Tittle: "task 2"}
+				require.NoError(t, rp.Save(&first))
+				require.NoError(t, rp.Save(&second))
+
+				wal, err := os.OpenFile(file+".wal", os.O_APPEND|os.O_WRONLY, 0)
+				require.NoError(t, err)
+				_, err = wal.WriteString(tail)
+				require.NoError(t, err)
+				require.NoError(t, wal.Close())
+
+				//act
+				rp, err = repository.NewTaskMapWAL(file, 0)
+				require.NoError(t, err)
+				third := internal.Task{Tittle: "task 3"}
+				require.NoError(t, rp.Save(&third))
+				rp, err = repository.NewTaskMapWAL(file, 0)
+				require.NoError(t, err)
+
+				//assert
+				page, err := rp.List(internal.TaskQuery{})
+				require.NoError(t, err)
+				require.Equal(t, 3, page.Total)
+				require.Equal(t, 3, third.ID)
+			})
+		}
+	})
+
+	//Test el WAL se compacta en el snapshot
+	t.Run("Success - Compaction", func(t *testing.T) {
+
+		//arrange
+		file := filepath.Join(t.TempDir(), "tasks.json")
+		rp, err := repository.NewTaskMapWAL(file, 2)
+		require.NoError(t, err)
+
+		//act
+		first := internal.Task{Tittle: "task 1"}
+		second := internal.Task{Tittle: "task 2"}
+		third := internal.Task{Tittle: "task 3"}
+		require.NoError(t, rp.Save(&first))
+		require.NoError(t, rp.Save(&second))
+		require.NoError(t, rp.Save(&third))
+
+		//assert
+		_, err = os.Stat(file)
+		require.NoError(t, err, "snapshot must exist after compaction")
+		info, err := os.Stat(file + ".wal")
+		require.NoError(t, err)
+		require.NotZero(t, info.Size(), "record after compaction must be in the wal")
+
+		require.NoError(t, rp.Close())
+		info, err = os.Stat(file + ".wal")
+		require.NoError(t, err)
+		require.Zero(t, info.Size())
+
+		rp, err = repository.NewTaskMapWAL(file, 2)
+		require.NoError(t, err)
+		page, err := rp.List(internal.TaskQuery{})
+		require.NoError(t, err)
+		require.Equal(t, 3, page.Total)
+	})
+}
@@ -0,0 +1,239 @@
+package repository_test
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de concurrencia del TaskMap, pensado para correr con go test -race
+func TestTaskMapConcurrency(t *testing.T) {
+
+	//Test crear tareas en paralelo sin repetir IDs
+	t.Run("Success - Concurrent Save assigns unique IDs", func(t *testing.T) {
+
+		//arrange
+		rp := repository.NewTaskMap(nil, 0)
+		const workers = 50
+		const tasksPerWorker = 20
+
+		//act
+		var wg sync.WaitGroup
+		ids := make(chan int, workers*tasksPerWorker)
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < tasksPerWorker; i++ {
+					task := internal.Task{Tittle: fmt.Sprintf("task %d-%d", w, i)}
+					if err := rp.Save(&task); err != nil {
+						t.Errorf("unexpected error: %v", err)
+						return
+					}
+					ids <- task.ID
+				}
+			}(w)
+		}
+		wg.Wait()
+		close(ids)
+
+		//assert
+		seen := make(map[int]bool)
+		for id := range ids {
+			require.False(t, seen[id], "duplicated id %d", id)
+			seen[id] = true
+		}
+		require.Len(t, seen, workers*tasksPerWorker)
+
+		page, err := rp.List(internal.TaskQuery{})
+		require.NoError(t, err)
+		require.Equal(t, workers*tasksPerWorker, page.Total)
+	})
+
+	//Test crear la misma tarea en paralelo, solo una debe guardarse
+	t.Run("Success - Concurrent Save with the same title", func(t *testing.T) {
+
+		//arrange
+		rp := repository.NewTaskMap(nil, 0)
+		const workers = 50
+
+		//act
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		saved, duplicated := 0, 0
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				task := internal.Task{Tittle: "same task"}
+				err := rp.Save(&task)
+
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					saved++
+				case errors.Is(err, internal.ErrTaskDuplicated):
+					duplicated++
+				default:
+					t.Errorf("unexpected error: %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		//assert
+		require.Equal(t, 1, saved)
+		require.Equal(t, workers-1, duplicated)
+	})
+
+	//Test usar todos los metodos del repositorio en paralelo
+	t.Run("Success - Mixed operations in parallel", func(t *testing.T) {
+
+		//arrange
+		const initial = 20
+		db := make(map[int]internal.Task)
+		for i := 1; i <= initial; i++ {
+			db[i] = internal.Task{ID: i, Tittle: fmt.Sprintf("task %d", i)}
+		}
+		rp := repository.NewTaskMap(db, initial)
+		const workers = 20
+		const iterations = 100
+
+		//act
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				for i := 0; i < iterations; i++ {
+					id := (w+i)%initial + 1
+
+					//Los errores esperados son que la tarea ya no exista o que el titulo este repetido
+					var err error
+					switch i % 6 {
+					case 0:
+						task := internal.Task{Tittle: fmt.Sprintf("new task %d-%d", w, i)}
+						err = rp.Save(&task)
+					case 1:
+						err = rp.Update(internal.Task{ID: id, Tittle: fmt.Sprintf("updated %d-%d", w, i)})
+					case 2:
+						err = rp.UpdatePartial(id, 0, internal.TaskPatch{Done: internal.Set(i%2 == 0), Description: internal.Set("patched")})
+					case 3:
+						_, err = rp.GetByID(id)
+					case 4:
+						_, err = rp.List(internal.TaskQuery{Search: "task", SortBy: internal.TaskSortTittle})
+					case 5:
+						if w%5 == 0 {
+							err = rp.Delete(id, 0)
+						}
+					}
+					if err != nil && !errors.Is(err, internal.ErrTaskNotFound) && !errors.Is(err, internal.ErrTaskDuplicated) {
+						t.Errorf("unexpected error: %v", err)
+						return
+					}
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		//assert
+		page, err := rp.List(internal.TaskQuery{})
+		require.NoError(t, err)
+
+		//Los IDs y los titulos deben seguir siendo unicos
+		titles := make(map[string]bool)
+		for _, task := range page.Tasks {
+			require.False(t, titles[task.Tittle], "duplicated title %q", task.Tittle)
+			titles[task.Tittle] = true
+
+			stored, err := rp.GetByID(task.ID)
+			require.NoError(t, err)
+			require.Equal(t, task, stored)
+		}
+	})
+}
+
+// Test de las fechas de inicio y limite del TaskMap
+func TestTaskMapDates(t *testing.T) {
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	due := time.Date(2026, 10, 10, 18, 0, 0, 0, time.UTC)
+
+	//Test la fecha de inicio no puede ser posterior a la fecha limite
+	t.Run("Error - Start after due", func(t *testing.T) {
+
+		//arrange
+		rp := repository.NewTaskMap(nil, 0)
+
+		//act
+		task := internal.Task{Tittle: "task 1", StartAt: &due, DueAt: &start}
+		err := rp.Save(&task)
+
+		//assert
+		require.ErrorIs(t, err, internal.ErrTaskInvalidField)
+	})
+
+	//Test setear y borrar las fechas con una actualizacion parcial
+	t.Run("Success - Set and clear dates with UpdatePartial", func(t *testing.T) {
+
+		//arrange
+		rp := repository.NewTaskMap(map[int]internal.Task{1: {ID: 1, Tittle: "task 1"}}, 1)
+
+		//act & assert
+		require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{StartAt: internal.Set(&start), DueAt: internal.Set(&due)}))
+		task, err := rp.GetByID(1)
+		require.NoError(t, err)
+		require.True(t, start.Equal(*task.StartAt))
+		require.True(t, due.Equal(*task.DueAt))
+
+		early := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+		require.ErrorIs(t, rp.UpdatePartial(1, 0, internal.TaskPatch{DueAt: internal.Set(&early)}), internal.ErrTaskInvalidField)
+
+		require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{StartAt: internal.Set[*time.Time](nil), DueAt: internal.Set[*time.Time](nil)}))
+		task, err = rp.GetByID(1)
+		require.NoError(t, err)
+		require.Nil(t, task.StartAt)
+		require.Nil(t, task.DueAt)
+	})
+
+	//Test listar las tareas vencidas y las que vencen en una ventana
+	t.Run("Success - Overdue and due window", func(t *testing.T) {
+
+		//arrange
+		before := due.Add(-48 * time.Hour)
+		after := due.Add(48 * time.Hour)
+		rp := repository.NewTaskMap(map[int]internal.Task{
+			1: {ID: 1, Tittle: "overdue", DueAt: &before},
+			2: {ID: 2, Tittle: "done", DueAt: &before, Done: true},
+			3: {ID: 3, Tittle: "later", DueAt: &after},
+			4: {ID: 4, Tittle: "no due date"},
+		}, 4)
+
+		//act
+		overdue, err := rp.List(internal.TaskQuery{OverdueAt: &due})
+		require.NoError(t, err)
+		window, err := rp.List(internal.TaskQuery{DueFrom: &due, DueTo: &after})
+		require.NoError(t, err)
+		sorted, err := rp.List(internal.TaskQuery{SortBy: internal.TaskSortDueAt, SortDesc: true})
+		require.NoError(t, err)
+
+		//assert
+		require.Equal(t, 1, overdue.Total)
+		require.Equal(t, 1, overdue.Tasks[0].ID)
+		require.Equal(t, 1, window.Total)
+		require.Equal(t, 3, window.Tasks[0].ID)
+
+		//Las tareas sin fecha limite van al final
+		ids := []int{}
+		for _, task := range sorted.Tasks {
+			ids = append(ids, task.ID)
+		}
+		require.Equal(t, []int{3, 2, 1, 4}, ids)
+	})
+}
@@ -0,0 +1,995 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Taks/internal"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// Esta es una implementacion de la interfaz TaskRepository basada en una base de datos SQLite
+// Usa un driver escrito en Go, por lo que no necesita cgo ni un servidor de base de datos
+type TaskSQL struct {
+	db *sql.DB
+}
+
+// Migraciones del esquema, se aplican en orden y cada una una sola vez
+// La version aplicada se guarda en PRAGMA user_version
+// Para cambiar el esquema se agrega una migracion al final, nunca se modifica una existente
+var taskSQLMigrations = []string{
+	`CREATE TABLE tasks (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		tittle      TEXT    NOT NULL,
+		description TEXT    NOT NULL,
+		done        BOOLEAN NOT NULL DEFAULT FALSE
+	)`,
+	// El titulo es unico, esta restriccion respalda a ErrTaskDuplicated
+	`CREATE UNIQUE INDEX tasks_tittle_unique ON tasks (tittle)`,
+	// Las fechas se guardan como texto RFC 3339 para conservar la zona horaria
+	`ALTER TABLE tasks ADD COLUMN start_at TEXT`,
+	`ALTER TABLE tasks ADD COLUMN due_at TEXT`,
+	`ALTER TABLE tasks ADD COLUMN priority INTEGER NOT NULL DEFAULT 0`,
+	// Relacion entre tareas y etiquetas, el indice por etiqueta sirve para contarlas
+	`CREATE TABLE task_tags (
+		task_id INTEGER NOT NULL REFERENCES tasks (id),
+		tag     TEXT    NOT NULL,
+		PRIMARY KEY (task_id, tag)
+	)`,
+	`CREATE INDEX task_tags_tag ON task_tags (tag)`,
+	// Tarea padre de una subtarea, el indice sirve para buscar las subtareas
+	`ALTER TABLE tasks ADD COLUMN parent_id INTEGER REFERENCES tasks (id)`,
+	`CREATE INDEX tasks_parent_id ON tasks (parent_id)`,
+	// Dependencias entre tareas, el indice sirve para quitarlas al eliminar la tarea que bloquea
+	`CREATE TABLE task_dependencies (
+		task_id    INTEGER NOT NULL REFERENCES tasks (id),
+		blocked_by INTEGER NOT NULL REFERENCES tasks (id),
+		PRIMARY KEY (task_id, blocked_by)
+	)`,
+	`CREATE INDEX task_dependencies_blocked_by ON task_dependencies (blocked_by)`,
+	// Regla de repeticion en formato RRULE
+	`ALTER TABLE tasks ADD COLUMN recurrence TEXT`,
+	// Version para el control de concurrencia optimista, las tareas existentes empiezan en 1
+	`ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1`,
+	// Fechas de creacion y de la ultima modificacion, las tareas existentes no las tienen
+	`ALTER TABLE tasks ADD COLUMN created_at TEXT`,
+	`ALTER TABLE tasks ADD COLUMN updated_at TEXT`,
+	// Papelera: las tareas eliminadas tienen fecha de eliminacion y su titulo puede volver a usarse
+	`ALTER TABLE tasks ADD COLUMN deleted_at TEXT`,
+	`DROP INDEX tasks_tittle_unique`,
+	`CREATE UNIQUE INDEX tasks_tittle_unique ON tasks (tittle) WHERE deleted_at IS NULL`,
+}
+
+// Columnas de la tabla tasks en el orden en que las lee scanTask
+// Las etiquetas y las dependencias se leen todas juntas separadas por comas, una etiqueta no puede tener comas
+// blocked se calcula con el estado de las tareas de las que depende que no estan en la papelera
+const taskSQLColumns = "id, tittle, description, done, start_at, due_at, priority, parent_id, recurrence, version, created_at, updated_at, deleted_at, " +
+	"(SELECT group_concat(tag) FROM task_tags WHERE task_id = tasks.id), " +
+	"(SELECT group_concat(blocked_by) FROM task_dependencies WHERE task_id = tasks.id), " +
+	"EXISTS (SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id = d.blocked_by WHERE d.task_id = tasks.id AND NOT b.done AND b.deleted_at IS NULL)"
+
+// Funcion para abrir una base de datos SQLite en un archivo
+// Si el archivo es ":memory:" la base de datos vive solo en memoria
+func OpenSQLite(file string) (db *sql.DB, err error) {
+	db, err = sql.Open("sqlite", file)
+	if err != nil {
+		return
+	}
+
+	//SQLite permite un solo escritor a la vez, con una conexion se evitan errores de base bloqueada
+	//y en memoria todas las consultas ven la misma base de datos
+	db.SetMaxOpenConns(1)
+
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return
+	}
+	return
+}
+
+// Funcion para inicializar el repositorio de tareas, crea el esquema si no existe
+func NewTaskSQL(db *sql.DB) (*TaskSQL, error) {
+	t := &TaskSQL{
+		db: db,
+	}
+
+	if err := t.migrate(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Funcion para aplicar las migraciones pendientes
+func (t *TaskSQL) migrate() (err error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		return
+	}
+	defer tx.Rollback()
+
+	//Leer la version actual del esquema
+	var version int
+	if err = tx.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return
+	}
+
+	//Aplicar las migraciones que falten
+	for i := version; i < len(taskSQLMigrations); i++ {
+		if _, err = tx.Exec(taskSQLMigrations[i]); err != nil {
+			err = fmt.Errorf("migration %d: %w", i+1, err)
+			return
+		}
+	}
+
+	//PRAGMA no acepta parametros, la version es un entero que controla el repositorio
+	if _, err = tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", len(taskSQLMigrations))); err != nil {
+		return
+	}
+
+	err = tx.Commit()
+	return
+}
+
+// Funcion para crear una tarea
+func (t *TaskSQL) Save(task *internal.Task) (err error) {
+	//Se validan los campos de la tarea
+	if err = validateTask(task); err != nil {
+		return
+	}
+
+	//La tarea y sus etiquetas se guardan en una transaccion
+	tx, err := t.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	//Se valida la jerarquia de la tarea
+	if err = checkTaskTree(*task, taskSQLTree{tx}); err != nil {
+		return
+	}
+
+	//Se validan las dependencias de la tarea
+	if err = checkTaskDependencies(*task, taskSQLTree{tx}); err != nil {
+		return
+	}
+
+	//Se asignan las fechas de creacion, la tarea recibida se modifica solo si se guarda
+	created := *task
+	created.CreatedAt = changeTime()
+	created.UpdatedAt = created.CreatedAt
+
+	id, err := insertTaskSQL(tx, created)
+	if err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	//Se asigna a la tarea el ID generado, la primera version y las fechas de creacion
+	(*task).ID = id
+	(*task).Version = 1
+	(*task).CreatedAt = created.CreatedAt
+	(*task).UpdatedAt = created.UpdatedAt
+	return
+}
+
+// Funcion para actualizar una tarea
+func (t *TaskSQL) Update(task internal.Task) (err error) {
+	//Se lee y se escribe dentro de una transaccion para validar contra la tarea guardada
+	tx, err := t.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	//Verificar que exista
+	prev, err := scanTask(tx.QueryRow("SELECT "+taskSQLColumns+" FROM tasks WHERE id = ? AND deleted_at IS NULL", task.ID))
+	if err != nil {
+		return
+	}
+
+	//Verificar que nadie la haya modificado desde que se leyo
+	if err = checkVersion(prev, task.Version); err != nil {
+		return
+	}
+	task.Version = prev.Version + 1
+	task.CreatedAt = prev.CreatedAt
+	task.UpdatedAt = changeTime()
+
+	//Validar los campos de la tarea
+	if err = validateTask(&task); err != nil {
+		return
+	}
+
+	//Validar la jerarquia de la tarea
+	if err = checkTaskTree(task, taskSQLTree{tx}); err != nil {
+		return
+	}
+
+	//Validar las dependencias de la tarea
+	if err = checkTaskDependencies(task, taskSQLTree{tx}); err != nil {
+		return
+	}
+
+	if err = updateTaskSQL(tx, task); err != nil {
+		return
+	}
+
+	//Las tareas que bloquea pueden cambiar de Blocked
+	if err = touchDependentsSQL(tx, prev, task); err != nil {
+		return
+	}
+
+	//Si se termino una tarea recurrente se crea la siguiente ocurrencia
+	if err = saveNextOccurrenceSQL(tx, prev, task); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para actualizar parcialmente una tarea
+func (t *TaskSQL) UpdatePartial(id int, version int, patch internal.TaskPatch) (err error) {
+	//Se lee y se escribe dentro de una transaccion para no pisar cambios concurrentes
+	tx, err := t.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	prev, err := scanTask(tx.QueryRow("SELECT "+taskSQLColumns+" FROM tasks WHERE id = ? AND deleted_at IS NULL", id))
+	if err != nil {
+		return
+	}
+
+	//Verificar que nadie la haya modificado desde que se leyo
+	if err = checkVersion(prev, version); err != nil {
+		return
+	}
+
+	//Aplicar los campos a la tarea
+	task := patch.Apply(prev)
+	task.Version = prev.Version + 1
+	task.CreatedAt = prev.CreatedAt
+	task.UpdatedAt = changeTime()
+
+	//Validar los campos de la tarea
+	if err = validateTask(&task); err != nil {
+		return
+	}
+
+	//Validar la jerarquia de la tarea
+	if err = checkTaskTree(task, taskSQLTree{tx}); err != nil {
+		return
+	}
+
+	//Validar las dependencias de la tarea
+	if err = checkTaskDependencies(task, taskSQLTree{tx}); err != nil {
+		return
+	}
+
+	if err = updateTaskSQL(tx, task); err != nil {
+		return
+	}
+
+	//Las tareas que bloquea pueden cambiar de Blocked
+	if err = touchDependentsSQL(tx, prev, task); err != nil {
+		return
+	}
+
+	//Si se termino una tarea recurrente se crea la siguiente ocurrencia
+	if err = saveNextOccurrenceSQL(tx, prev, task); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para eliminar una tarea
+func (t *TaskSQL) Delete(id int, version int) (err error) {
+	//La tarea se mueve a la papelera y se actualizan las relacionadas en una transaccion
+	tx, err := t.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	//Verificar que exista y que nadie la haya modificado desde que se leyo
+	prev, err := scanTask(tx.QueryRow("SELECT "+taskSQLColumns+" FROM tasks WHERE id = ? AND deleted_at IS NULL", id))
+	if err != nil {
+		return
+	}
+	if err = checkVersion(prev, version); err != nil {
+		return
+	}
+
+	//Las subtareas y las tareas que dependian de ella cambian, aumenta su version una sola vez
+	//Las tareas de la papelera no cambian, conservan sus relaciones hasta que se restauren
+	now := changeTime()
+	if _, err = tx.Exec(
+		"UPDATE tasks SET version = version + 1, updated_at = ? WHERE deleted_at IS NULL AND "+
+			"(parent_id = ? OR id IN (SELECT task_id FROM task_dependencies WHERE blocked_by = ?))",
+		timeValue(&now), id, id,
+	); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	//Las subtareas pasan a ser hijas del padre de la tarea eliminada
+	if _, err = tx.Exec("UPDATE tasks SET parent_id = ? WHERE parent_id = ? AND deleted_at IS NULL", prev.ParentID, id); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	//Las tareas que dependian de ella dejan de depender
+	if _, err = tx.Exec(
+		"DELETE FROM task_dependencies WHERE blocked_by = ? AND task_id IN (SELECT id FROM tasks WHERE deleted_at IS NULL)", id,
+	); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	//La tarea conserva sus etiquetas y dependencias en la papelera
+	result, err := tx.Exec(
+		"UPDATE tasks SET version = version + 1, updated_at = ?, deleted_at = ? WHERE id = ?",
+		timeValue(&now), timeValue(&now), id,
+	)
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if err = checkRowsAffected(result); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para listar las tareas de la papelera
+func (t *TaskSQL) ListTrash() (tasks []internal.Task, err error) {
+	tasks, err = queryTrashSQL(t.db)
+	if err != nil {
+		return
+	}
+
+	sortTrash(tasks)
+	return
+}
+
+// Funcion para sacar una tarea de la papelera
+func (t *TaskSQL) Restore(id int) (err error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	//Verificar que este en la papelera
+	prev, err := scanTask(tx.QueryRow("SELECT "+taskSQLColumns+" FROM tasks WHERE id = ? AND deleted_at IS NOT NULL", id))
+	if err != nil {
+		return
+	}
+
+	//Quitar el padre y las dependencias que ya no existen
+	task, err := restoreTask(prev, taskSQLTree{tx})
+	if err != nil {
+		return
+	}
+
+	//Validar la jerarquia y las dependencias contra las tareas actuales
+	if err = checkTaskTree(task, taskSQLTree{tx}); err != nil {
+		return
+	}
+	if err = checkTaskDependencies(task, taskSQLTree{tx}); err != nil {
+		return
+	}
+
+	//Si otra tarea tomo su titulo el indice unico devuelve ErrTaskDuplicated
+	task.Version = prev.Version + 1
+	task.UpdatedAt = changeTime()
+	if err = updateTaskSQL(tx, task); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para eliminar definitivamente una tarea de la papelera
+func (t *TaskSQL) Purge(id int) (err error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	if err = purgeTaskSQL(tx, id); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para eliminar definitivamente las tareas que se movieron a la papelera antes de un instante
+func (t *TaskSQL) PurgeTrash(before time.Time) (purged int, err error) {
+	tx, err := t.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	//Las fechas se comparan leidas y no como texto, RFC 3339 con nanosegundos no se ordena como texto
+	tasks, err := queryTrashSQL(tx)
+	if err != nil {
+		return
+	}
+
+	count := 0
+	for _, task := range tasks {
+		if !task.DeletedAt.Before(before) {
+			continue
+		}
+		if err = purgeTaskSQL(tx, task.ID); err != nil {
+			return
+		}
+		count++
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	purged = count
+	return
+}
+
+// Funcion para leer las tareas de la papelera sin ordenar
+func queryTrashSQL(db taskSQLQuerier) (tasks []internal.Task, err error) {
+	rows, err := db.Query("SELECT " + taskSQLColumns + " FROM tasks WHERE deleted_at IS NOT NULL")
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer rows.Close()
+
+	tasks = []internal.Task{}
+	for rows.Next() {
+		var task internal.Task
+		if task, err = scanTask(rows); err != nil {
+			return
+		}
+		tasks = append(tasks, task)
+	}
+	if err = rows.Err(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para obtener una tarea por id
+func (t *TaskSQL) GetByID(id int) (task internal.Task, err error) {
+	task, err = scanTask(t.db.QueryRow("SELECT "+taskSQLColumns+" FROM tasks WHERE id = ? AND deleted_at IS NULL", id))
+	return
+}
+
+// Funcion para buscar las tareas con un titulo sin distinguir mayusculas
+// lower de SQLite solo convierte letras ASCII, por eso se filtra por la cantidad de caracteres, que es la misma
+// en dos titulos iguales sin distinguir mayusculas, y se comparan los titulos con strings.EqualFold
+func (t *TaskSQL) FindByTittle(tittle string) (ids []int, err error) {
+	rows, err := t.db.Query("SELECT id, tittle FROM tasks WHERE deleted_at IS NULL AND length(tittle) = length(?) ORDER BY id", tittle)
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var value string
+		if err = rows.Scan(&id, &value); err != nil {
+			err = sqlError(err)
+			return
+		}
+		if strings.EqualFold(value, tittle) {
+			ids = append(ids, id)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		err = sqlError(err)
+	}
+	return
+}
+
+// Funcion para agregar una etiqueta a una tarea
+func (t *TaskSQL) AddTag(id int, tag string) (err error) {
+	err = t.changeTag(id, tag, "INSERT OR IGNORE INTO task_tags (task_id, tag) VALUES (?, ?)")
+	return
+}
+
+// Funcion para quitar una etiqueta de una tarea
+func (t *TaskSQL) RemoveTag(id int, tag string) (err error) {
+	err = t.changeTag(id, tag, "DELETE FROM task_tags WHERE task_id = ? AND tag = ?")
+	return
+}
+
+// Funcion para listar las etiquetas en uso
+func (t *TaskSQL) ListTags() (tags []internal.TagCount, err error) {
+	rows, err := t.db.Query("SELECT tag, COUNT(*) FROM task_tags JOIN tasks ON tasks.id = task_tags.task_id " +
+		"WHERE tasks.deleted_at IS NULL GROUP BY tag ORDER BY tag")
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer rows.Close()
+
+	tags = []internal.TagCount{}
+	for rows.Next() {
+		var tag internal.TagCount
+		if err = rows.Scan(&tag.Tag, &tag.Count); err != nil {
+			err = sqlError(err)
+			return
+		}
+		tags = append(tags, tag)
+	}
+	if err = rows.Err(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para agregar una dependencia a una tarea
+func (t *TaskSQL) AddDependency(id int, blockedBy int) (err error) {
+	err = t.changeDependencies(id, func(ids []int) []int {
+		return append(ids, blockedBy)
+	})
+	return
+}
+
+// Funcion para quitar una dependencia de una tarea
+func (t *TaskSQL) RemoveDependency(id int, blockedBy int) (err error) {
+	err = t.changeDependencies(id, func(ids []int) []int {
+		return removeID(ids, blockedBy)
+	})
+	return
+}
+
+// Funcion para cambiar las dependencias de una tarea que debe existir
+func (t *TaskSQL) changeDependencies(id int, change func(ids []int) []int) (err error) {
+	//Se lee y se escribe dentro de una transaccion para validar los ciclos contra las dependencias guardadas
+	tx, err := t.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	task, err := scanTask(tx.QueryRow("SELECT "+taskSQLColumns+" FROM tasks WHERE id = ? AND deleted_at IS NULL", id))
+	if err != nil {
+		return
+	}
+
+	task.BlockedBy = internal.NormalizeDependencies(change(task.BlockedBy))
+	if err = checkTaskDependencies(task, taskSQLTree{tx}); err != nil {
+		return
+	}
+
+	now := changeTime()
+	if _, err = tx.Exec("UPDATE tasks SET version = version + 1, updated_at = ? WHERE id = ?", timeValue(&now), id); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	//Reemplazar las dependencias
+	if _, err = tx.Exec("DELETE FROM task_dependencies WHERE task_id = ?", id); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if err = writeDependenciesSQL(tx, id, task.BlockedBy); err != nil {
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para ejecutar una sentencia sobre una etiqueta de una tarea que debe existir
+func (t *TaskSQL) changeTag(id int, tag string, statement string) (err error) {
+	tag, err = internal.NormalizeTag(tag)
+	if err != nil {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	//Verificar que exista
+	var exists int
+	if err = tx.QueryRow("SELECT 1 FROM tasks WHERE id = ? AND deleted_at IS NULL", id).Scan(&exists); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if _, err = tx.Exec(statement, id, tag); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	now := changeTime()
+	if _, err = tx.Exec("UPDATE tasks SET version = version + 1, updated_at = ? WHERE id = ?", timeValue(&now), id); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para eliminar definitivamente una tarea de la papelera con sus etiquetas y dependencias
+func purgeTaskSQL(tx *sql.Tx, id int) (err error) {
+	result, err := tx.Exec("DELETE FROM tasks WHERE id = ? AND deleted_at IS NOT NULL", id)
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if err = checkRowsAffected(result); err != nil {
+		return
+	}
+
+	if _, err = tx.Exec("DELETE FROM task_tags WHERE task_id = ?", id); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if _, err = tx.Exec("DELETE FROM task_dependencies WHERE task_id = ?", id); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para crear una tarea con sus etiquetas y dependencias dentro de una transaccion
+func insertTaskSQL(tx *sql.Tx, task internal.Task) (id int, err error) {
+	result, err := tx.Exec(
+		"INSERT INTO tasks (tittle, description, done, start_at, due_at, priority, parent_id, recurrence, version, "+
+			"created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?)",
+		task.Tittle, task.Description, task.Done, timeValue(task.StartAt), timeValue(task.DueAt),
+		task.Priority, task.ParentID, recurrenceValue(task.Recurrence), stampValue(task.CreatedAt), stampValue(task.UpdatedAt),
+	)
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	//Se obtiene el ID generado por la base de datos
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	id = int(lastID)
+
+	if err = writeTagsSQL(tx, id, task.Tags); err != nil {
+		return
+	}
+
+	err = writeDependenciesSQL(tx, id, task.BlockedBy)
+	return
+}
+
+// Funcion para crear la siguiente ocurrencia de una tarea recurrente dentro de una transaccion
+func saveNextOccurrenceSQL(tx *sql.Tx, prev, task internal.Task) (err error) {
+	next, ok, err := nextOccurrence(prev, task, func(title string) (taken bool, err error) {
+		if err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE tittle = ? AND deleted_at IS NULL)", title).Scan(&taken); err != nil {
+			err = sqlError(err)
+		}
+		return
+	})
+	if err != nil || !ok {
+		return
+	}
+
+	_, err = insertTaskSQL(tx, next)
+	return
+}
+
+// Funcion para cambiar la version y la fecha de modificacion de las tareas que dejan de estar bloqueadas, o que
+// vuelven a estarlo, porque se termino o se reabrio la tarea que las bloquea, igual que TaskMap
+// Son las tareas que dependen de ella y no estan bloqueadas por otra tarea sin terminar
+func touchDependentsSQL(tx *sql.Tx, prev, task internal.Task) (err error) {
+	if prev.Done == task.Done {
+		return
+	}
+
+	_, err = tx.Exec(
+		"UPDATE tasks SET version = version + 1, updated_at = ? WHERE deleted_at IS NULL "+
+			"AND id IN (SELECT task_id FROM task_dependencies WHERE blocked_by = ?) "+
+			"AND NOT EXISTS (SELECT 1 FROM task_dependencies d JOIN tasks b ON b.id = d.blocked_by "+
+			"WHERE d.task_id = tasks.id AND d.blocked_by != ? AND NOT b.done AND b.deleted_at IS NULL)",
+		stampValue(task.UpdatedAt), task.ID, task.ID,
+	)
+	if err != nil {
+		err = sqlError(err)
+	}
+	return
+}
+
+// Funcion para escribir todos los campos de una tarea dentro de una transaccion
+func updateTaskSQL(tx *sql.Tx, task internal.Task) (err error) {
+	result, err := tx.Exec(
+		"UPDATE tasks SET tittle = ?, description = ?, done = ?, start_at = ?, due_at = ?, priority = ?, parent_id = ?, "+
+			"recurrence = ?, version = ?, created_at = ?, updated_at = ?, deleted_at = ? WHERE id = ?",
+		task.Tittle, task.Description, task.Done, timeValue(task.StartAt), timeValue(task.DueAt), task.Priority,
+		task.ParentID, recurrenceValue(task.Recurrence), task.Version, stampValue(task.CreatedAt), stampValue(task.UpdatedAt),
+		timeValue(task.DeletedAt), task.ID,
+	)
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if err = checkRowsAffected(result); err != nil {
+		return
+	}
+
+	//Reemplazar las etiquetas
+	if _, err = tx.Exec("DELETE FROM task_tags WHERE task_id = ?", task.ID); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if err = writeTagsSQL(tx, task.ID, task.Tags); err != nil {
+		return
+	}
+
+	//Reemplazar las dependencias
+	if _, err = tx.Exec("DELETE FROM task_dependencies WHERE task_id = ?", task.ID); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	err = writeDependenciesSQL(tx, task.ID, task.BlockedBy)
+	return
+}
+
+// Funcion para guardar las etiquetas de una tarea dentro de una transaccion
+func writeTagsSQL(tx *sql.Tx, id int, tags []string) (err error) {
+	for _, tag := range tags {
+		if _, err = tx.Exec("INSERT INTO task_tags (task_id, tag) VALUES (?, ?)", id, tag); err != nil {
+			err = sqlError(err)
+			return
+		}
+	}
+	return
+}
+
+// Acceso a las tareas dentro de una transaccion para validar la jerarquia
+type taskSQLTree struct {
+	tx *sql.Tx
+}
+
+// Metodo para obtener una tarea guardada
+func (r taskSQLTree) get(id int) (task internal.Task, err error) {
+	task, err = scanTask(r.tx.QueryRow("SELECT "+taskSQLColumns+" FROM tasks WHERE id = ? AND deleted_at IS NULL", id))
+	return
+}
+
+// Metodo para saber si una tarea tiene subtareas sin terminar
+func (r taskSQLTree) hasOpenChildren(id int) (open bool, err error) {
+	if err = r.tx.QueryRow("SELECT EXISTS (SELECT 1 FROM tasks WHERE parent_id = ? AND NOT done AND deleted_at IS NULL)", id).Scan(&open); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
+
+// Funcion para guardar las dependencias de una tarea dentro de una transaccion
+func writeDependenciesSQL(tx *sql.Tx, id int, blockedBy []int) (err error) {
+	for _, dependency := range blockedBy {
+		if _, err = tx.Exec("INSERT INTO task_dependencies (task_id, blocked_by) VALUES (?, ?)", id, dependency); err != nil {
+			err = sqlError(err)
+			return
+		}
+	}
+	return
+}
+
+// Interfaz comun de sql.DB y sql.Tx para hacer consultas
+type taskSQLQuerier interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// Interfaz comun de sql.Row y sql.Rows para leer una tarea
+type taskScanner interface {
+	Scan(dest ...any) error
+}
+
+// Funcion para leer una tarea de una fila
+func scanTask(row taskScanner) (task internal.Task, err error) {
+	var startAt, dueAt, recurrence, createdAt, updatedAt, deletedAt, tags, blockedBy sql.NullString
+	var parentID sql.NullInt64
+	if err = row.Scan(&task.ID, &task.Tittle, &task.Description, &task.Done, &startAt, &dueAt, &task.Priority, &parentID,
+		&recurrence, &task.Version, &createdAt, &updatedAt, &deletedAt, &tags, &blockedBy, &task.Blocked); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if blockedBy.Valid {
+		for _, value := range strings.Split(blockedBy.String, ",") {
+			var id int
+			if id, err = strconv.Atoi(value); err != nil {
+				err = fmt.Errorf("%w: %v", internal.ErrTaskInternal, err)
+				return
+			}
+			task.BlockedBy = append(task.BlockedBy, id)
+		}
+		sort.Ints(task.BlockedBy)
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		task.ParentID = &id
+	}
+
+	if tags.Valid {
+		task.Tags = strings.Split(tags.String, ",")
+		sort.Strings(task.Tags)
+	}
+
+	if task.StartAt, err = parseTimeValue(startAt); err != nil {
+		return
+	}
+	if task.DueAt, err = parseTimeValue(dueAt); err != nil {
+		return
+	}
+	if task.CreatedAt, err = parseStampValue(createdAt); err != nil {
+		return
+	}
+	if task.UpdatedAt, err = parseStampValue(updatedAt); err != nil {
+		return
+	}
+	if task.DeletedAt, err = parseTimeValue(deletedAt); err != nil {
+		return
+	}
+
+	if recurrence.Valid {
+		if task.Recurrence, err = internal.ParseRecurrence(recurrence.String); err != nil {
+			err = fmt.Errorf("%w: %v", internal.ErrTaskInternal, err)
+			return
+		}
+	}
+	return
+}
+
+// Funcion para convertir una fecha opcional en el valor que se guarda en la base de datos
+func timeValue(date *time.Time) any {
+	if date == nil {
+		return nil
+	}
+	return date.Format(time.RFC3339Nano)
+}
+
+// Funcion para convertir una fecha de creacion o modificacion en el valor que se guarda, el valor cero es NULL
+func stampValue(date time.Time) any {
+	if date.IsZero() {
+		return nil
+	}
+	return timeValue(&date)
+}
+
+// Funcion para convertir una regla de repeticion opcional en el valor que se guarda en la base de datos
+func recurrenceValue(recurrence *internal.Recurrence) any {
+	if recurrence == nil {
+		return nil
+	}
+	return recurrence.String()
+}
+
+// Funcion para leer una fecha opcional guardada en la base de datos
+func parseTimeValue(value sql.NullString) (date *time.Time, err error) {
+	if !value.Valid {
+		return
+	}
+
+	parsed, err := time.Parse(time.RFC3339Nano, value.String)
+	if err != nil {
+		err = fmt.Errorf("%w: %v", internal.ErrTaskInternal, err)
+		return
+	}
+
+	date = &parsed
+	return
+}
+
+// Funcion para leer una fecha de creacion o modificacion, NULL es el valor cero
+func parseStampValue(value sql.NullString) (date time.Time, err error) {
+	parsed, err := parseTimeValue(value)
+	if err != nil || parsed == nil {
+		return
+	}
+
+	date = *parsed
+	return
+}
+
+// Funcion para verificar que una sentencia haya modificado alguna fila
+func checkRowsAffected(result sql.Result) (err error) {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	if rows == 0 {
+		err = internal.ErrTaskNotFound
+	}
+	return
+}
+
+// Funcion para traducir los errores de la base de datos a los errores del dominio
+func sqlError(err error) error {
+	//No se encontro la fila
+	if errors.Is(err, sql.ErrNoRows) {
+		return internal.ErrTaskNotFound
+	}
+
+	//Se viola la restriccion de titulo unico
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return internal.ErrTaskDuplicated
+	}
+
+	return fmt.Errorf("%w: %v", internal.ErrTaskInternal, err)
+}
@@ -0,0 +1,282 @@
+package repository
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"time"
+
+	"github.com/Taks/internal"
+	"modernc.org/sqlite"
+)
+
+/*
+Este archivo contiene la traduccion de una consulta de tareas (internal.TaskQuery) a SQL.
+
+Los filtros, el orden y la paginacion se hacen en la base de datos y devuelven exactamente lo mismo
+que queryTasks en el resto de implementaciones. Para eso se registran dos funciones en SQLite:
+  - task_lower convierte un texto a minusculas igual que strings.ToLower, lower de SQLite solo convierte ASCII.
+  - task_time convierte una fecha RFC 3339 en un texto UTC de largo fijo, asi las fechas con distinta zona
+    horaria o distinta cantidad de decimales se comparan en orden cronologico.
+
+La paginacion por cursor es por clave (keyset): la pagina empieza despues del valor del campo de
+ordenamiento y el ID de la ultima tarea devuelta, sin leer las tareas anteriores.
+*/
+
+// Formato de task_time, el largo fijo permite comparar las fechas como texto
+const taskSQLTimeFormat = "2006-01-02T15:04:05.000000000Z"
+
+func init() {
+	sqlite.MustRegisterDeterministicScalarFunction("task_lower", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		text, ok := args[0].(string)
+		if !ok {
+			return args[0], nil
+		}
+		return strings.ToLower(text), nil
+	})
+
+	sqlite.MustRegisterDeterministicScalarFunction("task_time", 1, func(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+		text, ok := args[0].(string)
+		if !ok {
+			return nil, nil
+		}
+		date, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return nil, err
+		}
+		return sortableTime(date), nil
+	})
+}
+
+// Funcion para convertir una fecha en el texto que devuelve task_time
+func sortableTime(date time.Time) string {
+	return date.UTC().Format(taskSQLTimeFormat)
+}
+
+// Condiciones y argumentos de una consulta SQL que se arma de a partes
+type taskSQLWhere struct {
+	conditions []string
+	args       []any
+}
+
+// Metodo para agregar una condicion con sus argumentos
+func (w *taskSQLWhere) add(condition string, args ...any) {
+	(*w).conditions = append((*w).conditions, condition)
+	(*w).args = append((*w).args, args...)
+}
+
+// Metodo para obtener la clausula WHERE con todas las condiciones
+func (w *taskSQLWhere) clause() string {
+	return " WHERE " + strings.Join((*w).conditions, " AND ")
+}
+
+// Funcion para traducir los filtros de una consulta ya validada en condiciones SQL
+// Las etiquetas se buscan con el indice de task_tags por etiqueta
+func taskSQLFilters(query internal.TaskQuery) (where taskSQLWhere) {
+	where.add("deleted_at IS NULL")
+
+	if query.Done != nil {
+		where.add("done = ?", *query.Done)
+	}
+
+	if query.Search != "" {
+		search := strings.ToLower(query.Search)
+		where.add("(instr(task_lower(tittle), ?) > 0 OR instr(task_lower(description), ?) > 0)", search, search)
+	}
+
+	//Ventana de fechas limite
+	if query.DueFrom != nil {
+		where.add("task_time(due_at) >= ?", sortableTime(*query.DueFrom))
+	}
+	if query.DueTo != nil {
+		where.add("task_time(due_at) <= ?", sortableTime(*query.DueTo))
+	}
+
+	//Tareas vencidas
+	if query.OverdueAt != nil {
+		where.add("NOT done AND task_time(due_at) < ?", sortableTime(*query.OverdueAt))
+	}
+
+	//Subtareas de una tarea
+	if query.ParentID != nil {
+		where.add("parent_id = ?", *query.ParentID)
+	}
+
+	//Etiquetas, las de la consulta ya estan normalizadas
+	if len(query.TagsAny) > 0 {
+		args := make([]any, 0, len(query.TagsAny))
+		for _, tag := range query.TagsAny {
+			args = append(args, tag)
+		}
+		where.add("id IN (SELECT task_id FROM task_tags WHERE tag IN ("+placeholders(len(args))+"))", args...)
+	}
+	for _, tag := range query.TagsAll {
+		where.add("id IN (SELECT task_id FROM task_tags WHERE tag = ?)", tag)
+	}
+	return
+}
+
+// Funcion para obtener la expresion SQL del campo de ordenamiento y si puede ser nulo
+func taskSQLSortKey(field string) (key string, nullable bool) {
+	switch field {
+	case internal.TaskSortTittle:
+		return "tittle", false
+	case internal.TaskSortDescription:
+		return "description", false
+	case internal.TaskSortDone:
+		return "done", false
+	case internal.TaskSortPriority:
+		return "priority", false
+	case internal.TaskSortStartAt:
+		return "task_time(start_at)", true
+	case internal.TaskSortDueAt:
+		return "task_time(due_at)", true
+	}
+	return "id", false
+}
+
+// Funcion para obtener el valor del campo de ordenamiento de una tarea, como lo devuelve taskSQLSortKey
+// Devuelve nil si la tarea no tiene la fecha
+func taskSQLSortValue(task internal.Task, field string) any {
+	date := func(value *time.Time) any {
+		if value == nil {
+			return nil
+		}
+		return sortableTime(*value)
+	}
+
+	switch field {
+	case internal.TaskSortTittle:
+		return task.Tittle
+	case internal.TaskSortDescription:
+		return task.Description
+	case internal.TaskSortDone:
+		return task.Done
+	case internal.TaskSortPriority:
+		return int(task.Priority)
+	case internal.TaskSortStartAt:
+		return date(task.StartAt)
+	case internal.TaskSortDueAt:
+		return date(task.DueAt)
+	}
+	return task.ID
+}
+
+// Funcion para obtener el ORDER BY de una consulta, el mismo orden que compareTasks
+// Las tareas sin fecha van al final sin importar la direccion y el ID desempata
+func taskSQLOrder(query internal.TaskQuery) string {
+	direction := " ASC"
+	if query.SortDesc {
+		direction = " DESC"
+	}
+
+	key, nullable := taskSQLSortKey(query.SortBy)
+	if key == "id" {
+		return " ORDER BY id" + direction
+	}
+
+	order := " ORDER BY "
+	if nullable {
+		order += key + " IS NULL, "
+	}
+	return order + key + direction + ", id" + direction
+}
+
+// Funcion para agregar la condicion de las tareas posteriores a la ultima tarea de un cursor, en el orden de la consulta
+func taskSQLAfter(where *taskSQLWhere, query internal.TaskQuery, last internal.Task) {
+	after := " > "
+	if query.SortDesc {
+		after = " < "
+	}
+
+	key, nullable := taskSQLSortKey(query.SortBy)
+	if key == "id" {
+		where.add("id"+after+"?", last.ID)
+		return
+	}
+
+	value := taskSQLSortValue(last, query.SortBy)
+	switch {
+	case value == nil:
+		//La ultima tarea no tenia fecha, solo quedan las tareas sin fecha que le siguen por ID
+		where.add("("+key+" IS NULL AND id"+after+"?)", last.ID)
+	case nullable:
+		where.add("("+key+" IS NULL OR "+key+after+"? OR ("+key+" = ? AND id"+after+"?))", value, value, last.ID)
+	default:
+		where.add("("+key+after+"? OR ("+key+" = ? AND id"+after+"?))", value, value, last.ID)
+	}
+}
+
+// Funcion para obtener n parametros separados por comas
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+// Funcion para listar las tareas que cumplan con la consulta
+// El total y la pagina se leen en la misma transaccion, asi corresponden al mismo estado
+func (t *TaskSQL) List(query internal.TaskQuery) (page internal.TaskPage, err error) {
+	//Validar la consulta igual que el resto de implementaciones
+	query, cursor, err := prepareTaskQuery(query)
+	if err != nil {
+		return
+	}
+
+	tx, err := t.db.Begin()
+	if err != nil {
+		err = sqlError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	//Paso 1: Contar las tareas que cumplen los filtros, sin tener en cuenta la paginacion
+	where := taskSQLFilters(query)
+	if err = tx.QueryRow("SELECT count(*) FROM tasks"+where.clause(), where.args...).Scan(&page.Total); err != nil {
+		err = sqlError(err)
+		return
+	}
+
+	//Paso 2: Leer la pagina, con cursor empieza despues de la ultima tarea devuelta
+	//Se lee una tarea de mas para saber si hay una pagina siguiente
+	if cursor != nil {
+		taskSQLAfter(&where, query, cursor.Last)
+	}
+	limit := -1
+	if query.Limit > 0 {
+		limit = query.Limit + 1
+	}
+	args := append(where.args, limit, query.Offset)
+	page.Tasks, err = scanTasksSQL(tx.Query("SELECT "+taskSQLColumns+" FROM tasks"+where.clause()+taskSQLOrder(query)+" LIMIT ? OFFSET ?", args...))
+	if err != nil {
+		return
+	}
+
+	//Paso 3: Si quedan tareas se genera el cursor de la siguiente pagina
+	if query.Limit > 0 && len(page.Tasks) > query.Limit {
+		page.Tasks = page.Tasks[:query.Limit]
+		page.NextCursor, err = encodeTaskCursor(page.Tasks[len(page.Tasks)-1], query)
+	}
+	return
+}
+
+// Funcion para leer todas las tareas de una consulta
+func scanTasksSQL(rows *sql.Rows, errQuery error) (tasks []internal.Task, err error) {
+	if errQuery != nil {
+		err = sqlError(errQuery)
+		return
+	}
+	defer rows.Close()
+
+	tasks = []internal.Task{}
+	for rows.Next() {
+		var task internal.Task
+		if task, err = scanTask(rows); err != nil {
+			return
+		}
+		tasks = append(tasks, task)
+	}
+	if err = rows.Err(); err != nil {
+		err = sqlError(err)
+		return
+	}
+	return
+}
@@ -0,0 +1,96 @@
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Funcion para crear un repositorio SQLite en un archivo temporal
+func newTaskSQL(t *testing.T, file string) *repository.TaskSQL {
+	db, err := repository.OpenSQLite(file)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	rp, err := repository.NewTaskSQL(db)
+	require.NoError(t, err)
+	return rp
+}
+
+// Test del repositorio SQLite
+func TestTaskSQL(t *testing.T) {
+
+	//Test crear, leer, actualizar y eliminar una tarea
+	t.Run("Success - CRUD", func(t *testing.T) {
+
+		//arrange
+		rp := newTaskSQL(t, ":memory:")
+
+		//act
+		task := internal.Task{Tittle: "task 1", Description: "description 1"}
+		require.NoError(t, rp.Save(&task))
+		require.Equal(t, 1, task.ID)
+
+		require.NoError(t, rp.Update(internal.Task{ID: 1, Tittle: "task 1", Description: "updated", Done: true}))
+		require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Tittle: internal.Set("task one"), Priority: internal.Set(internal.PriorityHigh)}))
+		got, err := rp.GetByID(1)
+
+		//assert
+		require.NoError(t, err)
+		require.NotZero(t, got.CreatedAt)
+		require.False(t, got.UpdatedAt.Before(got.CreatedAt))
+		require.Equal(t, internal.Task{ID: 1, Tittle: "task one", Description: "updated", Done: true, Priority: internal.PriorityHigh, Version: 3, CreatedAt: got.CreatedAt, UpdatedAt: got.UpdatedAt}, got)
+
+		require.NoError(t, rp.Delete(1, 0))
+		_, err = rp.GetByID(1)
+		require.ErrorIs(t, err, internal.ErrTaskNotFound)
+	})
+
+	//Test errores del dominio
+	t.Run("Error - Duplicated and not found", func(t *testing.T) {
+
+		//arrange
+		rp := newTaskSQL(t, ":memory:")
+		first := internal.Task{Tittle: "task 1"}
+		second := internal.Task{Tittle: "task 2"}
+		require.NoError(t, rp.Save(&first))
+		require.NoError(t, rp.Save(&second))
+
+		//act & assert
+		duplicated := internal.Task{Tittle: "task 1"}
+		require.ErrorIs(t, rp.Save(&duplicated), internal.ErrTaskDuplicated)
+		require.ErrorIs(t, rp.Update(internal.Task{ID: 2, Tittle: "task 1"}), internal.ErrTaskDuplicated)
+		require.ErrorIs(t, rp.UpdatePartial(2, 0, internal.TaskPatch{Tittle: internal.Set("task 1")}), internal.ErrTaskDuplicated)
+		require.ErrorIs(t, rp.Update(internal.Task{ID: 99, Tittle: "task 99"}), internal.ErrTaskNotFound)
+		require.ErrorIs(t, rp.UpdatePartial(99, 0, internal.TaskPatch{Done: internal.Set(true)}), internal.ErrTaskNotFound)
+		require.ErrorIs(t, rp.Delete(99, 0), internal.ErrTaskNotFound)
+		require.ErrorIs(t, rp.UpdatePartial(2, 0, internal.TaskPatch{Priority: internal.Set(internal.Priority(9))}), internal.ErrTaskInvalidField)
+	})
+
+	//Test las tareas y los IDs se mantienen al reabrir la base de datos
+	t.Run("Success - Data survives reopening", func(t *testing.T) {
+
+		//arrange
+		file := filepath.Join(t.TempDir(), "tasks.db")
+		rp := newTaskSQL(t, file)
+		first := internal.Task{Tittle: "task 1"}
+		second := internal.Task{Tittle: "task 2"}
+		require.NoError(t, rp.Save(&first))
+		require.NoError(t, rp.Save(&second))
+		require.NoError(t, rp.Delete(2, 0))
+
+		//act
+		rp = newTaskSQL(t, file)
+		third := internal.Task{Tittle: "task 3"}
+		require.NoError(t, rp.Save(&third))
+		page, err := rp.List(internal.TaskQuery{SortBy: internal.TaskSortID})
+
+		//assert
+		require.NoError(t, err)
+		require.Equal(t, 3, third.ID, "ids must not be reused")
+		require.Equal(t, 2, page.Total)
+	})
+}
@@ -0,0 +1,80 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de las fechas de creacion y modificacion en todas las implementaciones de TaskRepository
+func TestTimestamps(t *testing.T) {
+	factories := map[string]func(t *testing.T) internal.TaskRepository{
+		"map": func(t *testing.T) internal.TaskRepository {
+			return repository.NewTaskMap(nil, 0)
+		},
+		"sqlite": func(t *testing.T) internal.TaskRepository {
+			return newTaskSQL(t, ":memory:")
+		},
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+
+			//Test la fecha de creacion se mantiene y la de modificacion avanza con cada cambio
+			t.Run("Success - Created and updated at", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				before := time.Now()
+				task := internal.Task{Tittle: "task 1", CreatedAt: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)}
+				require.NoError(t, rp.Save(&task))
+
+				//act
+				saved, err := rp.GetByID(1)
+				require.NoError(t, err)
+				require.NoError(t, rp.Update(internal.Task{ID: 1, Tittle: "task 1", Description: "updated"}))
+				updated, err := rp.GetByID(1)
+				require.NoError(t, err)
+				require.NoError(t, rp.AddTag(1, "home"))
+				tagged, err := rp.GetByID(1)
+				require.NoError(t, err)
+
+				//assert
+				require.Equal(t, task.CreatedAt, saved.CreatedAt)
+				require.False(t, saved.CreatedAt.Before(before.Truncate(time.Second)), "client value must be ignored")
+				require.Equal(t, saved.CreatedAt, saved.UpdatedAt)
+				require.Equal(t, time.UTC, saved.CreatedAt.Location())
+
+				require.Equal(t, saved.CreatedAt, updated.CreatedAt)
+				require.False(t, updated.UpdatedAt.Before(saved.UpdatedAt))
+				require.Equal(t, saved.CreatedAt, tagged.CreatedAt)
+				require.False(t, tagged.UpdatedAt.Before(updated.UpdatedAt))
+			})
+
+			//Test la siguiente ocurrencia se crea en el mismo instante en que se termina la tarea
+			t.Run("Success - Next occurrence", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				dueAt := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+				task := internal.Task{Tittle: "standup", DueAt: &dueAt, Recurrence: &internal.Recurrence{Frequency: internal.FrequencyDaily}}
+				require.NoError(t, rp.Save(&task))
+
+				//act
+				err := rp.UpdatePartial(1, 0, internal.TaskPatch{Done: internal.Set(true)})
+
+				//assert
+				require.NoError(t, err)
+				done, err := rp.GetByID(1)
+				require.NoError(t, err)
+				next, err := rp.GetByID(2)
+				require.NoError(t, err)
+				require.Equal(t, done.UpdatedAt, next.CreatedAt)
+				require.Equal(t, done.UpdatedAt, next.UpdatedAt)
+			})
+		})
+	}
+}
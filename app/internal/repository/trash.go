@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/Taks/internal"
+)
+
+// Funcion para ordenar las tareas de la papelera, primero las eliminadas mas recientemente y despues por ID
+// La comparten las implementaciones de TaskRepository para que todas devuelvan la papelera en el mismo orden
+func sortTrash(tasks []internal.Task) {
+	sort.Slice(tasks, func(i, j int) bool {
+		a, b := tasks[i].DeletedAt, tasks[j].DeletedAt
+		if !a.Equal(*b) {
+			return a.After(*b)
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+}
+
+// Funcion para preparar una tarea de la papelera antes de restaurarla
+//
+// Mientras estuvo en la papelera su padre o las tareas de las que dependia pudieron eliminarse,
+// las que ya no existen se quitan para que la tarea restaurada cumpla las validaciones de la jerarquia.
+func restoreTask(task internal.Task, reader taskTreeReader) (restored internal.Task, err error) {
+	restored = task
+	restored.DeletedAt = nil
+
+	if restored.ParentID != nil {
+		if _, err = reader.get(*restored.ParentID); errors.Is(err, internal.ErrTaskNotFound) {
+			restored.ParentID = nil
+			err = nil
+		}
+		if err != nil {
+			return
+		}
+	}
+
+	var blockedBy []int
+	for _, id := range restored.BlockedBy {
+		_, err = reader.get(id)
+		if errors.Is(err, internal.ErrTaskNotFound) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		blockedBy = append(blockedBy, id)
+	}
+	restored.BlockedBy = blockedBy
+	return
+}
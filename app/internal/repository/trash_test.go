@@ -0,0 +1,208 @@
+package repository_test
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de la papelera en todas las implementaciones de TaskRepository
+func TestTrash(t *testing.T) {
+	factories := map[string]func(t *testing.T) internal.TaskRepository{
+		"map": func(t *testing.T) internal.TaskRepository {
+			return repository.NewTaskMap(nil, 0)
+		},
+		"sqlite": func(t *testing.T) internal.TaskRepository {
+			return newTaskSQL(t, ":memory:")
+		},
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+
+			//Test una tarea eliminada se oculta y se puede restaurar
+			t.Run("Success - Delete and restore", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				task := internal.Task{Tittle: "task 1", Tags: []string{"home"}}
+				require.NoError(t, rp.Save(&task))
+
+				//act
+				require.NoError(t, rp.Delete(1, 1))
+				_, errGet := rp.GetByID(1)
+				page, errList := rp.List(internal.TaskQuery{})
+				tags, errTags := rp.ListTags()
+				trash, errTrash := rp.ListTrash()
+
+				//assert
+				require.ErrorIs(t, errGet, internal.ErrTaskNotFound)
+				require.NoError(t, errList)
+				require.Equal(t, 0, page.Total)
+				require.NoError(t, errTags)
+				require.Empty(t, tags)
+				require.NoError(t, errTrash)
+				require.Len(t, trash, 1)
+				require.NotNil(t, trash[0].DeletedAt)
+				require.Equal(t, 2, trash[0].Version)
+				require.Equal(t, []string{"home"}, trash[0].Tags)
+
+				require.NoError(t, rp.Restore(1))
+				restored, err := rp.GetByID(1)
+				require.NoError(t, err)
+				require.Nil(t, restored.DeletedAt)
+				require.Equal(t, 3, restored.Version)
+				require.Equal(t, []string{"home"}, restored.Tags)
+
+				trash, err = rp.ListTrash()
+				require.NoError(t, err)
+				require.Empty(t, trash)
+			})
+
+			//Test el titulo de una tarea eliminada se puede volver a usar y la restauracion lo vuelve a verificar
+			t.Run("Error - Restore duplicated title", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				first := internal.Task{Tittle: "task 1"}
+				require.NoError(t, rp.Save(&first))
+				require.NoError(t, rp.Delete(first.ID, 0))
+				second := internal.Task{Tittle: "task 1"}
+				require.NoError(t, rp.Save(&second))
+
+				//act
+				err := rp.Restore(first.ID)
+
+				//assert
+				require.ErrorIs(t, err, internal.ErrTaskDuplicated)
+				require.ErrorIs(t, rp.Restore(second.ID), internal.ErrTaskNotFound)
+				trash, err := rp.ListTrash()
+				require.NoError(t, err)
+				require.Len(t, trash, 1)
+			})
+
+			//Test al restaurar se quitan el padre y las dependencias que ya no existen
+			t.Run("Success - Restore drops missing relations", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				parent := internal.Task{Tittle: "parent"}
+				require.NoError(t, rp.Save(&parent))
+				blocker := internal.Task{Tittle: "blocker"}
+				require.NoError(t, rp.Save(&blocker))
+				child := internal.Task{Tittle: "child", ParentID: &parent.ID, BlockedBy: []int{blocker.ID}}
+				require.NoError(t, rp.Save(&child))
+
+				require.NoError(t, rp.Delete(child.ID, 0))
+				require.NoError(t, rp.Delete(parent.ID, 0))
+
+				//act
+				err := rp.Restore(child.ID)
+
+				//assert
+				require.NoError(t, err)
+				task, err := rp.GetByID(child.ID)
+				require.NoError(t, err)
+				require.Nil(t, task.ParentID)
+				require.Equal(t, []int{blocker.ID}, task.BlockedBy)
+			})
+
+			//Test eliminar definitivamente una tarea y las que superaron la retencion
+			t.Run("Success - Purge", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				for _, title := range []string{"task 1", "task 2", "task 3"} {
+					task := internal.Task{Tittle: title}
+					require.NoError(t, rp.Save(&task))
+				}
+				require.NoError(t, rp.Delete(1, 0))
+				require.NoError(t, rp.Delete(2, 0))
+				deletedAt := time.Now()
+				require.NoError(t, rp.Delete(3, 0))
+
+				//act
+				errPurge := rp.Purge(1)
+				errPurged := rp.Purge(1)
+				require.NoError(t, rp.Restore(2))
+				errLive := rp.Purge(2)
+				require.NoError(t, rp.Delete(2, 0))
+				purged, errTrash := rp.PurgeTrash(deletedAt)
+
+				//assert
+				require.NoError(t, errPurge)
+				require.ErrorIs(t, errPurged, internal.ErrTaskNotFound)
+				require.ErrorIs(t, errLive, internal.ErrTaskNotFound, "only tasks in the trash can be purged")
+				require.NoError(t, errTrash)
+				require.Equal(t, 0, purged, "task 2 was deleted again after deletedAt")
+				trash, err := rp.ListTrash()
+				require.NoError(t, err)
+				require.Len(t, trash, 2)
+				require.Equal(t, 2, trash[0].ID, "most recently deleted first")
+
+				purged, err = rp.PurgeTrash(time.Now().Add(time.Second))
+				require.NoError(t, err)
+				require.Equal(t, 2, purged)
+				trash, err = rp.ListTrash()
+				require.NoError(t, err)
+				require.Empty(t, trash)
+			})
+		})
+	}
+
+	//Test la papelera se recupera al reiniciar los repositorios persistentes
+	t.Run("Success - Trash survives reopening", func(t *testing.T) {
+		reopen := map[string]func(t *testing.T, file string) internal.TaskRepository{
+			"file": func(t *testing.T, file string) internal.TaskRepository {
+				rp, err := repository.NewTaskMapFile(file, 0)
+				require.NoError(t, err)
+				return rp
+			},
+			"wal": func(t *testing.T, file string) internal.TaskRepository {
+				rp, err := repository.NewTaskMapWAL(file, 0)
+				require.NoError(t, err)
+				return rp
+			},
+			"sqlite": func(t *testing.T, file string) internal.TaskRepository {
+				return newTaskSQL(t, file)
+			},
+		}
+
+		for name, open := range reopen {
+			t.Run(name, func(t *testing.T) {
+
+				//arrange
+				file := filepath.Join(t.TempDir(), "tasks")
+				rp := open(t, file)
+				for _, title := range []string{"task 1", "task 2", "task 3"} {
+					task := internal.Task{Tittle: title}
+					require.NoError(t, rp.Save(&task))
+				}
+				require.NoError(t, rp.Delete(1, 0))
+				require.NoError(t, rp.Delete(2, 0))
+				require.NoError(t, rp.Delete(3, 0))
+				require.NoError(t, rp.Restore(2))
+				require.NoError(t, rp.Purge(3))
+
+				//act
+				rp = open(t, file)
+
+				//assert
+				trash, err := rp.ListTrash()
+				require.NoError(t, err)
+				require.Len(t, trash, 1)
+				require.Equal(t, 1, trash[0].ID)
+				_, err = rp.GetByID(2)
+				require.NoError(t, err)
+
+				task := internal.Task{Tittle: "task 4"}
+				require.NoError(t, rp.Save(&task))
+				require.Equal(t, 4, task.ID, "ids must not be reused")
+			})
+		}
+	})
+}
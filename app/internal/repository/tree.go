@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"errors"
+
+	"github.com/Taks/internal"
+)
+
+// Acceso a las tareas guardadas que necesitan la validacion de la jerarquia y de las dependencias
+type taskTreeReader interface {
+	// Devuelve internal.ErrTaskNotFound si la tarea no existe
+	get(id int) (task internal.Task, err error)
+
+	// Indica si la tarea tiene alguna subtarea directa sin terminar
+	hasOpenChildren(id int) (open bool, err error)
+}
+
+// Funcion para validar la jerarquia de una tarea antes de guardarla
+// La comparten las implementaciones de TaskRepository para que todas apliquen las mismas reglas
+func checkTaskTree(task internal.Task, reader taskTreeReader) (err error) {
+	//Una tarea no puede terminarse si tiene subtareas sin terminar
+	//Una tarea nueva (sin ID) todavia no tiene subtareas
+	if task.Done && task.ID != 0 {
+		var open bool
+		if open, err = reader.hasOpenChildren(task.ID); err != nil {
+			return
+		}
+		if open {
+			err = internal.ErrTaskOpenChildren
+			return
+		}
+	}
+
+	if task.ParentID == nil {
+		return
+	}
+
+	//Recorrer los ancestros, si se llega a la misma tarea el padre formaria un ciclo
+	parentID := *task.ParentID
+	for {
+		if parentID == task.ID {
+			err = internal.ErrTaskParentCycle
+			return
+		}
+
+		var parent internal.Task
+		parent, err = reader.get(parentID)
+		if errors.Is(err, internal.ErrTaskNotFound) {
+			//El padre debe existir
+			err = internal.ErrTaskInvalidField
+			return
+		}
+		if err != nil {
+			return
+		}
+
+		if parent.ParentID == nil {
+			return
+		}
+		parentID = *parent.ParentID
+	}
+}
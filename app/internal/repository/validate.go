@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"time"
+
+	"github.com/Taks/internal"
+)
+
+// Funcion para validar y normalizar los campos de una tarea antes de guardarla
+// La comparten las implementaciones de TaskRepository para que todas acepten las mismas tareas
+func validateTask(task *internal.Task) (err error) {
+	//La fecha de inicio no puede ser posterior a la fecha limite
+	if task.StartAt != nil && task.DueAt != nil && task.StartAt.After(*task.DueAt) {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+
+	//La prioridad debe ser una de las definidas
+	if !task.Priority.Valid() {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+
+	//Las etiquetas se guardan normalizadas
+	if task.Tags, err = internal.NormalizeTags(task.Tags); err != nil {
+		err = internal.ErrTaskInvalidField
+		return
+	}
+
+	//Una tarea recurrente necesita una regla valida y fecha limite para calcular la siguiente ocurrencia
+	if task.Recurrence != nil {
+		if err = task.Recurrence.Validate(); err != nil {
+			err = internal.ErrTaskInvalidField
+			return
+		}
+		if task.DueAt == nil {
+			err = internal.ErrTaskInvalidField
+			return
+		}
+
+		recurrence := internal.NormalizeRecurrence(*task.Recurrence)
+		task.Recurrence = &recurrence
+	}
+
+	//Las dependencias se guardan sin repetidas y ordenadas
+	task.BlockedBy = internal.NormalizeDependencies(task.BlockedBy)
+
+	return
+}
+
+// Funcion para verificar la version esperada de una tarea antes de modificarla
+// Si version es 0 no se verifica
+func checkVersion(stored internal.Task, version int) (err error) {
+	if version != 0 && version != stored.Version {
+		err = internal.ErrTaskVersionConflict
+	}
+	return
+}
+
+// Funcion para obtener el instante de un cambio que se guarda en CreatedAt y UpdatedAt
+// Es UTC y sin lectura monotonica para que todas las implementaciones guarden y devuelvan el mismo valor
+func changeTime() time.Time {
+	return time.Now().UTC()
+}
@@ -0,0 +1,89 @@
+package repository_test
+
+import (
+	"testing"
+
+	"github.com/Taks/internal"
+	"github.com/Taks/internal/repository"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de las versiones en todas las implementaciones de TaskRepository
+func TestVersions(t *testing.T) {
+	factories := map[string]func(t *testing.T) internal.TaskRepository{
+		"map": func(t *testing.T) internal.TaskRepository {
+			return repository.NewTaskMap(nil, 0)
+		},
+		"sqlite": func(t *testing.T) internal.TaskRepository {
+			return newTaskSQL(t, ":memory:")
+		},
+	}
+
+	for name, factory := range factories {
+		t.Run(name, func(t *testing.T) {
+
+			//Test la version empieza en 1 y aumenta con cada cambio
+			t.Run("Success - Version increases with every change", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				task := internal.Task{Tittle: "task 1"}
+				require.NoError(t, rp.Save(&task))
+				require.Equal(t, 1, task.Version)
+
+				//act
+				require.NoError(t, rp.Update(internal.Task{ID: 1, Tittle: "task 1", Description: "updated", Version: 1}))
+				require.NoError(t, rp.UpdatePartial(1, 2, internal.TaskPatch{Done: internal.Set(true)}))
+				require.NoError(t, rp.AddTag(1, "home"))
+				require.NoError(t, rp.UpdatePartial(1, 0, internal.TaskPatch{Description: internal.Set("any version")}))
+
+				//assert
+				task, err := rp.GetByID(1)
+				require.NoError(t, err)
+				require.Equal(t, 5, task.Version)
+			})
+
+			//Test una version que no es la guardada es un conflicto
+			t.Run("Error - Stale version", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				task := internal.Task{Tittle: "task 1"}
+				require.NoError(t, rp.Save(&task))
+				require.NoError(t, rp.UpdatePartial(1, 1, internal.TaskPatch{Description: internal.Set("first writer")}))
+
+				//act & assert
+				require.ErrorIs(t, rp.Update(internal.Task{ID: 1, Tittle: "task 1", Version: 1}), internal.ErrTaskVersionConflict)
+				require.ErrorIs(t, rp.UpdatePartial(1, 1, internal.TaskPatch{Description: internal.Set("second writer")}), internal.ErrTaskVersionConflict)
+				require.ErrorIs(t, rp.Delete(1, 1), internal.ErrTaskVersionConflict)
+				require.ErrorIs(t, rp.Delete(2, 1), internal.ErrTaskNotFound)
+
+				task, err := rp.GetByID(1)
+				require.NoError(t, err)
+				require.Equal(t, "first writer", task.Description)
+				require.Equal(t, 2, task.Version)
+				require.NoError(t, rp.Delete(1, 2))
+			})
+
+			//Test las tareas que cambian al eliminar otra aumentan su version una sola vez
+			t.Run("Success - Delete bumps related tasks", func(t *testing.T) {
+
+				//arrange
+				rp := factory(t)
+				parent := internal.Task{Tittle: "parent"}
+				require.NoError(t, rp.Save(&parent))
+				child := internal.Task{Tittle: "child", ParentID: &parent.ID, BlockedBy: []int{parent.ID}}
+				require.NoError(t, rp.Save(&child))
+
+				//act
+				err := rp.Delete(parent.ID, 1)
+
+				//assert
+				require.NoError(t, err)
+				task, err := rp.GetByID(child.ID)
+				require.NoError(t, err)
+				require.Equal(t, 2, task.Version)
+			})
+		})
+	}
+}
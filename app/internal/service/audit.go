@@ -0,0 +1,182 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Taks/internal"
+)
+
+// Funcion para leer el estado de una tarea antes o despues de un cambio, devuelve nil si la tarea no esta en ese estado
+type taskState func(id int) (task *internal.Task, err error)
+
+// Funcion para implementar el metodo WithActor de la interfaz TaskService
+// La copia comparte el repositorio, la auditoria, el bloqueo y el aviso de cambios con el servicio original
+func (t *TaskService) WithActor(actor string) internal.TaskService {
+	bound := *t
+	bound.actor = actor
+	return &bound
+}
+
+// Funcion para implementar el metodo History de la interfaz TaskService
+func (t *TaskService) History(query internal.AuditQuery) (page internal.AuditPage, err error) {
+	page, err = t.audit.List(query)
+	return
+}
+
+// Funcion para hacer un cambio sobre una tarea y registrarlo en la auditoria
+// before y after leen la tarea antes y despues del cambio, si before falla el cambio no se hace
+// Los cambios que provoca en otras tareas se registran despues, ver sideEffects
+// next es la tarea despues del cambio leida con el servicio bloqueado, asi corresponde solo a este cambio
+func (t *TaskService) audited(op string, id int, before, after taskState, change func() error) (next *internal.Task, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	next, err = t.auditedLocked(op, id, before, after, change)
+	return
+}
+
+// Funcion para hacer un cambio auditado con el servicio ya bloqueado, asi varios cambios se hacen sin que se
+// intercale otro, ver audited
+func (t *TaskService) auditedLocked(op string, id int, before, after taskState, change func() error) (next *internal.Task, err error) {
+	//Las entradas pendientes se guardan antes de hacer otro cambio, ver flushAudit
+	if err = t.flushAudit(); err != nil {
+		return
+	}
+
+	prev, err := before(id)
+	if err != nil {
+		return
+	}
+
+	effects, err := t.sideEffects(op, id, prev)
+	if err != nil {
+		return
+	}
+
+	if err = change(); err != nil {
+		return
+	}
+
+	if next, err = t.recordChange(op, id, prev, after, effects); err != nil {
+		err = t.auditFailed(op, id, err)
+	}
+	return
+}
+
+// Funcion para registrar un cambio que ya se hizo y los cambios que provoco en otras tareas
+// Las entradas se agregan a las pendientes y despues se guardan todas juntas, ver flushAudit
+func (t *TaskService) recordChange(op string, id int, prev *internal.Task, after taskState, effects sideEffects) (next *internal.Task, err error) {
+	next, err = after(id)
+	if err != nil {
+		return
+	}
+
+	if err = t.record(op, id, prev, next); err != nil {
+		return
+	}
+
+	if err = t.recordSideEffects(effects); err != nil {
+		return
+	}
+
+	err = t.flushAudit()
+	return
+}
+
+// Funcion para informar que no se pudo registrar un cambio que ya se hizo
+// El repositorio y la auditoria no comparten una transaccion, por eso el cambio no se deshace (una tarea
+// eliminada definitivamente no se puede recuperar). Las entradas que no se pudieron guardar quedan pendientes y
+// se devuelve internal.ErrTaskAuditPending para que quien hizo el cambio sepa que se guardo
+// Si ni siquiera se pudo crear la entrada (por ejemplo, no se pudo leer la tarea) el error queda en el logger
+func (t *TaskService) auditFailed(op string, id int, err error) error {
+	if errors.Is(err, internal.ErrTaskAuditUnavailable) {
+		return fmt.Errorf("%w: %s of task %d", internal.ErrTaskAuditPending, op, id)
+	}
+
+	t.logger.Error("audit entry not created", "op", op, "task", id, "error", err)
+	return fmt.Errorf("%w: %s of task %d was saved but its audit entry was not created", internal.ErrTaskInternal, op, id)
+}
+
+// Entradas de auditoria de cambios ya hechos que todavia no se guardaron, en el orden de los cambios
+// Se usan con el servicio bloqueado
+type auditBacklog struct {
+	entries []internal.AuditEntry
+}
+
+// Funcion para guardar en orden las entradas pendientes y avisar a los suscriptores de cada una
+// Si una entrada no se puede guardar queda pendiente junto con las siguientes y ningun cambio nuevo se hace hasta
+// guardarlas, asi el registro de cambios y las suscripciones nunca saltean un cambio que ya se hizo
+func (t *TaskService) flushAudit() (err error) {
+	for len(t.pending.entries) > 0 {
+		entry := t.pending.entries[0]
+		if errAppend := t.audit.Append(&entry); errAppend != nil {
+			t.logger.Error("audit entries pending", "pending", len(t.pending.entries), "op", entry.Op, "task", entry.TaskID, "error", errAppend)
+			err = fmt.Errorf("%w: %d entries pending", internal.ErrTaskAuditUnavailable, len(t.pending.entries))
+			return
+		}
+		t.pending.entries = t.pending.entries[1:]
+
+		//Avisar a las consultas del registro de cambios que estan esperando y a los suscriptores
+		t.feed.publish(entry)
+	}
+	return
+}
+
+// Funcion para crear la entrada de auditoria de un cambio que ya se hizo y agregarla a las pendientes
+func (t *TaskService) record(op string, id int, before, after *internal.Task) (err error) {
+	changes, err := internal.DiffTasks(before, after)
+	if err != nil {
+		return
+	}
+
+	actor := t.actor
+	if actor == "" {
+		actor = internal.AuditActorAnonymous
+	}
+
+	t.pending.entries = append(t.pending.entries, internal.AuditEntry{
+		TaskID:  id,
+		Actor:   actor,
+		At:      time.Now().UTC(),
+		Op:      op,
+		Changes: changes,
+		Task:    after,
+	})
+	return
+}
+
+// Funcion para leer una tarea que no esta en la papelera
+func (t *TaskService) live(id int) (task *internal.Task, err error) {
+	found, err := t.repository.GetByID(id)
+	if err != nil {
+		return
+	}
+
+	task = &found
+	return
+}
+
+// Funcion para leer una tarea de la papelera, si no esta devuelve internal.ErrTaskNotFound
+func (t *TaskService) trashed(id int) (task *internal.Task, err error) {
+	tasks, err := t.repository.ListTrash()
+	if err != nil {
+		return
+	}
+
+	for i := range tasks {
+		if tasks[i].ID == id {
+			task = &tasks[i]
+			return
+		}
+	}
+
+	err = internal.ErrTaskNotFound
+	return
+}
+
+// Funcion para una tarea que ya no existe despues del cambio
+func (t *TaskService) none(id int) (task *internal.Task, err error) {
+	return
+}
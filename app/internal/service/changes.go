@@ -0,0 +1,347 @@
+package service
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/Taks/internal"
+)
+
+// Limites de los cambios que se conservan en memoria para las suscripciones
+const (
+	//Cantidad de cambios recientes que se pueden reenviar a una suscripcion que retoma desde un cursor
+	changeBufferSize = 1000
+
+	//Cantidad de cambios que puede tener pendientes un suscriptor, si no los lee a tiempo se cierra la suscripcion
+	changeSubscriberBuffer = 100
+)
+
+// Aviso de cambios nuevos para las consultas que esperan en Changes y para las suscripciones
+// Cada vez que se registra un cambio se cierra el canal actual y se crea uno nuevo
+type changeFeed struct {
+	mu sync.Mutex
+	ch chan struct{}
+
+	//Ultimos cambios registrados, en orden de ID de entrada, como maximo changeBufferSize
+	buffer []bufferedChange
+
+	//ID de la entrada del ultimo cambio registrado desde que se creo el servicio, 0 si no hubo cambios
+	lastID int
+
+	subscribers map[*changeSubscriber]struct{}
+}
+
+// Cambio que se conserva en memoria junto con el ID de su entrada
+type bufferedChange struct {
+	id     int
+	change internal.Change
+}
+
+// Suscriptor de los cambios, el canal se cierra al quitarlo del aviso
+type changeSubscriber struct {
+	ch     chan internal.Change
+	filter internal.ChangeFilter
+}
+
+// Funcion para inicializar el aviso de cambios
+func newChangeFeed() *changeFeed {
+	return &changeFeed{
+		ch:          make(chan struct{}),
+		subscribers: map[*changeSubscriber]struct{}{},
+	}
+}
+
+// Funcion para obtener el canal que se cierra con el proximo cambio
+func (f *changeFeed) wait() <-chan struct{} {
+	(*f).mu.Lock()
+	defer (*f).mu.Unlock()
+
+	return (*f).ch
+}
+
+// Funcion para avisar que se registro un cambio a las consultas que esperan y a los suscriptores
+// Un suscriptor que tiene su canal lleno se quita, asi un suscriptor lento no frena a los demas
+func (f *changeFeed) publish(entry internal.AuditEntry) {
+	(*f).mu.Lock()
+	defer (*f).mu.Unlock()
+
+	close((*f).ch)
+	(*f).ch = make(chan struct{})
+
+	change := internal.NewChange(entry)
+	if len((*f).buffer) == changeBufferSize {
+		(*f).buffer = (*f).buffer[1:]
+	}
+	(*f).buffer = append((*f).buffer, bufferedChange{id: entry.ID, change: change})
+	(*f).lastID = entry.ID
+
+	for sub := range (*f).subscribers {
+		if !sub.filter.Match(change) {
+			continue
+		}
+
+		select {
+		case sub.ch <- change:
+		default:
+			delete((*f).subscribers, sub)
+			close(sub.ch)
+		}
+	}
+}
+
+// Funcion para agregar un suscriptor, si resume es true primero recibe los cambios conservados posteriores a after
+// gap es true si hay cambios posteriores a after que ya no se conservan o si after no corresponde a un cambio conocido
+func (f *changeFeed) subscribe(after int, resume bool, filter internal.ChangeFilter) (sub *changeSubscriber, gap bool) {
+	(*f).mu.Lock()
+	defer (*f).mu.Unlock()
+
+	var replay []internal.Change
+	if resume {
+		oldest := (*f).lastID + 1
+		if len((*f).buffer) > 0 {
+			oldest = (*f).buffer[0].id
+		}
+		gap = after < oldest-1 || after > (*f).lastID
+
+		for _, buffered := range (*f).buffer {
+			if buffered.id > after && filter.Match(buffered.change) {
+				replay = append(replay, buffered.change)
+			}
+		}
+	}
+
+	//El canal tiene lugar para todos los cambios que se reenvian y para los que lleguen mientras se leen
+	sub = &changeSubscriber{
+		ch:     make(chan internal.Change, len(replay)+changeSubscriberBuffer),
+		filter: filter,
+	}
+	for _, change := range replay {
+		sub.ch <- change
+	}
+
+	(*f).subscribers[sub] = struct{}{}
+	return
+}
+
+// Funcion para quitar un suscriptor y cerrar su canal, si ya se quito no hace nada
+func (f *changeFeed) unsubscribe(sub *changeSubscriber) {
+	(*f).mu.Lock()
+	defer (*f).mu.Unlock()
+
+	if _, ok := (*f).subscribers[sub]; !ok {
+		return
+	}
+
+	delete((*f).subscribers, sub)
+	close(sub.ch)
+}
+
+// Efectos de un cambio sobre otras tareas, se leen antes del cambio para registrarlos despues
+type sideEffects struct {
+	//Tareas que pueden cambiar como consecuencia, con su estado antes del cambio
+	related []internal.Task
+
+	//Si es true el cambio puede crear la siguiente ocurrencia de una tarea recurrente
+	//lastID es el mayor ID de las tareas antes del cambio, las tareas con un ID mayor las creo el cambio
+	spawns bool
+	lastID int
+}
+
+// Funcion para implementar el metodo Changes de la interfaz TaskService
+func (t *TaskService) Changes(ctx context.Context, query internal.ChangeQuery) (page internal.ChangePage, err error) {
+	if err = query.Validate(); err != nil {
+		return
+	}
+
+	after, err := internal.ParseChangeCursor(query.Cursor)
+	if err != nil {
+		return
+	}
+
+	limit := query.Limit
+	if limit == 0 {
+		limit = internal.ChangeDefaultLimit
+	}
+
+	timer := time.NewTimer(min(query.Wait, internal.ChangeMaxWait))
+	defer timer.Stop()
+
+	//Si no hay cambios la siguiente consulta sigue desde el mismo lugar
+	page.Cursor = internal.EncodeChangeCursor(after)
+	page.Changes = []internal.Change{}
+	for {
+		//Paso 1: Se toma el aviso antes de leer, asi no se pierde un cambio que se registre entre la lectura y la espera
+		notified := t.feed.wait()
+
+		//Paso 2: Leer los cambios posteriores al cursor
+		var entries []internal.AuditEntry
+		if entries, err = t.audit.ListSince(after, limit); err != nil {
+			return
+		}
+		if len(entries) > 0 {
+			for _, entry := range entries {
+				page.Changes = append(page.Changes, internal.NewChange(entry))
+			}
+			page.Cursor = page.Changes[len(page.Changes)-1].Cursor
+			return
+		}
+
+		//Paso 3: Si no hay cambios esperar a que llegue alguno, a que se acabe el tiempo o a que se cancele la consulta
+		if query.Wait <= 0 {
+			return
+		}
+		select {
+		case <-notified:
+		case <-timer.C:
+			return
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+	}
+}
+
+// Funcion para implementar el metodo Subscribe de la interfaz TaskService
+func (t *TaskService) Subscribe(ctx context.Context, cursor string, filter internal.ChangeFilter) (subscription internal.ChangeSubscription, err error) {
+	after, err := internal.ParseChangeCursor(cursor)
+	if err != nil {
+		return
+	}
+
+	sub, gap := t.feed.subscribe(after, cursor != "", filter)
+
+	//Cuando se cancela ctx se quita el suscriptor y se cierra su canal
+	context.AfterFunc(ctx, func() {
+		t.feed.unsubscribe(sub)
+	})
+
+	subscription = internal.ChangeSubscription{
+		Changes: sub.ch,
+		Gap:     gap,
+	}
+	return
+}
+
+// Funcion para leer las tareas que pueden cambiar como consecuencia de un cambio
+// Al eliminar una tarea sus subtareas pasan a la tarea padre y las tareas que bloqueaba dejan de estar bloqueadas
+// Al terminar o reabrir una tarea las tareas que bloquea pueden cambiar de Blocked, el repositorio cambia su version
+// Al completar una tarea recurrente se crea la siguiente ocurrencia, se detecta por el mayor ID
+// prev es la tarea antes del cambio, solo se busca el mayor ID si el cambio puede completarla
+func (t *TaskService) sideEffects(op string, id int, prev *internal.Task) (effects sideEffects, err error) {
+	if canComplete(op, prev) {
+		effects.spawns = true
+		if effects.lastID, err = t.lastID(); err != nil {
+			return
+		}
+	}
+
+	if op != internal.AuditOpDelete && !(prev != nil && changesDone(op)) {
+		return
+	}
+
+	page, err := t.repository.List(internal.TaskQuery{})
+	if err != nil {
+		return
+	}
+	for _, task := range page.Tasks {
+		if task.ID == id {
+			continue
+		}
+		child := op == internal.AuditOpDelete && task.ParentID != nil && *task.ParentID == id
+		if child || slices.Contains(task.BlockedBy, id) {
+			effects.related = append(effects.related, task)
+		}
+	}
+	return
+}
+
+// Funcion para registrar en la auditoria los efectos de un cambio que ya se hizo
+// Las tareas relacionadas solo se registran si cambiaron y las tareas nuevas se registran en orden de ID
+func (t *TaskService) recordSideEffects(effects sideEffects) (err error) {
+	for i := range effects.related {
+		before := &effects.related[i]
+
+		var after *internal.Task
+		if after, err = t.live(before.ID); err != nil {
+			return
+		}
+		if after.Version == before.Version {
+			continue
+		}
+
+		if err = t.record(internal.AuditOpRelated, before.ID, before, after); err != nil {
+			return
+		}
+	}
+
+	if !effects.spawns {
+		return
+	}
+
+	created, err := t.tasksAfter(effects.lastID)
+	if err != nil {
+		return
+	}
+	for i := range created {
+		if err = t.record(internal.AuditOpRelated, created[i].ID, nil, &created[i]); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Funcion para saber si un cambio puede completar una tarea sin terminar
+// Solo las actualizaciones cambian Done, una restauracion o un cambio de etiquetas no crean ocurrencias
+func canComplete(op string, prev *internal.Task) bool {
+	if prev == nil || prev.Done {
+		return false
+	}
+	return changesDone(op)
+}
+
+// Funcion para saber si un cambio puede cambiar Done de una tarea, solo las actualizaciones lo cambian
+func changesDone(op string) bool {
+	return op == internal.AuditOpUpdate || op == internal.AuditOpUpdatePartial || op == internal.AuditOpRevert
+}
+
+// Funcion para obtener el mayor ID de las tareas, 0 si no hay tareas
+// Las tareas de la papelera no se tienen en cuenta, alcanza porque canComplete descarta las restauraciones y
+// la ocurrencia nueva recibe un ID mayor que el de cualquier tarea, tambien las de la papelera
+func (t *TaskService) lastID() (id int, err error) {
+	page, err := t.repository.List(internal.TaskQuery{SortBy: internal.TaskSortID, SortDesc: true, Limit: 1})
+	if err != nil {
+		return
+	}
+
+	if len(page.Tasks) > 0 {
+		id = page.Tasks[0].ID
+	}
+	return
+}
+
+// Funcion para obtener las tareas con un ID mayor a id, ordenadas por ID
+func (t *TaskService) tasksAfter(id int) (tasks []internal.Task, err error) {
+	query := internal.TaskQuery{SortBy: internal.TaskSortID, SortDesc: true, Limit: 10}
+	for {
+		var page internal.TaskPage
+		if page, err = t.repository.List(query); err != nil {
+			return
+		}
+
+		for _, task := range page.Tasks {
+			if task.ID <= id {
+				slices.Reverse(tasks)
+				return
+			}
+			tasks = append(tasks, task)
+		}
+
+		if page.NextCursor == "" {
+			slices.Reverse(tasks)
+			return
+		}
+		query.Cursor = page.NextCursor
+	}
+}
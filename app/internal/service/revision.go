@@ -0,0 +1,156 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Taks/internal"
+)
+
+// Funcion para implementar el metodo Revision de la interfaz TaskService
+func (t *TaskService) Revision(id int, revision int) (entry internal.AuditEntry, err error) {
+	if revision < 1 {
+		err = fmt.Errorf("%w: invalid revision %d", internal.ErrTaskInvalidField, revision)
+		return
+	}
+
+	page, err := t.audit.List(internal.AuditQuery{TaskID: id, Limit: 1, Offset: revision - 1})
+	if err != nil {
+		return
+	}
+	if len(page.Entries) == 0 {
+		err = internal.ErrTaskRevisionNotFound
+		return
+	}
+
+	entry, err = revisionEntry(page.Entries[0])
+	return
+}
+
+// Funcion para implementar el metodo RevisionAt de la interfaz TaskService
+// Los cambios se registran en orden, por eso la ultima entrada hasta at es el estado de la tarea en ese instante
+func (t *TaskService) RevisionAt(id int, at time.Time) (entry internal.AuditEntry, err error) {
+	page, err := t.audit.List(internal.AuditQuery{TaskID: id})
+	if err != nil {
+		return
+	}
+
+	found := false
+	for _, candidate := range page.Entries {
+		if candidate.At.After(at) {
+			break
+		}
+		entry, found = candidate, true
+	}
+
+	//La tarea todavia no existia en ese instante
+	if !found {
+		err = internal.ErrTaskRevisionNotFound
+		return
+	}
+
+	entry, err = revisionEntry(entry)
+	return
+}
+
+// Funcion para implementar el metodo Revert de la interfaz TaskService
+// Se aplican los campos de la revision sobre la tarea actual con Update, asi se validan igual que cualquier cambio
+// (por ejemplo, el titulo debe cumplir las reglas actuales y no puede estar en uso por otra tarea)
+// Devuelve la tarea guardada con su nueva version
+func (t *TaskService) Revert(id int, revision int, version int) (reverted internal.Task, err error) {
+	entry, err := t.Revision(id, revision)
+	if err != nil {
+		return
+	}
+
+	next, err := t.audited(internal.AuditOpRevert, id, t.live, t.live, func() (err error) {
+		current, err := t.repository.GetByID(id)
+		if err != nil {
+			return
+		}
+
+		task, err := t.revertedTask(current, *entry.Task)
+		if err != nil {
+			return
+		}
+
+		if err = t.rules.Apply(&task); err != nil {
+			return
+		}
+		if err = t.checkTittle(id, task.Tittle); err != nil {
+			return
+		}
+
+		task.Version = version
+		err = t.repository.Update(task)
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	reverted = *next
+	return
+}
+
+// Funcion para obtener la tarea actual con los campos de una revision
+// El padre y las dependencias que ya no existen se quitan, como al restaurar una tarea de la papelera
+func (t *TaskService) revertedTask(current, revision internal.Task) (task internal.Task, err error) {
+	task = current
+	task.Tittle = revision.Tittle
+	task.Description = revision.Description
+	task.Done = revision.Done
+	task.StartAt = revision.StartAt
+	task.DueAt = revision.DueAt
+	task.Priority = revision.Priority
+	task.Tags = revision.Tags
+	task.Recurrence = revision.Recurrence
+
+	task.ParentID = nil
+	if revision.ParentID != nil {
+		var exists bool
+		if exists, err = t.exists(*revision.ParentID); err != nil {
+			return
+		}
+		if exists {
+			task.ParentID = revision.ParentID
+		}
+	}
+
+	task.BlockedBy = nil
+	for _, blockedBy := range revision.BlockedBy {
+		var exists bool
+		if exists, err = t.exists(blockedBy); err != nil {
+			return
+		}
+		if exists {
+			task.BlockedBy = append(task.BlockedBy, blockedBy)
+		}
+	}
+	return
+}
+
+// Funcion para saber si una tarea existe y no esta en la papelera
+func (t *TaskService) exists(id int) (exists bool, err error) {
+	_, err = t.repository.GetByID(id)
+	if errors.Is(err, internal.ErrTaskNotFound) {
+		err = nil
+		return
+	}
+
+	exists = err == nil
+	return
+}
+
+// Funcion para verificar que una entrada tenga el estado de la tarea
+// Las entradas de tareas eliminadas definitivamente y las guardadas antes de registrar revisiones no lo tienen
+func revisionEntry(entry internal.AuditEntry) (revision internal.AuditEntry, err error) {
+	if entry.Task == nil {
+		err = internal.ErrTaskRevisionNotFound
+		return
+	}
+
+	revision = entry
+	return
+}
@@ -1,45 +1,230 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
 	"github.com/Taks/internal"
 )
 
 /*
 Estructura de TaskService que se conecta con el repositorio.
 Desde el servicio se acceder a todo lo que ofrece el repositorio
+Cada cambio sobre una tarea se registra en la auditoria con el actor del servicio, ver WithActor
 */
 type TaskService struct {
 	repository internal.TaskRepository
+	audit      internal.AuditStore
+
+	//Quien hace los cambios, si esta vacio se registra internal.AuditActorAnonymous
+	actor string
+
+	//Serializa los cambios auditados, asi el estado anterior y posterior de cada entrada corresponde solo a su cambio
+	//Es un puntero porque lo comparten todas las copias creadas con WithActor
+	mu *sync.Mutex
+
+	//Avisa a las consultas que esperan en Changes cuando se registra un cambio, tambien lo comparten las copias
+	feed *changeFeed
+
+	//Entradas de auditoria de cambios ya hechos que todavia no se pudieron guardar, tambien lo comparten las copias
+	pending *auditBacklog
+
+	//Donde se informan los errores que no se pueden devolver a quien hizo el cambio, por ejemplo una entrada pendiente
+	logger *slog.Logger
+
+	//Reglas del titulo y la descripcion que se aplican antes de guardar una tarea
+	rules internal.TaskRules
 }
 
 // Funcion para inicializar el servicio de tareas
-func NewTaskService(rp internal.TaskRepository) *TaskService {
+func NewTaskService(rp internal.TaskRepository, audit internal.AuditStore) *TaskService {
 	return &TaskService{
 		repository: rp,
+		audit:      audit,
+		mu:         &sync.Mutex{},
+		feed:       newChangeFeed(),
+		pending:    &auditBacklog{},
+		logger:     slog.Default(),
+		rules:      internal.DefaultTaskRules(),
 	}
 }
 
+// Funcion para obtener una copia del servicio que informa sus errores en logger
+func (t *TaskService) WithLogger(logger *slog.Logger) *TaskService {
+	bound := *t
+	bound.logger = logger
+	return &bound
+}
+
+// Funcion para obtener una copia del servicio que aplica otras reglas al titulo y la descripcion
+func (t *TaskService) WithRules(rules internal.TaskRules) *TaskService {
+	bound := *t
+	bound.rules = rules
+	return &bound
+}
+
 // Funcion para implementar el metodo Save de la interfaz TaskService
 func (t *TaskService) Save(task *internal.Task) (err error) {
-	err = t.repository.Save(task)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	//Las entradas pendientes se guardan antes de hacer otro cambio, ver flushAudit
+	if err = t.flushAudit(); err != nil {
+		return
+	}
+
+	//Normalizar el titulo y la descripcion antes de que el repositorio busque titulos duplicados
+	if err = t.rules.Apply(task); err != nil {
+		return
+	}
+	if err = t.checkTittle((*task).ID, (*task).Tittle); err != nil {
+		return
+	}
+
+	if err = t.repository.Save(task); err != nil {
+		return
+	}
+
+	//La tarea no existia antes, se registran todos sus campos
+	if _, err = t.recordChange(internal.AuditOpSave, (*task).ID, nil, t.live, sideEffects{}); err != nil {
+		err = t.auditFailed(internal.AuditOpSave, (*task).ID, err)
+	}
 	return
 }
 
 // Funcion para implementar el metodo Update de la interfaz TaskService
-func (t *TaskService) Update(task internal.Task) (err error) {
-	err = t.repository.Update(task)
+// Devuelve la tarea guardada con su nueva version
+func (t *TaskService) Update(task internal.Task) (updated internal.Task, err error) {
+	if err = t.rules.Apply(&task); err != nil {
+		return
+	}
+
+	next, err := t.audited(internal.AuditOpUpdate, task.ID, t.live, t.live, func() (err error) {
+		if err = t.checkTittle(task.ID, task.Tittle); err != nil {
+			return
+		}
+		err = t.repository.Update(task)
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	updated = *next
 	return
 }
 
 // Funcion para implementar el metodo UpdatePartial de la interfaz TaskService
-func (t *TaskService) UpdatePartial(id int, fields map[string]any) (err error) {
-	err = t.repository.UpdatePartial(id, fields)
+// Devuelve la tarea guardada con su nueva version
+func (t *TaskService) UpdatePartial(id int, version int, patch internal.TaskPatch) (updated internal.Task, err error) {
+	if err = t.rules.ApplyPatch(&patch); err != nil {
+		return
+	}
+
+	next, err := t.audited(internal.AuditOpUpdatePartial, id, t.live, t.live, func() (err error) {
+		if patch.Tittle.Set {
+			if err = t.checkTittle(id, patch.Tittle.Value); err != nil {
+				return
+			}
+		}
+		err = t.repository.UpdatePartial(id, version, patch)
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	updated = *next
 	return
 }
 
 // Funcion para implementar el metodo Delete de la interfaz TaskService
-func (t *TaskService) Delete(id int) (err error) {
-	err = t.repository.Delete(id)
+func (t *TaskService) Delete(id int, version int) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	err = t.deleteLocked(id, version)
+	return
+}
+
+// Funcion para mover una tarea a la papelera con el servicio ya bloqueado
+func (t *TaskService) deleteLocked(id int, version int) (err error) {
+	_, err = t.auditedLocked(internal.AuditOpDelete, id, t.live, t.trashed, func() error {
+		return t.repository.Delete(id, version)
+	})
+	return
+}
+
+// Funcion para implementar el metodo ListTrash de la interfaz TaskService
+func (t *TaskService) ListTrash() (tasks []internal.Task, err error) {
+	tasks, err = t.repository.ListTrash()
+	return
+}
+
+// Funcion para implementar el metodo Restore de la interfaz TaskService
+// Devuelve la tarea restaurada con su nueva version
+func (t *TaskService) Restore(id int) (restored internal.Task, err error) {
+	next, err := t.audited(internal.AuditOpRestore, id, t.trashed, t.live, func() (err error) {
+		trashed, err := t.trashed(id)
+		if err != nil {
+			return
+		}
+		if err = t.checkTittle(id, (*trashed).Tittle); err != nil {
+			return
+		}
+		err = t.repository.Restore(id)
+		return
+	})
+	if err != nil {
+		return
+	}
+
+	restored = *next
+	return
+}
+
+// Funcion para implementar el metodo Purge de la interfaz TaskService
+func (t *TaskService) Purge(id int) (err error) {
+	_, err = t.audited(internal.AuditOpPurge, id, t.trashed, t.none, func() error {
+		return t.repository.Purge(id)
+	})
+	return
+}
+
+// Funcion para implementar el metodo PurgeExpired de la interfaz TaskService
+// Cada tarea se elimina con Purge para que quede registrada en la auditoria
+func (t *TaskService) PurgeExpired(retention time.Duration) (purged int, err error) {
+	before := time.Now().Add(-retention)
+	tasks, err := t.repository.ListTrash()
+	if err != nil {
+		return
+	}
+
+	for _, task := range tasks {
+		if !task.DeletedAt.Before(before) {
+			continue
+		}
+
+		//Si mientras tanto se restauro o se elimino definitivamente se saltea
+		err = t.Purge(task.ID)
+		if errors.Is(err, internal.ErrTaskNotFound) {
+			err = nil
+			continue
+		}
+		if err != nil {
+			return
+		}
+		purged++
+	}
+	return
+}
+
+// Funcion para implementar el metodo List de la interfaz TaskService
+func (t *TaskService) List(query internal.TaskQuery) (page internal.TaskPage, err error) {
+	page, err = t.repository.List(query)
 	return
 }
 
@@ -48,3 +233,174 @@ func (t *TaskService) GetByID(id int) (task internal.Task, err error) {
 	task, err = t.repository.GetByID(id)
 	return
 }
+
+// Funcion para implementar el metodo AddTag de la interfaz TaskService
+// Devuelve la tarea guardada con su nueva version
+func (t *TaskService) AddTag(id int, tag string) (updated internal.Task, err error) {
+	next, err := t.audited(internal.AuditOpAddTag, id, t.live, t.live, func() error {
+		return t.repository.AddTag(id, tag)
+	})
+	if err != nil {
+		return
+	}
+
+	updated = *next
+	return
+}
+
+// Funcion para implementar el metodo RemoveTag de la interfaz TaskService
+// Devuelve la tarea guardada con su nueva version
+func (t *TaskService) RemoveTag(id int, tag string) (updated internal.Task, err error) {
+	next, err := t.audited(internal.AuditOpRemoveTag, id, t.live, t.live, func() error {
+		return t.repository.RemoveTag(id, tag)
+	})
+	if err != nil {
+		return
+	}
+
+	updated = *next
+	return
+}
+
+// Funcion para implementar el metodo ListTags de la interfaz TaskService
+func (t *TaskService) ListTags() (tags []internal.TagCount, err error) {
+	tags, err = t.repository.ListTags()
+	return
+}
+
+// Funcion para implementar el metodo AddDependency de la interfaz TaskService
+// Devuelve la tarea guardada con su nueva version
+func (t *TaskService) AddDependency(id int, blockedBy int) (updated internal.Task, err error) {
+	next, err := t.audited(internal.AuditOpAddDependency, id, t.live, t.live, func() error {
+		return t.repository.AddDependency(id, blockedBy)
+	})
+	if err != nil {
+		return
+	}
+
+	updated = *next
+	return
+}
+
+// Funcion para implementar el metodo RemoveDependency de la interfaz TaskService
+// Devuelve la tarea guardada con su nueva version
+func (t *TaskService) RemoveDependency(id int, blockedBy int) (updated internal.Task, err error) {
+	next, err := t.audited(internal.AuditOpRemoveDependency, id, t.live, t.live, func() error {
+		return t.repository.RemoveDependency(id, blockedBy)
+	})
+	if err != nil {
+		return
+	}
+
+	updated = *next
+	return
+}
+
+// Funcion para implementar el metodo NextTasks de la interfaz TaskService
+// Las tareas terminadas no se incluyen, las dependencias con ellas ya estan cumplidas
+func (t *TaskService) NextTasks() (tasks []internal.Task, err error) {
+	done := false
+	page, err := t.repository.List(internal.TaskQuery{Done: &done})
+	if err != nil {
+		return
+	}
+
+	tasks, err = internal.SortByDependencies(page.Tasks)
+	return
+}
+
+// Funcion para implementar el metodo DeleteCascade de la interfaz TaskService
+// Las subtareas se eliminan antes que su padre, asi si una eliminacion falla el arbol que queda sigue siendo valido
+// La version se verifica antes de empezar, al eliminar las subtareas la version de la tarea puede cambiar
+// El servicio queda bloqueado desde que se lee el arbol hasta que se elimina, asi ningun otro cambio del
+// servicio se intercala: el arbol eliminado es el mismo que se leyo y la version verificada sigue vigente
+func (t *TaskService) DeleteCascade(id int, version int) (err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	tree, err := t.GetSubtree(id)
+	if err != nil {
+		return
+	}
+
+	if version != 0 && version != tree.Task.Version {
+		err = internal.ErrTaskVersionConflict
+		return
+	}
+
+	err = t.deleteTree(tree)
+	return
+}
+
+// Funcion para implementar el metodo GetChildren de la interfaz TaskService
+func (t *TaskService) GetChildren(id int) (children []internal.Task, err error) {
+	//Verificar que exista
+	if _, err = t.repository.GetByID(id); err != nil {
+		return
+	}
+
+	page, err := t.repository.List(internal.TaskQuery{ParentID: &id})
+	if err != nil {
+		return
+	}
+
+	children = page.Tasks
+	return
+}
+
+// Funcion para implementar el metodo GetSubtree de la interfaz TaskService
+func (t *TaskService) GetSubtree(id int) (tree internal.TaskTree, err error) {
+	tree.Task, err = t.repository.GetByID(id)
+	if err != nil {
+		return
+	}
+
+	//El repositorio no permite ciclos, por eso la recursion siempre termina
+	page, err := t.repository.List(internal.TaskQuery{ParentID: &id})
+	if err != nil {
+		return
+	}
+
+	tree.Children = make([]internal.TaskTree, 0, len(page.Tasks))
+	for _, child := range page.Tasks {
+		var subtree internal.TaskTree
+		subtree, err = t.GetSubtree(child.ID)
+		if err != nil {
+			return
+		}
+		tree.Children = append(tree.Children, subtree)
+	}
+	return
+}
+
+// Funcion para eliminar un arbol de tareas empezando por las hojas, con el servicio ya bloqueado
+func (t *TaskService) deleteTree(tree internal.TaskTree) (err error) {
+	for _, child := range tree.Children {
+		if err = t.deleteTree(child); err != nil {
+			return
+		}
+	}
+
+	err = t.deleteLocked(tree.Task.ID, 0)
+	return
+}
+
+// Funcion para verificar que ninguna otra tarea use el titulo cuando las reglas no distinguen mayusculas
+// Los titulos exactamente iguales los rechaza el repositorio, por eso con las reglas por defecto no se lee nada
+func (t *TaskService) checkTittle(id int, tittle string) (err error) {
+	if !t.rules.CaseInsensitiveTittles {
+		return
+	}
+
+	ids, err := t.repository.FindByTittle(tittle)
+	if err != nil {
+		return
+	}
+	for _, other := range ids {
+		if other != id {
+			err = fmt.Errorf("%w: tittle %q is used by task %d", internal.ErrTaskDuplicated, tittle, other)
+			return
+		}
+	}
+	return
+}
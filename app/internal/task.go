@@ -1,6 +1,15 @@
 package internal
 
-import "errors"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Taks/internal/tools"
+)
 
 /*
 	Este archivo es el domain que debe ir en la raiz de internal
@@ -25,6 +34,215 @@ type Task struct {
 	Tittle      string
 	Description string
 	Done        bool
+
+	//Fecha desde la que se puede empezar la tarea, es opcional
+	StartAt *time.Time
+
+	//Fecha limite de la tarea, es opcional y no puede ser anterior a StartAt
+	DueAt *time.Time
+
+	//Prioridad de la tarea, por defecto PriorityNone
+	Priority Priority
+
+	//Etiquetas de la tarea, normalizadas con NormalizeTags
+	Tags []string
+
+	//ID de la tarea padre, si es nil la tarea no es una subtarea
+	ParentID *int
+
+	//IDs de las tareas que deben terminarse antes de empezar esta, normalizados con NormalizeDependencies
+	BlockedBy []int
+
+	//Indica si alguna de las tareas de BlockedBy no esta terminada
+	//Lo calcula el repositorio al leer la tarea y se ignora al guardarla
+	Blocked bool
+
+	//Version de la tarea, empieza en 1 y el repositorio la aumenta con cada cambio
+	//Al actualizar, si es distinta de 0 debe coincidir con la guardada o se devuelve ErrTaskVersionConflict
+	Version int
+
+	//Fechas de creacion y de la ultima modificacion, en UTC
+	//Las asigna el repositorio al guardar la tarea, el valor cero indica que no se conocen
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	//Fecha en que la tarea se movio a la papelera, si es nil la tarea no esta en la papelera
+	//Las tareas de la papelera no se devuelven en GetByID ni en List y no cuentan para los titulos duplicados
+	DeletedAt *time.Time
+
+	//Regla de repeticion, si es nil la tarea no se repite
+	//Una tarea recurrente necesita fecha limite: al terminarla se crea la siguiente ocurrencia
+	Recurrence *Recurrence
+}
+
+// Arbol de una tarea con todas sus subtareas
+type TaskTree struct {
+	Task     Task
+	Children []TaskTree
+}
+
+// Cantidad de tareas que tienen una etiqueta
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// Funcion para normalizar una etiqueta: sin espacios al principio o al final y en minusculas
+// Una etiqueta no puede estar vacia ni tener comas, porque en las consultas se separan con comas
+func NormalizeTag(tag string) (normalized string, err error) {
+	normalized = strings.ToLower(strings.TrimSpace(tag))
+	if normalized == "" || strings.Contains(normalized, ",") {
+		err = fmt.Errorf("%w: invalid tag %q", ErrTaskInvalidField, tag)
+		return
+	}
+	return
+}
+
+// Funcion para normalizar un conjunto de etiquetas: cada una con NormalizeTag, sin repetidas y ordenadas
+// Si no hay etiquetas devuelve nil
+func NormalizeTags(tags []string) (normalized []string, err error) {
+	seen := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		tag, err = NormalizeTag(tag)
+		if err != nil {
+			normalized = nil
+			return
+		}
+
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+
+	sort.Strings(normalized)
+	return
+}
+
+// Prioridad de una tarea, de menor a mayor
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+// Nombres de las prioridades, en el mismo orden que las constantes
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+// Funcion para obtener una prioridad a partir de su nombre, vacio es PriorityNone
+func ParsePriority(name string) (priority Priority, err error) {
+	if name == "" {
+		return
+	}
+
+	for i, priorityName := range priorityNames {
+		if priorityName == name {
+			priority = Priority(i)
+			return
+		}
+	}
+
+	err = fmt.Errorf("%w: unknown priority %q", ErrTaskInvalidField, name)
+	return
+}
+
+// Mensaje del error de validacion de una prioridad desconocida
+const MsgInvalidPriority = "invalid priority"
+
+// Regla para que un string sea el nombre de una prioridad, los valores que no son string no se validan
+func PriorityRule() tools.Rule {
+	return tools.Format(MsgInvalidPriority, func(text string) bool {
+		_, err := ParsePriority(text)
+		return err == nil
+	})
+}
+
+// Metodo para saber si la prioridad es una de las definidas
+func (p Priority) Valid() bool {
+	return p >= PriorityNone && p <= PriorityUrgent
+}
+
+// Metodo para obtener el nombre de la prioridad
+func (p Priority) String() string {
+	if !p.Valid() {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+// Metodo para escribir la prioridad en JSON con su nombre
+func (p Priority) MarshalText() ([]byte, error) {
+	if !p.Valid() {
+		return nil, fmt.Errorf("%w: unknown priority %d", ErrTaskInvalidField, int(p))
+	}
+	return []byte(p.String()), nil
+}
+
+// Metodo para leer la prioridad de JSON a partir de su nombre
+func (p *Priority) UnmarshalText(text []byte) (err error) {
+	*p, err = ParsePriority(string(text))
+	return
+}
+
+// Campos por los que se puede ordenar un listado de tareas
+const (
+	TaskSortID          = "id"
+	TaskSortTittle      = "tittle"
+	TaskSortDescription = "description"
+	TaskSortDone        = "done"
+	TaskSortStartAt     = "start_at"
+	TaskSortDueAt       = "due_at"
+	TaskSortPriority    = "priority"
+)
+
+// Consulta para listar tareas: filtros, orden y paginacion
+type TaskQuery struct {
+	//Filtrar por estado, si es nil no se filtra
+	Done *bool
+
+	//Texto que debe estar contenido en el titulo o la descripcion (sin distinguir mayusculas)
+	Search string
+
+	//Ventana de fechas limite, incluye los extremos y excluye las tareas sin fecha limite
+	DueFrom *time.Time
+	DueTo   *time.Time
+
+	//Solo tareas sin terminar cuya fecha limite es anterior a este instante
+	OverdueAt *time.Time
+
+	//Tareas que tienen alguna de estas etiquetas y tareas que tienen todas estas etiquetas
+	TagsAny []string
+	TagsAll []string
+
+	//Solo las subtareas directas de esta tarea
+	ParentID *int
+
+	//Campo por el que se ordena (por defecto el ID) y si el orden es descendente
+	SortBy   string
+	SortDesc bool
+
+	//Cantidad maxima de tareas a devolver, 0 devuelve todas
+	Limit int
+
+	//Paginacion por offset o por cursor, no se pueden usar las dos a la vez
+	Offset int
+	Cursor string
+}
+
+// Resultado de una consulta de tareas
+type TaskPage struct {
+	//Tareas de la pagina
+	Tasks []Task
+
+	//Total de tareas que cumplen los filtros, sin tener en cuenta la paginacion
+	Total int
+
+	//Cursor para pedir la siguiente pagina, vacio si no hay mas tareas
+	NextCursor string
 }
 
 var (
@@ -45,6 +263,27 @@ var (
 
 	//Error en el service
 	ErrTaskService = errors.New("task service can´t be processed")
+
+	//Error al marcar como terminada una tarea que tiene subtareas sin terminar, tambien es un campo invalido
+	ErrTaskOpenChildren = fmt.Errorf("%w: task has open children", ErrTaskInvalidField)
+
+	//Error al asignar un padre que formaria un ciclo, tambien es un campo invalido
+	ErrTaskParentCycle = fmt.Errorf("%w: parent would create a cycle", ErrTaskInvalidField)
+
+	//Error al modificar una tarea que cambio desde que se leyo, la version esperada no coincide con la guardada
+	ErrTaskVersionConflict = errors.New("task version conflict")
+
+	//Error al agregar una dependencia que formaria un ciclo, tambien es un campo invalido
+	ErrTaskDependencyCycle = fmt.Errorf("%w: dependency would create a cycle", ErrTaskInvalidField)
+
+	//Error cuando la auditoria no puede guardar las entradas pendientes, el cambio pedido no se hace
+	ErrTaskAuditUnavailable = errors.New("task audit unavailable")
+
+	//Error cuando un cambio se hizo pero su entrada de auditoria quedo pendiente, tambien es una auditoria no disponible
+	ErrTaskAuditPending = fmt.Errorf("%w: change saved, audit entry pending", ErrTaskAuditUnavailable)
+
+	//Error al pedir una revision que no existe o que no tiene el estado de la tarea, tambien es una tarea no encontrada
+	ErrTaskRevisionNotFound = fmt.Errorf("%w: revision not found", ErrTaskNotFound)
 )
 
 // Interfaz de repository
@@ -54,29 +293,124 @@ type TaskRepository interface {
 	Save(task *Task) (err error)
 
 	//Actualizar y sino esta devuelve error
+	//Si task.Version es distinta de 0 debe coincidir con la version guardada
 	Update(task Task) (err error)
 
-	//Actualizar parcialmente
-	UpdatePartial(id int, fields map[string]any) (err error)
+	//Actualizar parcialmente, si version es distinta de 0 debe coincidir con la version guardada
+	//Solo se modifican los campos de patch que tienen Set en true
+	UpdatePartial(id int, version int, patch TaskPatch) (err error)
+
+	//Mover una tarea a la papelera, sus subtareas pasan a ser hijas del padre de la tarea eliminada
+	//y las tareas que dependian de ella dejan de depender
+	//Si version es distinta de 0 debe coincidir con la version guardada
+	Delete(id int, version int) (err error)
+
+	//Obtener las tareas de la papelera, primero las eliminadas mas recientemente
+	ListTrash() (tasks []Task, err error)
 
-	//Eliminar una tarea
-	Delete(id int) (err error)
+	//Sacar una tarea de la papelera, si otra tarea ya usa su titulo devuelve ErrTaskDuplicated
+	//El padre y las dependencias que ya no existen se quitan de la tarea
+	Restore(id int) (err error)
 
-	//Obtener todas las tareas
+	//Eliminar definitivamente una tarea de la papelera
+	Purge(id int) (err error)
+
+	//Eliminar definitivamente las tareas que se movieron a la papelera antes de un instante
+	PurgeTrash(before time.Time) (purged int, err error)
+
+	//Obtener todas las tareas que cumplan con la consulta, ordenadas y paginadas
+	List(query TaskQuery) (page TaskPage, err error)
 
 	//Obtener por id
 	GetByID(id int) (task Task, err error)
+
+	//Obtener los ids de las tareas que no estan en la papelera con el titulo, sin distinguir mayusculas
+	//El servicio lo usa para la regla TaskRules.CaseInsensitiveTittles, el repositorio no la aplica al guardar
+	FindByTittle(tittle string) (ids []int, err error)
+
+	//Agregar una etiqueta a una tarea, si ya la tiene no hace nada
+	AddTag(id int, tag string) (err error)
+
+	//Quitar una etiqueta de una tarea, si no la tiene no hace nada
+	RemoveTag(id int, tag string) (err error)
+
+	//Obtener todas las etiquetas en uso con la cantidad de tareas que tiene cada una, ordenadas por nombre
+	ListTags() (tags []TagCount, err error)
+
+	//Indicar que la tarea id no puede empezar hasta que se termine la tarea blockedBy, si ya estaba no hace nada
+	AddDependency(id int, blockedBy int) (err error)
+
+	//Quitar una dependencia de una tarea, si no la tiene no hace nada
+	RemoveDependency(id int, blockedBy int) (err error)
 }
 
 // Interfaz de service
 type TaskService interface {
 	Save(task *Task) (err error)
 
-	Update(task Task) (err error)
+	//Los cambios de una tarea devuelven la tarea guardada con su nueva version, leida junto con el cambio
+	Update(task Task) (updated Task, err error)
+
+	UpdatePartial(id int, version int, patch TaskPatch) (updated Task, err error)
+
+	Delete(id int, version int) (err error)
+
+	//Eliminar una tarea junto con todas sus subtareas, la version se verifica solo en la tarea id
+	DeleteCascade(id int, version int) (err error)
 
-	UpdatePartial(id int, fields map[string]any) (err error)
+	ListTrash() (tasks []Task, err error)
 
-	Delete(id int) (err error)
+	Restore(id int) (restored Task, err error)
+
+	Purge(id int) (err error)
+
+	//Eliminar definitivamente las tareas que estan en la papelera desde hace mas de retention
+	PurgeExpired(retention time.Duration) (purged int, err error)
+
+	List(query TaskQuery) (page TaskPage, err error)
 
 	GetByID(id int) (task Task, err error)
+
+	//Obtener las subtareas directas de una tarea
+	GetChildren(id int) (children []Task, err error)
+
+	//Obtener una tarea con todas sus subtareas
+	GetSubtree(id int) (tree TaskTree, err error)
+
+	AddTag(id int, tag string) (updated Task, err error)
+
+	RemoveTag(id int, tag string) (updated Task, err error)
+
+	ListTags() (tags []TagCount, err error)
+
+	AddDependency(id int, blockedBy int) (updated Task, err error)
+
+	RemoveDependency(id int, blockedBy int) (updated Task, err error)
+
+	//Obtener las tareas sin terminar en un orden en que cada una aparece despues de las que la bloquean
+	NextTasks() (tasks []Task, err error)
+
+	//Obtener una copia del servicio que registra los cambios en la auditoria a nombre de actor
+	WithActor(actor string) TaskService
+
+	//Obtener una pagina del historial de cambios de una tarea, aunque ya no exista
+	History(query AuditQuery) (page AuditPage, err error)
+
+	//Obtener una revision de una tarea, la entrada tiene el estado completo de la tarea en esa revision
+	Revision(id int, revision int) (entry AuditEntry, err error)
+
+	//Obtener la ultima revision de una tarea hecha hasta un instante, es el estado de la tarea en ese instante
+	RevisionAt(id int, at time.Time) (entry AuditEntry, err error)
+
+	//Volver una tarea al estado que tenia en una revision, el cambio queda registrado como una revision nueva
+	//Si version es distinta de 0 debe coincidir con la version de la tarea
+	Revert(id int, revision int, version int) (reverted Task, err error)
+
+	//Obtener los cambios de todas las tareas posteriores al cursor de la consulta, en el orden en que se hicieron
+	//Si no hay cambios espera hasta query.Wait a que llegue alguno o a que se cancele ctx
+	Changes(ctx context.Context, query ChangeQuery) (page ChangePage, err error)
+
+	//Suscribirse a los cambios que cumplen el filtro a medida que ocurren, la suscripcion termina cuando se cancela ctx
+	//Si cursor no esta vacio primero se reciben los cambios posteriores a el que todavia se conservan
+	Subscribe(ctx context.Context, cursor string, filter ChangeFilter) (subscription ChangeSubscription, err error)
 }
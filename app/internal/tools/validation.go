@@ -0,0 +1,265 @@
+package tools
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+/*
+	Este archivo contiene la validacion de los campos de un body JSON decodificado en un map[string]any.
+
+	Cada tipo de request declara sus reglas con un Schema: que campos son obligatorios y que reglas
+	debe cumplir el valor de cada campo. La validacion no se detiene en el primer error, junta un
+	FieldError por cada campo invalido para que el cliente pueda corregirlos todos de una vez.
+*/
+
+// Mensajes de los errores de validacion
+const (
+	MsgRequired   = "field is required"
+	MsgNotNull    = "must not be null"
+	MsgString     = "must be a string"
+	MsgBool       = "must be a boolean"
+	MsgInteger    = "must be an integer"
+	MsgTime       = "must be a date in RFC 3339 format"
+	MsgStringList = "must be a list of strings"
+	MsgIntList    = "must be a list of integers"
+)
+
+// Regla que debe cumplir el valor de un campo, devuelve el mensaje del error o "" si el valor es valido
+// El valor es el que deja encoding/json en un map[string]any y es nil si el campo se envio como null
+type Rule func(value any) (msg string)
+
+// Reglas de un campo, se aplican en orden y se detienen en la primera que falla
+type FieldRules struct {
+	Field string
+
+	//Si es true el campo debe estar en el body y no puede ser null
+	Required bool
+
+	Rules []Rule
+}
+
+// Reglas de todos los campos de un request, los errores se devuelven en este orden
+type Schema []FieldRules
+
+// Error con todos los errores de validacion de un request
+type ValidationError struct {
+	Errors []*FieldError
+}
+
+// Acumulador de errores de validacion
+type Validator struct {
+	errors []*FieldError
+}
+
+// Metodo para obtener el mensaje con todos los errores
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		msgs = append(msgs, fieldErr.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Metodo para obtener cada error de campo con errors.As o recorriendo el error
+func (e *ValidationError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, fieldErr := range e.Errors {
+		errs = append(errs, fieldErr)
+	}
+	return errs
+}
+
+// Metodo para agregar el error de un campo
+func (v *Validator) Add(field string, msg string) {
+	(*v).errors = append((*v).errors, &FieldError{Field: field, Msg: msg})
+}
+
+// Metodo para agregar el error de un campo si no se cumple la condicion
+func (v *Validator) Check(ok bool, field string, msg string) {
+	if !ok {
+		v.Add(field, msg)
+	}
+}
+
+// Metodo para saber si se agrego algun error
+func (v *Validator) Valid() bool {
+	return len((*v).errors) == 0
+}
+
+// Metodo para obtener los errores acumulados, nil si no hay errores
+func (v *Validator) Err() (err error) {
+	if v.Valid() {
+		return
+	}
+
+	err = &ValidationError{Errors: (*v).errors}
+	return
+}
+
+// Metodo para validar los campos de un body con las reglas del schema
+// Los campos que no estan en el schema no se validan
+func (s Schema) Validate(fields map[string]any) (err error) {
+	var v Validator
+	for _, field := range s {
+		value, ok := fields[field.Field]
+		if !ok {
+			v.Check(!field.Required, field.Field, MsgRequired)
+			continue
+		}
+
+		if value == nil && field.Required {
+			v.Add(field.Field, MsgNotNull)
+			continue
+		}
+
+		if msg := field.Check(value); msg != "" {
+			v.Add(field.Field, msg)
+		}
+	}
+
+	err = v.Err()
+	return
+}
+
+// Metodo para validar el valor de un campo con sus reglas, devuelve el mensaje de la primera que falla o "" si es valido
+func (f FieldRules) Check(value any) (msg string) {
+	for _, rule := range f.Rules {
+		if msg = rule(value); msg != "" {
+			return
+		}
+	}
+	return
+}
+
+// --------------------- REGLAS ---------------------
+
+// Regla para que el valor no sea null, las demas reglas aceptan null como valor vacio
+func NotNull() Rule {
+	return func(value any) string {
+		if value == nil {
+			return MsgNotNull
+		}
+		return ""
+	}
+}
+
+// Regla para que el valor sea un string, null se acepta como valor vacio
+func String() Rule {
+	return func(value any) string {
+		if _, ok := value.(string); !ok && value != nil {
+			return MsgString
+		}
+		return ""
+	}
+}
+
+// Regla para que el valor sea un booleano, null se acepta como valor vacio
+func Bool() Rule {
+	return func(value any) string {
+		if _, ok := value.(bool); !ok && value != nil {
+			return MsgBool
+		}
+		return ""
+	}
+}
+
+// Regla para que el valor sea un numero entero, null se acepta como valor vacio
+func Integer() Rule {
+	return func(value any) string {
+		if _, ok := Int(value); value != nil && !ok {
+			return MsgInteger
+		}
+		return ""
+	}
+}
+
+// Regla para que el valor sea una fecha en formato RFC 3339, null se acepta como valor vacio
+func Time() Rule {
+	return func(value any) string {
+		if value == nil {
+			return ""
+		}
+		if date, ok := value.(string); !ok {
+			return MsgTime
+		} else if _, err := time.Parse(time.RFC3339, date); err != nil {
+			return MsgTime
+		}
+		return ""
+	}
+}
+
+// Regla para que el valor sea una lista de strings, null se acepta como lista vacia
+func StringList() Rule {
+	return func(value any) string {
+		if value == nil {
+			return ""
+		}
+		list, ok := value.([]any)
+		if !ok {
+			return MsgStringList
+		}
+		for _, item := range list {
+			if _, ok := item.(string); !ok {
+				return MsgStringList
+			}
+		}
+		return ""
+	}
+}
+
+// Regla para que el valor sea una lista de numeros enteros, null se acepta como lista vacia
+func IntList() Rule {
+	return func(value any) string {
+		if value == nil {
+			return ""
+		}
+		list, ok := value.([]any)
+		if !ok {
+			return MsgIntList
+		}
+		for _, item := range list {
+			if _, ok := Int(item); !ok {
+				return MsgIntList
+			}
+		}
+		return ""
+	}
+}
+
+// Regla para que un string tenga como maximo max caracteres, los valores que no son string no se validan
+func MaxLength(max int) Rule {
+	return func(value any) string {
+		if text, ok := value.(string); ok && utf8.RuneCountInString(text) > max {
+			return fmt.Sprintf("must be at most %d characters", max)
+		}
+		return ""
+	}
+}
+
+// Regla para que un string tenga un formato valido, los valores que no son string no se validan
+// valid indica si el string tiene el formato y msg es el mensaje del error
+func Format(msg string, valid func(text string) bool) Rule {
+	return func(value any) string {
+		if text, ok := value.(string); ok && !valid(text) {
+			return msg
+		}
+		return ""
+	}
+}
+
+// Funcion para leer un numero entero de un valor decodificado de JSON, los numeros llegan como float64
+// ok es false si el valor no es un numero, tiene decimales o no entra en un int32
+func Int(value any) (number int, ok bool) {
+	float, ok := value.(float64)
+	if !ok || float != math.Trunc(float) || math.Abs(float) > math.MaxInt32 {
+		ok = false
+		return
+	}
+
+	number = int(float)
+	return
+}
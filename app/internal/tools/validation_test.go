@@ -0,0 +1,103 @@
+package tools_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Taks/internal/tools"
+	"github.com/stretchr/testify/require"
+)
+
+// Test de la validacion de los campos con un schema
+func TestSchemaValidate(t *testing.T) {
+	schema := tools.Schema{
+		{Field: "name", Required: true, Rules: []tools.Rule{tools.String(), tools.MaxLength(5)}},
+		{Field: "active", Required: true, Rules: []tools.Rule{tools.Bool()}},
+		{Field: "count", Rules: []tools.Rule{tools.Integer()}},
+		{Field: "at", Rules: []tools.Rule{tools.Time()}},
+		{Field: "labels", Rules: []tools.Rule{tools.StringList()}},
+		{Field: "ids", Rules: []tools.Rule{tools.IntList()}},
+		{Field: "code", Rules: []tools.Rule{tools.String(), tools.Format("must be uppercase", func(text string) bool {
+			return text == strings.ToUpper(text)
+		})}},
+	}
+
+	//Test los campos validos, los opcionales que no estan y los null no tienen errores
+	t.Run("Success - Valid fields", func(t *testing.T) {
+
+		//arrange
+		fields := map[string]any{
+			"name":   "ñandú",
+			"active": false,
+			"count":  float64(3),
+			"at":     "2024-05-01T10:00:00Z",
+			"labels": nil,
+			"ids":    []any{float64(1), float64(2)},
+			"other":  []any{"not validated"},
+		}
+
+		//act
+		err := schema.Validate(fields)
+
+		//assert
+		require.NoError(t, err)
+	})
+
+	//Test se informa un error por cada campo invalido, en el orden del schema
+	t.Run("Error - All invalid fields", func(t *testing.T) {
+
+		//arrange
+		fields := map[string]any{
+			"name":   "too long",
+			"count":  1.5,
+			"at":     "yesterday",
+			"labels": []any{"a", true},
+			"ids":    "1,2",
+			"code":   "abc",
+		}
+
+		//act
+		err := schema.Validate(fields)
+
+		//assert
+		var validationErr *tools.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []*tools.FieldError{
+			{Field: "name", Msg: "must be at most 5 characters"},
+			{Field: "active", Msg: tools.MsgRequired},
+			{Field: "count", Msg: tools.MsgInteger},
+			{Field: "at", Msg: tools.MsgTime},
+			{Field: "labels", Msg: tools.MsgStringList},
+			{Field: "ids", Msg: tools.MsgIntList},
+			{Field: "code", Msg: "must be uppercase"},
+		}, validationErr.Errors)
+
+		var fieldErr *tools.FieldError
+		require.ErrorAs(t, err, &fieldErr)
+		require.Equal(t, "name", fieldErr.Field)
+	})
+
+	//Test las reglas de un campo se detienen en la primera que falla
+	t.Run("Error - One error per field", func(t *testing.T) {
+
+		//act
+		err := schema.Validate(map[string]any{"name": 12345678, "active": "yes"})
+
+		//assert
+		require.EqualError(t, err, "name: must be a string; active: must be a boolean")
+	})
+
+	//Test los campos obligatorios no pueden ser null, los opcionales si
+	t.Run("Error - Required field is null", func(t *testing.T) {
+
+		//act
+		err := schema.Validate(map[string]any{"name": nil, "active": true, "count": nil})
+
+		//assert
+		var validationErr *tools.ValidationError
+		require.ErrorAs(t, err, &validationErr)
+		require.Equal(t, []*tools.FieldError{
+			{Field: "name", Msg: tools.MsgNotNull},
+		}, validationErr.Errors)
+	})
+}
@@ -37,7 +37,7 @@ func RequestJSON(r *http.Request, ptr any) (err error) {
 	y un mensaje de error personalizado.
 	*/
 	if err != nil {
-		err = fmt.Errorf("%w. %v", ErrRequestJSONInvalid, err)
+		err = fmt.Errorf("%w. %w", ErrRequestJSONInvalid, err)
 		return
 	}
 	return
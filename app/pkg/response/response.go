@@ -38,12 +38,12 @@ Esta funcion es para escribir respuestas en formato JSON:
 */
 func ResponseJSON(w http.ResponseWriter, code int, body any) {
 
-	//Primero se pregunta si ocurre un error codificando el JSON
-	if err := json.NewEncoder(w).Encode(body); err != nil {
+	//Primero se codifica el JSON, despues de escribir el codigo de estado ya no se puede enviar un error
+	bytes, err := json.Marshal(body)
+	if err != nil {
 		//Si ocurre un error codificando el JSON, enviar una respuesta HTTP con error
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("internal server error"))
+		Text(w, http.StatusInternalServerError, "internal server error")
+		return
 	}
 
 	//Configura el encabezado Content-Type de la respuesta HTTP para indicar que la respuesta será JSON codificado en UTF-8.
@@ -52,4 +52,56 @@ func ResponseJSON(w http.ResponseWriter, code int, body any) {
 	//Establece el código de estado HTTP de la respuesta en el valor proporcionado por code
 	w.WriteHeader(code)
 
+	//Envía el JSON como cuerpo de la respuesta, con un salto de linea al final como lo hace json.Encoder
+	w.Write(append(bytes, '\n'))
+}
+
+/*
+Esta estructura es un problema segun el RFC 7807 (Problem Details for HTTP APIs):
+
+  - > Type: Una URI que identifica el tipo de problema, los clientes la usan para distinguir los errores.
+  - > Title: Un resumen del tipo de problema, es el mismo para todos los problemas del mismo tipo.
+  - > Status: El código de estado HTTP de la respuesta.
+  - > Detail: Una explicacion de este problema en particular.
+  - > Instance: Una URI que identifica donde ocurrio el problema, por ejemplo la ruta del request.
+  - > Errors: Los errores de cada campo cuando falla una validacion, es una extension del RFC.
+*/
+type Problem struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail,omitempty"`
+	Instance string         `json:"instance,omitempty"`
+	Errors   []FieldProblem `json:"errors,omitempty"`
+}
+
+// Esta estructura es el error de un campo dentro de un problema de validacion
+type FieldProblem struct {
+	Field  string `json:"field"`
+	Detail string `json:"detail"`
+}
+
+/*
+Esta funcion es para escribir respuestas de error en formato problem+json (RFC 7807):
+
+  - > w http.ResponseWriter: Un objeto que permite escribir una respuesta HTTP al cliente.
+  - > problem Problem: El problema que se enviará, su Status es el código de estado HTTP de la respuesta.
+*/
+func ProblemJSON(w http.ResponseWriter, problem Problem) {
+
+	//Primero se codifica el JSON, un Problem siempre se puede codificar
+	bytes, err := json.Marshal(problem)
+	if err != nil {
+		Text(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	//Configura el encabezado Content-Type con el tipo de medio del RFC 7807.
+	w.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
+
+	//Establece el código de estado HTTP de la respuesta en el del problema
+	w.WriteHeader(problem.Status)
+
+	//Envía el problema como cuerpo de la respuesta
+	w.Write(append(bytes, '\n'))
 }